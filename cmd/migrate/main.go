@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	database "backend/config"
+	"backend/migrations"
+	"backend/services"
+)
+
+const usage = `Usage: migrate <command> [n | mode]
+
+Commands:
+  up [n]     Jalankan n migrasi berikutnya (default: semua)
+  down [n]   Batalkan n migrasi terakhir (default: 1)
+  status     Tampilkan migrasi yang sudah / belum dijalankan
+  baseline   Tandai database lama (dibuat AutoMigrate) sebagai versi 1 setelah kolomnya diperiksa
+  data       Migrasi data lama: enkripsi field plaintext, hash API Key plaintext, ubah role lama,
+             dan masukkan pasien tanpa organisasi ke organisasi registrasi
+  sensor-storage <timescale|partitioned>
+             Ubah tabel sensor_data ke hypertable TimescaleDB / partisi per bulan (saat maintenance)`
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	steps := 0
+	if len(os.Args) == 3 && os.Args[1] != "sensor-storage" {
+		n, err := strconv.Atoi(os.Args[2])
+		if err != nil || n < 1 {
+			fmt.Println(usage)
+			os.Exit(2)
+		}
+		steps = n
+	}
+
+	database.ConnectDatabase()
+
+	switch os.Args[1] {
+	case "up":
+		done, err := migrations.Up(database.DB, steps)
+		for _, migration := range done {
+			fmt.Printf("✅ Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		if len(done) == 0 {
+			fmt.Println("Database schema is up to date")
+		}
+
+	case "down":
+		done, err := migrations.Down(database.DB, steps)
+		for _, migration := range done {
+			fmt.Printf("↩️  Rolled back %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		if len(done) == 0 {
+			fmt.Println("No migration to roll back")
+		}
+
+	case "baseline":
+		initial, err := migrations.Baseline(database.DB)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Printf("✅ Marked %04d_%s as applied, run `migrate up` for the remaining migrations\n", initial.Version, initial.Name)
+
+	case "data":
+		if err := migrations.RequireCurrent(database.DB); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		services.InitFieldEncryption()
+
+		encrypted, rewrapped, err := services.RotateFieldEncryption()
+		if err != nil {
+			log.Fatal("❌ Failed to encrypt fields: ", err)
+		}
+		fmt.Printf("✅ Encrypted %d plaintext or v1 values, re-wrapped %d data keys\n", encrypted, rewrapped)
+
+		hashed, err := services.HashLegacyAPIKeys()
+		if err != nil {
+			log.Fatal("❌ Failed to hash API keys: ", err)
+		}
+		fmt.Printf("✅ Hashed %d legacy device API keys\n", hashed)
+
+		roles, err := services.MigrateLegacyRoles()
+		if err != nil {
+			log.Fatal("❌ Failed to migrate legacy roles: ", err)
+		}
+		fmt.Printf("✅ Migrated %d users from legacy roles\n", roles)
+
+		assigned, err := services.AssignUnassignedUsers()
+		if err != nil {
+			log.Fatal("❌ Failed to assign patients to an organization: ", err)
+		}
+		fmt.Printf("✅ Assigned %d patients without organization to the registration organization\n", assigned)
+
+	case "sensor-storage":
+		if len(os.Args) != 3 {
+			fmt.Println(usage)
+			os.Exit(2)
+		}
+		if err := migrations.RequireCurrent(database.DB); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		mode := strings.ToLower(os.Args[2])
+		if err := services.ConvertSensorStorage(mode); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Printf("✅ sensor_data storage is %s, set SENSOR_STORAGE_MODE=%s\n", mode, mode)
+
+	case "status":
+		statuses, err := migrations.GetStatus(database.DB)
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", status.Version, status.Name, applied)
+		}
+
+		// Data lama hanya bisa dihitung setelah skema terbaru dijalankan
+		if migrations.RequireCurrent(database.DB) != nil {
+			return
+		}
+		legacy, err := services.GetLegacyDataStatus()
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		fmt.Printf("\nLegacy data (migrate data): %d users with unencrypted fields, %d plaintext API keys, "+
+			"%d legacy roles, %d patients without organization\n",
+			legacy.UnencryptedUsers, legacy.PlaintextAPIKeys, legacy.LegacyRoles, legacy.UnassignedPatients)
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
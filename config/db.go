@@ -7,7 +7,6 @@ import (
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"backend/models"
 	"github.com/joho/godotenv"
 )
 
@@ -38,13 +37,6 @@ func ConnectDatabase() {
 		log.Fatal("❌ Failed to connect to database:", err)
 	}
 
-	// Auto Migrate untuk semua model
-	err = db.AutoMigrate(&models.User{}, &models.Device{}, &models.SensorData{})
-	if err != nil {
-		log.Fatal("❌ Migration failed:", err)
-	}
-
-	fmt.Println("🚀 Database migration completed successfully!")
-
+	// Skema database dikelola lewat migrasi bernomor (go run ./cmd/migrate up)
 	DB = db
 }
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"net/http"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deviceAccess - Permission yang dibutuhkan untuk satu jenis akses ke device
+type deviceAccess struct {
+	own    string // Permission untuk device milik sendiri
+	shared string // Permission untuk device pasien yang memberikan akses (kosong = tidak bisa lewat akses bersama)
+	all    string // Permission untuk semua device
+	scope  string // Cakupan AccessGrant minimal untuk akses bersama
+}
+
+// Jenis akses device
+var (
+	readVitalsAccess = deviceAccess{
+		own: models.PermReadOwnVitals, shared: models.PermReadSharedVitals, all: models.PermReadAllVitals,
+		scope: models.GrantScopeViewVitals,
+	}
+	manageDeviceAccess = deviceAccess{
+		own: models.PermManageOwnDevices, shared: models.PermManageSharedDevices, all: models.PermManageAllDevices,
+		scope: models.GrantScopeManageDevices,
+	}
+	// Hapus device hanya untuk pemilik dan admin, tidak lewat akses bersama
+	deleteDeviceAccess = deviceAccess{own: models.PermManageOwnDevices, all: models.PermManageAllDevices}
+)
+
+// authorizeDeviceAccess - Mengecek user yang login boleh mengakses device: pemilik device,
+// user yang diberi akses oleh pasien (AccessGrant), atau role dengan akses ke semua pasien
+// di organisasinya (super-admin lintas organisasi).
+// Mengirim respons error dan mengembalikan false jika tidak diizinkan.
+func authorizeDeviceAccess(c *gin.Context, deviceID uint, access deviceAccess) (*models.Device, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+	role := c.GetString("role")
+	all := services.HasPermission(role, access.all)
+
+	var device models.Device
+	if err := database.DB.First(&device, deviceID).Error; err != nil {
+		denyDeviceAccess(c, all)
+		return nil, false
+	}
+	markAuditTarget(c, device.UserID, &device.ID)
+
+	if all && services.InTenant(tenantID(c), device.OrganizationID) {
+		return &device, true
+	}
+	if device.UserID == userID.(uint) && services.HasPermission(role, access.own) {
+		return &device, true
+	}
+	if access.shared != "" && services.HasPermission(role, access.shared) &&
+		services.HasActiveGrant(device.UserID, userID.(uint), access.scope) {
+		return &device, true
+	}
+
+	denyDeviceAccess(c, all)
+	return nil, false
+}
+
+// denyDeviceAccess - Device di luar organisasi admin diperlakukan seperti tidak ada,
+// "tidak ditemukan" hanya dibedakan untuk role yang boleh melihat semua device
+func denyDeviceAccess(c *gin.Context, all bool) {
+	if all {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+	} else {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this device"})
+	}
+}
+
+// authorizeVitalsAccess - Mengecek akses baca data sensor (vital sign) dari device
+func authorizeVitalsAccess(c *gin.Context, deviceID uint) (*models.Device, bool) {
+	return authorizeDeviceAccess(c, deviceID, readVitalsAccess)
+}
+
+// markAuditTarget - Menandai pasien (dan device) yang datanya diakses untuk audit log
+func markAuditTarget(c *gin.Context, userID uint, deviceID *uint) {
+	c.Set(services.AuditTargetUserKey, userID)
+	if deviceID != nil {
+		c.Set(services.AuditTargetDeviceKey, *deviceID)
+	}
+}
+
+// markAuditTargets - Menandai beberapa pasien yang datanya ada di respons (satu catatan audit per pasien)
+func markAuditTargets(c *gin.Context, userIDs []uint) {
+	c.Set(services.AuditTargetUsersKey, userIDs)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// deletedUserResponse - User yang dihapus beserta waktu penghapusan permanennya
+type deletedUserResponse struct {
+	models.User
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// RestoreAccount - Memulihkan akun dari link yang dikirim lewat email saat akun dihapus
+func RestoreAccount(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	user, err := services.RestoreUserByToken(token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRestoreToken) || errors.Is(err, services.ErrAccountNotDeleted) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired restore link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore account"})
+		return
+	}
+	markAuditTarget(c, user.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account restored successfully"})
+}
+
+// GetDeletedUsersAdmin - Daftar user yang dihapus dan masih bisa dipulihkan
+func GetDeletedUsersAdmin(c *gin.Context) {
+	var users []models.User
+	err := tenantDB(c).Unscoped().
+		Where("users.deleted_at IS NOT NULL").
+		Select("id, username, email, role, organization_id, full_name, email_verified, created_at, updated_at, deleted_at").
+		Order("deleted_at DESC").
+		Find(&users).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve deleted users"})
+		return
+	}
+
+	response := make([]deletedUserResponse, len(users))
+	for i, user := range users {
+		response[i] = deletedUserResponse{User: user, PurgeAt: services.PurgeAt(user.DeletedAt.Time)}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RestoreUserAdmin - Memulihkan user yang dihapus sebelum masa tenggang habis
+func RestoreUserAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := tenantDB(c).Unscoped().Where("users.deleted_at IS NOT NULL").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted user not found"})
+		return
+	}
+	if !canManageUser(c, user) {
+		return
+	}
+
+	restored, err := services.RestoreUser(user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrAccountNotDeleted) {
+			c.JSON(http.StatusGone, gin.H{"error": "Account has already been permanently deleted"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore user"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User restored successfully", "user": restored})
+}
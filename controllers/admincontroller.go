@@ -3,6 +3,8 @@ package controllers
 import (
 	database "backend/config"
 	"backend/models"
+	"backend/mqttbridge"
+	"backend/services"
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
@@ -12,6 +14,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GenerateAPIKey - Membuat API Key unik untuk perangkat
@@ -35,6 +38,7 @@ func CreateUserAdmin(c *gin.Context) {
 		Province       *string `json:"province"`
 		City           *string `json:"city"`
 		PostalCode     *string `json:"postal_code"`
+		OrganizationID *uint   `json:"organization_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -42,6 +46,26 @@ func CreateUserAdmin(c *gin.Context) {
 		return
 	}
 
+	// Admin organisasi hanya bisa menambahkan user ke organisasinya sendiri
+	organizationID, ok := assignableOrganization(c, input.OrganizationID)
+	if !ok {
+		return
+	}
+
+	// Validasi role yang diberikan dan permission untuk mengisi riwayat medis
+	actorRole := c.GetString("role")
+	if input.Role == "" {
+		input.Role = models.RolePatient
+	}
+	if !services.CanAssignRole(actorRole, input.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to assign this role"})
+		return
+	}
+	if input.MedicalHistory != nil && !services.HasPermission(actorRole, models.PermEditAllMedicalHistory) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to edit medical history"})
+		return
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -73,6 +97,7 @@ func CreateUserAdmin(c *gin.Context) {
 		Province:       input.Province,
 		City:           input.City,
 		PostalCode:     input.PostalCode,
+		OrganizationID: organizationID,
 	}
 
 	// Simpan ke database
@@ -80,6 +105,7 @@ func CreateUserAdmin(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	markAuditTarget(c, user.ID, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "User created successfully"})
 }
@@ -87,11 +113,18 @@ func CreateUserAdmin(c *gin.Context) {
 // GetAllUsers - Mendapatkan semua user
 func GetAllUsersAdmin(c *gin.Context) {
 	var users []models.User
-	if err := database.DB.Select("id, username, email, role, full_name, date_of_birth, medical_history, address, province, city, postal_code, email_verified, created_at, updated_at").Find(&users).Error; err != nil {
+	if err := tenantDB(c).Select("id, username, email, role, organization_id, full_name, date_of_birth, medical_history, address, province, city, postal_code, email_verified, created_at, updated_at").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
 		return
 	}
 
+	// Respons berisi data medis setiap user, jadi setiap user dicatat di audit log
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	markAuditTargets(c, ids)
+
 	c.JSON(http.StatusOK, users)
 }
 
@@ -104,10 +137,13 @@ func UpdateUserAdmin(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := database.DB.First(&user, userID).Error; err != nil {
+	if err := tenantDB(c).First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		return
 	}
+	if !canManageUser(c, user) {
+		return
+	}
 
 	var input struct {
 		Username       *string `json:"username"`
@@ -121,6 +157,7 @@ func UpdateUserAdmin(c *gin.Context) {
 		City           *string `json:"city"`
 		PostalCode     *string `json:"postal_code"`
 		Password       *string `json:"password,omitempty"` // Opsional, tidak harus dikirim
+		OrganizationID *uint   `json:"organization_id"`    // Hanya super-admin, 0 = keluarkan dari organisasi
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -128,33 +165,68 @@ func UpdateUserAdmin(c *gin.Context) {
 		return
 	}
 
-	// Perbarui hanya jika ada perubahan
+	// Perbarui hanya kolom yang dikirim, kolom lain (misalnya totp_last_counter dari login yang berjalan
+	// bersamaan) tidak ditimpa dengan nilai lama
+	columns := []string{"updated_at"}
 	if input.Username != nil {
 		user.Username = *input.Username
+		columns = append(columns, "username")
 	}
 	if input.Email != nil {
 		user.Email = *input.Email
+		columns = append(columns, "email")
 	}
+	actorRole := c.GetString("role")
 	if input.Role != nil {
+		if !services.CanAssignRole(actorRole, *input.Role) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to assign this role"})
+			return
+		}
 		user.Role = *input.Role
+		columns = append(columns, "role")
 	}
 	if input.FullName != nil {
 		user.FullName = input.FullName
+		columns = append(columns, "full_name")
 	}
 	if input.MedicalHistory != nil {
+		if !services.HasPermission(actorRole, models.PermEditAllMedicalHistory) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to edit medical history"})
+			return
+		}
 		user.MedicalHistory = input.MedicalHistory
+		columns = append(columns, "medical_history")
 	}
 	if input.Address != nil {
 		user.Address = input.Address
+		columns = append(columns, "address")
 	}
 	if input.Province != nil {
 		user.Province = input.Province
+		columns = append(columns, "province")
 	}
 	if input.City != nil {
 		user.City = input.City
+		columns = append(columns, "city")
 	}
 	if input.PostalCode != nil {
 		user.PostalCode = input.PostalCode
+		columns = append(columns, "postal_code")
+	}
+
+	// Pindah organisasi (device milik user ikut pindah)
+	organizationChanged := false
+	if input.OrganizationID != nil {
+		requested := input.OrganizationID
+		if *requested == 0 {
+			requested = nil
+		}
+		organizationID, ok := assignableOrganization(c, requested)
+		if !ok {
+			return
+		}
+		organizationChanged = !sameOrganization(user.OrganizationID, organizationID)
+		user.OrganizationID = organizationID
 	}
 
 	// Parsing DateOfBirth jika diberikan
@@ -165,6 +237,7 @@ func UpdateUserAdmin(c *gin.Context) {
 			return
 		}
 		user.DateOfBirth = &parsedDate
+		columns = append(columns, "date_of_birth")
 	}
 
 	// Jika password diisi, hash password baru
@@ -175,10 +248,21 @@ func UpdateUserAdmin(c *gin.Context) {
 			return
 		}
 		user.Password = string(hashedPassword)
+		columns = append(columns, "password")
 	}
 
-	// Simpan perubahan ke database
-	if err := database.DB.Save(&user).Error; err != nil {
+	// Simpan perubahan ke database, device ikut dipindah dalam transaksi yang sama.
+	// Updates lewat struct (bukan map) agar kolom terenkripsi tetap melewati serializer.
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Select(columns).Updates(&user).Error; err != nil {
+			return err
+		}
+		if organizationChanged {
+			return services.SetUserOrganization(tx, user.ID, user.OrganizationID)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
@@ -186,6 +270,50 @@ func UpdateUserAdmin(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
+// canManageUser - Hanya super-admin yang boleh mengubah atau menghapus akun super-admin
+func canManageUser(c *gin.Context, target models.User) bool {
+	if target.Role == models.RoleSuperAdmin && c.GetString("role") != models.RoleSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to manage this user"})
+		return false
+	}
+	return true
+}
+
+// assignableOrganization - Organisasi yang boleh diberikan ke user oleh admin yang login.
+// Super-admin bebas memilih organisasi, admin organisasi selalu memakai organisasinya sendiri.
+func assignableOrganization(c *gin.Context, requested *uint) (*uint, bool) {
+	if services.HasPermission(c.GetString("role"), models.PermManageOrganizations) {
+		if requested == nil {
+			return nil, true
+		}
+		if _, err := services.FindOrganization(*requested); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Organization not found"})
+			return nil, false
+		}
+		return requested, true
+	}
+
+	orgID, _ := c.Get("organization_id")
+	own, _ := orgID.(*uint)
+	if own == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned to an organization"})
+		return nil, false
+	}
+	if requested != nil && *requested != *own {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only manage users in your organization"})
+		return nil, false
+	}
+	return own, true
+}
+
+// sameOrganization - Membandingkan dua organization_id yang bisa nil
+func sameOrganization(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
 // DeleteUser - Menghapus user berdasarkan ID
 func DeleteUserAdmin(c *gin.Context) {
 	userID, err := strconv.Atoi(c.Param("user_id"))
@@ -194,31 +322,44 @@ func DeleteUserAdmin(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&models.User{}, userID).Error; err != nil {
+	var user models.User
+	if err := tenantDB(c).First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !canManageUser(c, user) {
+		return
+	}
+
+	// Akun hanya ditandai terhapus, data dihapus permanen setelah masa tenggang
+	purgeAt, err := services.SoftDeleteUser(user)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "User scheduled for deletion", "purge_at": purgeAt})
 }
 
 // CreateDevice - Menambahkan device baru untuk user
 func CreateDeviceAdmin(c *gin.Context) {
-	// Pastikan hanya admin yang bisa akses
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admin can add devices"})
-		return
-	}
-
 	var device models.Device
 	if err := c.ShouldBindJSON(&device); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Generate API Key untuk device baru
-	device.APIKey = GenerateAPIKey()
+	// Pemilik device harus berada di organisasi admin, device ikut organisasi pemiliknya
+	var owner models.User
+	if err := tenantDB(c).First(&owner, device.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	device.OrganizationID = owner.OrganizationID
+
+	// Generate API Key untuk device baru (hanya hash yang disimpan)
+	apiKey := GenerateAPIKey()
+	services.SetAPIKey(&device, apiKey)
 
 	// Simpan ke database
 	if err := database.DB.Create(&device).Error; err != nil {
@@ -226,25 +367,25 @@ func CreateDeviceAdmin(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Device created successfully", "api_key": device.APIKey})
+	c.JSON(http.StatusOK, gin.H{"message": "Device created successfully", "api_key": apiKey})
 }
 
 // GetAllDevicesAdmin - Mendapatkan semua device
 func GetAllDevicesAdmin(c *gin.Context) {
-	// Pastikan hanya admin yang bisa akses
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admin can view all devices"})
+	var devices []models.Device
+	if err := tenantDB(c).Find(&devices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve devices"})
 		return
 	}
 
-	var devices []models.Device
-	if err := database.DB.Find(&devices).Error; err != nil {
+	// Sertakan status konektivitas dan riwayat uptime
+	result, err := withStatusHistory(devices)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve devices"})
 		return
 	}
 
-	c.JSON(http.StatusOK, devices)
+	c.JSON(http.StatusOK, result)
 }
 
 // UpdateDeviceAdmin - Memperbarui device berdasarkan ID
@@ -256,33 +397,12 @@ func UpdateDeviceAdmin(c *gin.Context) {
 		return
 	}
 
-	// Ambil user ID dan role dari token JWT
-	userID, exists := c.Get("user_id")
-	role, _ := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	// Pastikan user boleh mengelola device ini
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), manageDeviceAccess)
+	if !ok {
 		return
 	}
 
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
-
-	// Cari device berdasarkan ID
-	var device models.Device
-	if role != "admin" {
-		// Jika bukan admin, pastikan device milik user yang sedang login
-		if err := database.DB.Where("id = ? AND user_id = ?", uint(deviceID), userIDUint).First(&device).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to edit this device"})
-			return
-		}
-	} else {
-		// Jika admin, tidak perlu cek user_id
-		if err := database.DB.Where("id = ?", uint(deviceID)).First(&device).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
-			return
-		}
-	}
-
 	// Ambil data yang dikirimkan dalam body request
 	var input struct {
 		CurrentState string `json:"current_state"`
@@ -298,52 +418,51 @@ func UpdateDeviceAdmin(c *gin.Context) {
 	device.CurrentState = input.CurrentState
 	device.Delay = input.Delay
 
-	if err := database.DB.Save(&device).Error; err != nil {
+	if err := database.DB.Save(device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device"})
 		return
 	}
 
+	// Kirim konfigurasi terbaru ke perangkat yang terhubung lewat MQTT
+	mqttbridge.PublishDeviceConfig(device)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Device updated successfully"})
 }
 
-// DeleteDevice - Menghapus device berdasarkan ID
-func DeleteDeviceAdmin(c *gin.Context) {
-	// Ambil ID perangkat dari parameter URL dan konversi ke uint
+// RotateDeviceKeyAdmin - Mengganti API Key device berdasarkan ID
+func RotateDeviceKeyAdmin(c *gin.Context) {
 	deviceID, err := strconv.Atoi(c.Param("device_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
 		return
 	}
 
-	// Ambil user ID dan role dari token JWT
-	userID, exists := c.Get("user_id")
-	role, _ := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	// Pastikan device berada di organisasi admin
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), manageDeviceAccess)
+	if !ok {
 		return
 	}
 
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
+	rotateDeviceKey(c, device)
+}
 
-	// Cari device berdasarkan ID
-	var device models.Device
-	if role != "admin" {
-		// Jika bukan admin, pastikan device milik user yang sedang login
-		if err := database.DB.Where("id = ? AND user_id = ?", deviceID, userIDUint).First(&device).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to delete this device"})
-			return
-		}
-	} else {
-		// Jika admin, tidak perlu cek user_id
-		if err := database.DB.Where("id = ?", deviceID).First(&device).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
-			return
-		}
+// DeleteDevice - Menghapus device berdasarkan ID
+func DeleteDeviceAdmin(c *gin.Context) {
+	// Ambil ID perangkat dari parameter URL dan konversi ke uint
+	deviceID, err := strconv.Atoi(c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+
+	// Pastikan user boleh mengelola device ini
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), deleteDeviceAccess)
+	if !ok {
+		return
 	}
 
 	// Hapus device
-	if err := database.DB.Delete(&device).Error; err != nil {
+	if err := database.DB.Delete(device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete device"})
 		return
 	}
@@ -360,17 +479,6 @@ func DeleteSensorDataAdmin(c *gin.Context) {
 		return
 	}
 
-	// Ambil user ID dan role dari token JWT
-	userID, exists := c.Get("user_id")
-	role, _ := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
-
 	// Cari data sensor berdasarkan ID sensor
 	var sensorData models.SensorData
 	if err := database.DB.First(&sensorData, sensorID).Error; err != nil {
@@ -378,14 +486,9 @@ func DeleteSensorDataAdmin(c *gin.Context) {
 		return
 	}
 
-	// Jika bukan admin, pastikan data sensor yang akan dihapus milik perangkat pengguna yang sedang login
-	if role != "admin" {
-		var device models.Device
-		// Cek apakah perangkat yang terkait dengan data sensor milik user yang sedang login
-		if err := database.DB.Where("id = ? AND user_id = ?", sensorData.DeviceID, userIDUint).First(&device).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to delete this sensor data"})
-			return
-		}
+	// Pastikan user boleh mengelola device pemilik data sensor ini
+	if _, ok := authorizeDeviceAccess(c, sensorData.DeviceID, deleteDeviceAccess); !ok {
+		return
 	}
 
 	// Hapus data sensor
@@ -406,33 +509,12 @@ func GetSensorDataByAdmin(c *gin.Context) {
 		return
 	}
 
-	// Ambil user ID dan role dari token JWT
-	userID, exists := c.Get("user_id")
-	role, _ := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
-
-	// Jika bukan admin, pastikan data sensor yang diambil adalah milik user yang sedang login
-	if role != "admin" {
-		var device models.Device
-		// Cek apakah perangkat milik user yang sedang login
-		if err := database.DB.Where("id = ? AND user_id = ?", deviceID, userIDUint).First(&device).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this device's sensor data"})
-			return
-		}
-	}
-
-	// Ambil data sensor berdasarkan device ID
-	var sensorData []models.SensorData
-	if err := database.DB.Where("device_id = ?", deviceID).Find(&sensorData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sensor data"})
+	// Pastikan user boleh membaca data sensor device ini
+	device, ok := authorizeVitalsAccess(c, uint(deviceID))
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"sensor_data": sensorData})
+	// Ambil data sensor berdasarkan device ID (dengan filter waktu & pagination)
+	respondSensorHistory(c, device)
 }
@@ -0,0 +1,381 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertRuleInput - Input untuk membuat / mengubah AlertRule
+type alertRuleInput struct {
+	DeviceID    *uint    `json:"device_id"`
+	Name        *string  `json:"name"`
+	Metric      *string  `json:"metric"`
+	Operator    *string  `json:"operator"`
+	Threshold   *float64 `json:"threshold"`
+	Consecutive *int     `json:"consecutive"`
+	Severity    *string  `json:"severity"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// applyAlertRuleInput - Menerapkan input ke rule dan memvalidasinya, mengembalikan pesan error jika tidak valid
+func applyAlertRuleInput(rule *models.AlertRule, input alertRuleInput) string {
+	if input.DeviceID != nil {
+		// Device harus milik pasien yang sama
+		var device models.Device
+		if err := database.DB.Where("id = ? AND user_id = ?", *input.DeviceID, rule.UserID).First(&device).Error; err != nil {
+			return "Device not found for this user"
+		}
+		rule.DeviceID = input.DeviceID
+	}
+	if input.Name != nil {
+		rule.Name = *input.Name
+	}
+	if input.Metric != nil {
+		rule.Metric = *input.Metric
+	}
+	if input.Operator != nil {
+		rule.Operator = *input.Operator
+	}
+	if input.Threshold != nil {
+		rule.Threshold = *input.Threshold
+	}
+	if input.Consecutive != nil {
+		rule.Consecutive = *input.Consecutive
+	}
+	if input.Severity != nil {
+		rule.Severity = *input.Severity
+	}
+	if input.Enabled != nil {
+		rule.Enabled = *input.Enabled
+	}
+
+	if !services.AlertMetrics[rule.Metric] {
+		return "Metric must be one of bpm, spo2, temp"
+	}
+	if !services.AlertOperators[rule.Operator] {
+		return "Operator must be one of <, <=, >, >="
+	}
+	if rule.Consecutive < 1 || rule.Consecutive > services.MaxConsecutiveReadings {
+		return "Consecutive must be between 1 and 100"
+	}
+	if rule.Severity == "" {
+		rule.Severity = "warning"
+	}
+	return ""
+}
+
+// createAlertRule - Membuat AlertRule untuk pasien tertentu
+func createAlertRule(c *gin.Context, patientID uint) {
+	var input alertRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if input.Metric == nil || input.Operator == nil || input.Threshold == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric, operator and threshold are required"})
+		return
+	}
+
+	rule := models.AlertRule{
+		UserID:      patientID,
+		Consecutive: 1,
+		Enabled:     true,
+		CreatedBy:   c.MustGet("user_id").(uint),
+	}
+	if msg := applyAlertRuleInput(&rule, input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule created successfully", "rule": rule})
+}
+
+// =================== Alert Rules (User) ===================
+
+// GetAlertRulesByUser - Mendapatkan semua alert rule milik user
+func GetAlertRulesByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var rules []models.AlertRule
+	if err := database.DB.Where("user_id = ?", userID).Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// AddAlertRuleByUser - Menambahkan alert rule untuk user sendiri
+func AddAlertRuleByUser(c *gin.Context) {
+	createAlertRule(c, c.MustGet("user_id").(uint))
+}
+
+// UpdateAlertRuleByUser - Mengubah alert rule milik user
+func UpdateAlertRuleByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var rule models.AlertRule
+	if err := database.DB.Where("id = ? AND user_id = ?", uint(ruleID), userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	var input alertRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+	if msg := applyAlertRuleInput(&rule, input); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := database.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule updated successfully", "rule": rule})
+}
+
+// DeleteAlertRuleByUser - Menghapus alert rule milik user
+func DeleteAlertRuleByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	ruleID, err := strconv.ParseUint(c.Param("rule_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", uint(ruleID), userID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
+
+// =================== Alerts (User) ===================
+
+// GetAlertsByUser - Mendapatkan alert milik user, bisa difilter ?status=open|acknowledged|resolved
+func GetAlertsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	// ?patient_id= untuk melihat alert pasien yang memberikan akses view_alerts
+	if patientParam := c.Query("patient_id"); patientParam != "" {
+		patientID, err := strconv.Atoi(patientParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid patient ID"})
+			return
+		}
+		if uint(patientID) != userID {
+			if !services.HasPermission(c.GetString("role"), models.PermReadSharedVitals) ||
+				!services.HasActiveGrant(uint(patientID), userID, models.GrantScopeViewAlerts) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to view this patient's alerts"})
+				return
+			}
+			userID = uint(patientID)
+		}
+	}
+	markAuditTarget(c, userID, nil)
+
+	query := database.DB.Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var alerts []models.Alert
+	if err := query.Order("triggered_at DESC").Limit(500).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// AcknowledgeAlertByUser - Menandai alert milik user sebagai sudah diketahui
+func AcknowledgeAlertByUser(c *gin.Context) {
+	updateAlertStatus(c, models.AlertStatusAcknowledged, true)
+}
+
+// ResolveAlertByUser - Menandai alert milik user sebagai selesai
+func ResolveAlertByUser(c *gin.Context) {
+	updateAlertStatus(c, models.AlertStatusResolved, true)
+}
+
+// =================== Alerts (Admin) ===================
+
+// GetAlertRulesAdmin - Mendapatkan alert rule milik user tertentu
+func GetAlertRulesAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var rules []models.AlertRule
+	if err := tenantDB(c).Where("user_id = ?", userID).Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// CreateAlertRuleAdmin - Menambahkan alert rule atas nama user tertentu
+func CreateAlertRuleAdmin(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := tenantDB(c).First(&user, uint(userID)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	createAlertRule(c, user.ID)
+}
+
+// DeleteAlertRuleAdmin - Menghapus alert rule berdasarkan ID
+func DeleteAlertRuleAdmin(c *gin.Context) {
+	ruleID, err := strconv.Atoi(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var rule models.AlertRule
+	if err := tenantDB(c).First(&rule, ruleID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+	markAuditTarget(c, rule.UserID, rule.DeviceID)
+
+	result := database.DB.Delete(&rule)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert rule deleted successfully"})
+}
+
+// GetAllAlertsAdmin - Mendapatkan semua alert, bisa difilter ?status= dan ?user_id=
+func GetAllAlertsAdmin(c *gin.Context) {
+	query := tenantDB(c).Model(&models.Alert{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var alerts []models.Alert
+	if err := query.Order("triggered_at DESC").Limit(500).Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alerts)
+}
+
+// AcknowledgeAlertAdmin - Menandai alert sebagai sudah diketahui oleh admin
+func AcknowledgeAlertAdmin(c *gin.Context) {
+	updateAlertStatus(c, models.AlertStatusAcknowledged, false)
+}
+
+// updateAlertStatus - Mengubah status alert (acknowledged / resolved)
+func updateAlertStatus(c *gin.Context, status string, ownOnly bool) {
+	userID := c.MustGet("user_id").(uint)
+
+	alertID, err := strconv.ParseUint(c.Param("alert_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+		return
+	}
+
+	// Admin dibatasi ke alert pasien di organisasinya, user biasa ke alert miliknya sendiri
+	query := tenantDB(c).Where("id = ?", uint(alertID))
+	if ownOnly {
+		query = database.DB.Where("id = ? AND user_id = ?", uint(alertID), userID)
+	}
+
+	var alert models.Alert
+	if err := query.First(&alert).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	}
+	if alert.Status == models.AlertStatusResolved {
+		c.JSON(http.StatusConflict, gin.H{"error": "Alert is already resolved"})
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	allowedFrom := []string{models.AlertStatusOpen, models.AlertStatusAcknowledged}
+	switch status {
+	case models.AlertStatusAcknowledged:
+		if alert.Status == models.AlertStatusAcknowledged {
+			c.JSON(http.StatusOK, gin.H{"message": "Alert acknowledged", "alert": alert})
+			return
+		}
+		updates["acknowledged_at"] = now
+		updates["acknowledged_by"] = userID
+		allowedFrom = []string{models.AlertStatusOpen}
+	case models.AlertStatusResolved:
+		updates["resolved_at"] = now
+	}
+
+	// Hanya kolom yang berubah, dengan syarat status belum berubah sejak dibaca (misalnya di-resolve otomatis evaluator)
+	result := database.DB.Model(&models.Alert{}).Where("id = ? AND status IN ?", alert.ID, allowedFrom).Updates(updates)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+	if err := database.DB.First(&alert, alert.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		if alert.Status == models.AlertStatusResolved {
+			c.JSON(http.StatusConflict, gin.H{"error": "Alert is already resolved"})
+			return
+		}
+		// Sudah di-acknowledge request lain
+		c.JSON(http.StatusOK, gin.H{"message": "Alert " + alert.Status, "alert": alert})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alert " + status, "alert": alert})
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// queryUintParam - Membaca parameter ID yang opsional dari query string
+func queryUintParam(c *gin.Context, name string) (*uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	parsed := uint(id)
+	return &parsed, nil
+}
+
+// =================== Audit Log (User) ===================
+
+// GetAccessHistoryByUser - Siapa yang mengakses data pasien dan kapan (filter: from, to, limit)
+func GetAccessHistoryByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' timestamp (RFC3339 required)"})
+		return
+	}
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' timestamp (RFC3339 required)"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	records, err := services.AccessHistory(userID, from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accesses": records})
+}
+
+// =================== Audit Log (Admin) ===================
+
+// GetAuditLogsAdmin - Melihat audit log di organisasi admin
+// (filter: actor_id, target_user_id, target_device_id, action, outcome, from, to, limit)
+func GetAuditLogsAdmin(c *gin.Context) {
+	filter := services.AuditFilter{
+		Action:  c.Query("action"),
+		Outcome: c.Query("outcome"),
+	}
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	var err error
+	for name, target := range map[string]**uint{
+		"actor_id":         &filter.ActorID,
+		"target_user_id":   &filter.TargetUserID,
+		"target_device_id": &filter.TargetDeviceID,
+	} {
+		if *target, err = queryUintParam(c, name); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name})
+			return
+		}
+	}
+	if filter.From, err = parseTimeParam(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' timestamp (RFC3339 required)"})
+		return
+	}
+	if filter.To, err = parseTimeParam(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' timestamp (RFC3339 required)"})
+		return
+	}
+
+	// Admin organisasi hanya melihat akses ke data pasien di organisasinya
+	logs, err := services.QueryAuditLogs(tenantDB(c), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"logs": logs})
+}
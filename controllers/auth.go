@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"errors"
+	"log"
 	"net/http"
-	"os"
+	"strconv"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -11,6 +13,7 @@ import (
 
 	database "backend/config"
 	"backend/models"
+	"backend/services"
 )
 
 type Claims struct {
@@ -31,16 +34,38 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Termasuk akun yang dihapus tetapi masih dalam masa tenggang: email & username-nya tetap dipakai
+	// sampai akun di-purge agar akun itu masih bisa dipulihkan
+	var existing int64
+	if err := database.DB.Unscoped().Model(&models.User{}).
+		Where("email = ? OR username = ?", input.Email, input.Username).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Username or email is already registered"})
+		return
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
 		return
 	}
 
+	// User yang mendaftar sendiri masuk organisasi registrasi agar terlihat oleh admin organisasi
+	organizationID, err := services.RegistrationOrganization(database.DB)
+	if err != nil {
+		log.Println("Failed to resolve registration organization:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register"})
+		return
+	}
+
 	user := models.User{
-		Username: input.Username,
-		Password: string(hashedPassword),
-		Email:    input.Email,
+		Username:       input.Username,
+		Password:       string(hashedPassword),
+		Email:          input.Email,
+		OrganizationID: organizationID,
 	}
 
 	if err := database.DB.Create(&user).Error; err != nil {
@@ -48,7 +73,94 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+	// Kirim link verifikasi email (kegagalan kirim tidak membatalkan registrasi, user bisa kirim ulang)
+	if err := services.SendVerificationEmail(user, true); err != nil {
+		log.Println("Failed to send verification email:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User registered successfully. Please check your email to verify your account"})
+}
+
+// VerifyEmail - Endpoint untuk verifikasi email dari link yang dikirim ke user
+func VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	if _, err := services.VerifyEmailToken(token); err != nil {
+		if errors.Is(err, services.ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification link"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
+
+// ResendVerificationEmail - Endpoint untuk mengirim ulang link verifikasi (dibatasi satu kali per beberapa menit)
+func ResendVerificationEmail(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	err := services.SendVerificationEmail(user, false)
+	switch {
+	case errors.Is(err, services.ErrEmailAlreadyVerified):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already verified"})
+	case errors.Is(err, services.ErrVerificationRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Verification email was sent recently, please try again later"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	}
+}
+
+// ForgotPassword - Endpoint untuk meminta link reset password lewat email.
+// Respons selalu sama agar tidak membocorkan apakah email terdaftar.
+func ForgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	services.RequestPasswordReset(input.Email)
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword - Endpoint untuk mengganti password dengan token dari email reset
+func ResetPassword(c *gin.Context) {
+	var input struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	if err := services.ResetPassword(input.Token, input.NewPassword); err != nil {
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully. Please log in again"})
 }
 
 // Login - Endpoint untuk login user
@@ -63,31 +175,201 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Tolak sementara jika username atau IP sedang diblokir karena terlalu banyak gagal
+	ctx := c.Request.Context()
+	if !allowLoginAttempt(c, input.Username) {
+		return
+	}
+
 	var user models.User
 	if err := database.DB.Where("username = ?", input.Username).First(&user).Error; err != nil {
+		services.RecordLoginFailure(ctx, input.Username, c.ClientIP(), c.Request.UserAgent(), nil, "unknown username")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		services.RecordLoginFailure(ctx, input.Username, c.ClientIP(), c.Request.UserAgent(), &user.ID, "wrong password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
 
-	// Tambahkan user_id ke token JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"role":     user.Role,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
+	// Jika 2FA aktif, login dilanjutkan di /login/2fa dengan token sementara
+	if user.TOTPEnabled {
+		mfaToken, err := services.SignMFAToken(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"mfa_token":           mfaToken,
+		})
+		return
+	}
+
+	services.RecordLoginSuccess(ctx, user.Username)
+	respondWithSession(c, user)
+}
+
+// allowLoginAttempt - Mengecek blokir login per username dan IP, mengirim 429 jika masih diblokir
+func allowLoginAttempt(c *gin.Context, username string) bool {
+	retryAfter := services.LoginRetryAfter(c.Request.Context(), username, c.ClientIP())
+	if retryAfter <= 0 {
+		return true
+	}
+	seconds := int(retryAfter.Seconds()) + 1
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many failed login attempts, please try again later",
+		"retry_after": seconds,
 	})
+	return false
+}
+
+// LoginTwoFactor - Langkah kedua login: menukar mfa_token dan kode TOTP / kode cadangan dengan sesi
+func LoginTwoFactor(c *gin.Context) {
+	var input struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	mfaToken, err := services.ParseMFAToken(input.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor login token"})
+		return
+	}
+	user := mfaToken.User
+
+	// Kode 2FA ikut dibatasi agar tidak bisa ditebak dengan brute-force
+	ctx := c.Request.Context()
+	if !allowLoginAttempt(c, user.Username) {
+		return
+	}
+	if err := services.VerifySecondFactor(user, input.Code); err != nil {
+		services.RecordLoginFailure(ctx, user.Username, c.ClientIP(), c.Request.UserAgent(), &user.ID, "invalid two-factor code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+	// Token hanya bisa ditukar sekali, kode salah tidak menghabiskan token
+	if err := services.ConsumeMFAToken(*mfaToken); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired two-factor login token"})
+		return
+	}
+
+	services.RecordLoginSuccess(ctx, user.Username)
+	respondWithSession(c, user)
+}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+// respondWithSession - Membuat sesi baru (access token berumur pendek + refresh token) dan mengirimkannya ke client
+func respondWithSession(c *gin.Context, user models.User) {
+	tokens, err := services.CreateSession(user, c.Request.UserAgent(), c.ClientIP())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	response := gin.H{
+		"token":         tokens.AccessToken, // Tetap dikirim untuk kompatibilitas frontend lama
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	}
+	// Role yang wajib 2FA tapi belum setup hanya bisa mengakses endpoint /api/2fa
+	if !user.TOTPEnabled && services.RoleRequires2FA(user.Role) {
+		response["two_factor_setup_required"] = true
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshToken - Menukar refresh token dengan access token baru (refresh token di-rotasi)
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	tokens, err := services.RefreshSession(input.RefreshToken, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// Logout - Mencabut sesi yang sedang dipakai
+func Logout(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	sessionID := c.MustGet("session_id").(uint)
+
+	if _, err := services.RevokeSession(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// LogoutAll - Mencabut semua sesi milik user (logout dari semua perangkat)
+func LogoutAll(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	if err := services.RevokeAllSessions(database.DB, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
+}
+
+// GetSessionsByUser - Mendapatkan daftar sesi aktif milik user
+func GetSessionsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	sessionID := c.MustGet("session_id").(uint)
+
+	var sessions []models.Session
+	if err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions, "current_session_id": sessionID})
+}
+
+// RevokeSessionByUser - Mencabut sesi tertentu milik user
+func RevokeSessionByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	sessionID, err := strconv.ParseUint(c.Param("session_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	revoked, err := services.RevokeSession(userID, uint(sessionID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
 }
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestRegisterConflictsWithAccountInDeletionGrace(t *testing.T) {
+	db := testdb.Open(t)
+	deleted := models.User{Username: "leaving", Password: "x", Email: "leaving@example.com",
+		DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}
+	if err := db.Create(&deleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/register", Register)
+
+	body := `{"username":"newcomer","password":"secret123","email":"leaving@example.com"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportResponse - Status export beserta link download sementara jika sudah selesai
+type exportResponse struct {
+	models.DataExport
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+// withDownloadURL - Menambahkan link download untuk export yang sudah selesai
+func withDownloadURL(export models.DataExport) (exportResponse, error) {
+	response := exportResponse{DataExport: export}
+	if export.Status == models.ExportCompleted {
+		link, expiresAt, err := services.ExportDownloadURL(export)
+		if err != nil {
+			return response, err
+		}
+		response.DownloadURL = link
+		response.DownloadExpiresAt = &expiresAt
+	}
+	return response, nil
+}
+
+// =================== Data Export (User) ===================
+
+// RequestExportByUser - Meminta export seluruh data user (diproses di background)
+func RequestExportByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	markAuditTarget(c, userID, nil)
+
+	export, err := services.RequestExport(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrExportInProgress) {
+			c.JSON(http.StatusConflict, gin.H{"error": "An export is already in progress"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request export"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Export requested, poll the status until it is completed", "export": export})
+}
+
+// GetExportsByUser - Daftar export milik user
+func GetExportsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var exports []models.DataExport
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Limit(20).Find(&exports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exports"})
+		return
+	}
+
+	result := make([]exportResponse, 0, len(exports))
+	for _, export := range exports {
+		response, err := withDownloadURL(export)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create download link"})
+			return
+		}
+		result = append(result, response)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"exports": result})
+}
+
+// GetExportByUser - Status export, berisi link download sementara jika sudah selesai
+func GetExportByUser(c *gin.Context) {
+	exportID, err := strconv.Atoi(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid export ID"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	var export models.DataExport
+	if err := database.DB.Where("id = ? AND user_id = ?", exportID, userID).First(&export).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export not found"})
+		return
+	}
+
+	response, err := withDownloadURL(export)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create download link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"export": response})
+}
+
+// DownloadExport - Mengunduh file export lewat link sementara (?token=), tanpa header Authorization
+func DownloadExport(c *gin.Context) {
+	export, err := services.ExportForDownload(c.Query("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrExportNotReady) {
+			c.JSON(http.StatusGone, gin.H{"error": "Export is no longer available, please request a new one"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+	markAuditTarget(c, export.UserID, nil)
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="health-data-export-%d.zip"`, export.ID))
+	c.Header("Content-Length", strconv.FormatInt(export.SizeBytes, 10))
+	c.Header("Content-Type", "application/zip")
+	c.Status(http.StatusOK)
+	if err := services.WriteExportTo(c.Writer, *export); err != nil {
+		// Header sudah terkirim; body lebih pendek dari Content-Length sehingga client tahu file tidak lengkap
+		log.Printf("Failed to send export %d: %v", export.ID, err)
+	}
+}
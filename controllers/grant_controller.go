@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =================== Access Grants (Pasien berbagi akses) ===================
+
+// GetGrantsByUser - Daftar akses yang diberikan pasien ke caregiver / clinician
+func GetGrantsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var grants []models.AccessGrant
+	if err := database.DB.Where("patient_id = ?", userID).Order("created_at DESC").Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access grants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grants": grants})
+}
+
+// AddGrantByUser - Pasien mengundang user lain lewat email dengan cakupan akses dan masa berlaku opsional
+func AddGrantByUser(c *gin.Context) {
+	var input struct {
+		Email     string     `json:"email" binding:"required,email"`
+		Scope     string     `json:"scope" binding:"required"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	patient, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	grant, err := services.InviteGrantee(*patient, input.Email, input.Scope, input.ExpiresAt)
+	switch {
+	case errors.Is(err, services.ErrGrantInvalidScope):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be one of view_vitals, view_alerts, manage_devices, edit_medical_history"})
+	case errors.Is(err, services.ErrGrantSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot share access with yourself"})
+	case errors.Is(err, services.ErrGrantExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "An active or pending grant already exists for this email"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create access grant"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation sent", "grant": grant})
+	}
+}
+
+// RevokeGrantByUser - Mencabut akses (oleh pasien, atau grantee yang tidak ingin lagi menerima akses)
+func RevokeGrantByUser(c *gin.Context) {
+	grantID, err := strconv.Atoi(c.Param("grant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grant ID"})
+		return
+	}
+
+	userID := c.MustGet("user_id").(uint)
+	if err := services.RevokeGrant(uint(grantID), userID, c.GetString("email")); err != nil {
+		if errors.Is(err, services.ErrGrantNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Access grant not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke access grant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Access revoked"})
+}
+
+// GetReceivedGrantsByUser - Undangan dan akses yang diterima user dari pasien lain
+func GetReceivedGrantsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	email := strings.ToLower(c.GetString("email"))
+
+	var grants []models.AccessGrant
+	err := database.DB.Where("grantee_id = ? OR (grantee_email = ? AND status = ?)", userID, email, models.GrantPending).
+		Order("created_at DESC").Find(&grants).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch access grants"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grants": grants})
+}
+
+// AcceptGrantByUser - Menerima undangan akses yang dikirim ke email user
+func AcceptGrantByUser(c *gin.Context) {
+	grantID, err := strconv.Atoi(c.Param("grant_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grant ID"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	grant, err := services.AcceptGrant(uint(grantID), *user, user.Role)
+	switch {
+	case errors.Is(err, services.ErrGrantNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only caregiver or clinician accounts can accept shared access"})
+	case errors.Is(err, services.ErrGrantUnverified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Please verify your email before accepting shared access"})
+	case errors.Is(err, services.ErrGrantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invitation"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted", "grant": grant})
+	}
+}
@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/notifier"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// generateWebhookSecret - Membuat secret acak untuk tanda tangan webhook
+func generateWebhookSecret() string {
+	bytes := make([]byte, 32)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// GetNotificationChannelsByUser - Mendapatkan semua channel notifikasi milik user
+func GetNotificationChannelsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var channels []models.NotificationChannel
+	if err := database.DB.Where("user_id = ?", userID).Find(&channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification channels"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"channels": channels})
+}
+
+// AddNotificationChannelByUser - Menambahkan channel notifikasi (email, webhook, push)
+func AddNotificationChannelByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var input struct {
+		Type   string `json:"type" binding:"required"`
+		Target string `json:"target" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	channel := models.NotificationChannel{UserID: userID, Type: input.Type, Target: input.Target, Enabled: true}
+
+	// Validasi target sesuai jenis channel
+	switch input.Type {
+	case models.ChannelEmail:
+		if _, err := mail.ParseAddress(input.Target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+			return
+		}
+	case models.ChannelWebhook:
+		// Tolak alamat internal (metadata cloud, localhost, jaringan privat); dicek lagi saat dikirim
+		if err := notifier.ValidateWebhookURL(c.Request.Context(), input.Target); err != nil {
+			if errors.Is(err, notifier.ErrForbiddenAddress) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL must point to a public address"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook URL"})
+			return
+		}
+		channel.Secret = generateWebhookSecret()
+	case models.ChannelPush:
+		// Token push dari aplikasi, tidak ada format khusus
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Type must be one of email, webhook, push"})
+		return
+	}
+
+	if err := database.DB.Create(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create notification channel"})
+		return
+	}
+
+	response := gin.H{"message": "Notification channel added successfully", "channel": channel}
+	if channel.Type == models.ChannelWebhook {
+		// Secret hanya ditampilkan sekali, dipakai penerima untuk memverifikasi X-Signature
+		response["secret"] = channel.Secret
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateNotificationChannelByUser - Mengaktifkan / menonaktifkan channel notifikasi
+func UpdateNotificationChannelByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	channelID, err := strconv.ParseUint(c.Param("channel_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	var input struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	var channel models.NotificationChannel
+	if err := database.DB.Where("id = ? AND user_id = ?", uint(channelID), userID).First(&channel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification channel not found"})
+		return
+	}
+
+	channel.Enabled = *input.Enabled
+	if err := database.DB.Save(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification channel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel updated successfully", "channel": channel})
+}
+
+// DeleteNotificationChannelByUser - Menghapus channel notifikasi milik user
+func DeleteNotificationChannelByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	channelID, err := strconv.ParseUint(c.Param("channel_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", uint(channelID), userID).Delete(&models.NotificationChannel{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notification channel"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification channel not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification channel deleted successfully"})
+}
+
+// GetNotificationSettingsByUser - Mendapatkan preferensi notifikasi (quiet hours)
+func GetNotificationSettingsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	setting := models.NotificationSetting{
+		UserID:             userID,
+		QuietHoursStart:    "22:00",
+		QuietHoursEnd:      "07:00",
+		Timezone:           "UTC",
+		CriticalBypassesQH: true,
+	}
+	if err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": setting})
+}
+
+// UpdateNotificationSettingsByUser - Mengubah preferensi notifikasi (quiet hours)
+func UpdateNotificationSettingsByUser(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+
+	var input struct {
+		QuietHoursEnabled  *bool   `json:"quiet_hours_enabled"`
+		QuietHoursStart    *string `json:"quiet_hours_start"`
+		QuietHoursEnd      *string `json:"quiet_hours_end"`
+		Timezone           *string `json:"timezone"`
+		CriticalBypassesQH *bool   `json:"critical_bypasses_quiet_hours"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	setting := models.NotificationSetting{
+		UserID:             userID,
+		QuietHoursStart:    "22:00",
+		QuietHoursEnd:      "07:00",
+		Timezone:           "UTC",
+		CriticalBypassesQH: true,
+	}
+	if err := database.DB.Where("user_id = ?", userID).Limit(1).Find(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification settings"})
+		return
+	}
+
+	// Update hanya field yang dikirim
+	if input.QuietHoursEnabled != nil {
+		setting.QuietHoursEnabled = *input.QuietHoursEnabled
+	}
+	if input.QuietHoursStart != nil {
+		if _, err := services.ParseClock(*input.QuietHoursStart); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiet_hours_start (HH:MM required)"})
+			return
+		}
+		setting.QuietHoursStart = *input.QuietHoursStart
+	}
+	if input.QuietHoursEnd != nil {
+		if _, err := services.ParseClock(*input.QuietHoursEnd); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quiet_hours_end (HH:MM required)"})
+			return
+		}
+		setting.QuietHoursEnd = *input.QuietHoursEnd
+	}
+	if input.Timezone != nil {
+		if _, err := time.LoadLocation(*input.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+			return
+		}
+		setting.Timezone = *input.Timezone
+	}
+	if input.CriticalBypassesQH != nil {
+		setting.CriticalBypassesQH = *input.CriticalBypassesQH
+	}
+
+	if err := database.DB.Save(&setting).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification settings updated successfully", "settings": setting})
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =================== Organizations (Super Admin) ===================
+
+// GetOrganizationsAdmin - Daftar organisasi beserta jumlah user dan device
+func GetOrganizationsAdmin(c *gin.Context) {
+	type organizationSummary struct {
+		models.Organization
+		Users   int64 `json:"users"`
+		Devices int64 `json:"devices"`
+	}
+
+	var organizations []models.Organization
+	if err := database.DB.Order("name").Find(&organizations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	result := make([]organizationSummary, 0, len(organizations))
+	for _, org := range organizations {
+		summary := organizationSummary{Organization: org}
+		database.DB.Model(&models.User{}).Where("organization_id = ?", org.ID).Count(&summary.Users)
+		database.DB.Model(&models.Device{}).Where("organization_id = ?", org.ID).Count(&summary.Devices)
+		result = append(result, summary)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"organizations": result})
+}
+
+// CreateOrganizationAdmin - Menambahkan organisasi (klinik) baru
+func CreateOrganizationAdmin(c *gin.Context) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	org := models.Organization{Name: strings.TrimSpace(input.Name)}
+	if err := database.DB.Create(&org).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization name already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization created successfully", "organization": org})
+}
+
+// UpdateOrganizationAdmin - Mengubah nama organisasi
+func UpdateOrganizationAdmin(c *gin.Context) {
+	orgID, err := strconv.ParseUint(c.Param("org_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || strings.TrimSpace(input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	org, err := services.FindOrganization(uint(orgID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	org.Name = strings.TrimSpace(input.Name)
+	if err := database.DB.Save(org).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization name already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization updated successfully", "organization": org})
+}
+
+// DeleteOrganizationAdmin - Menghapus organisasi yang sudah tidak memiliki user
+func DeleteOrganizationAdmin(c *gin.Context) {
+	orgID, err := strconv.ParseUint(c.Param("org_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+		return
+	}
+
+	err = services.DeleteOrganization(uint(orgID))
+	switch {
+	case errors.Is(err, services.ErrOrganizationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrOrganizationNotEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": "Move or delete the users of this organization first"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+	}
+}
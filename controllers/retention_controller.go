@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// =================== Retensi Data Sensor ===================
+
+// retentionInput - Input policy retensi (hari, 0 = selamanya untuk ringkasan)
+type retentionInput struct {
+	RawDays    int `json:"raw_days" binding:"required"`
+	HourlyDays int `json:"hourly_days"`
+	DailyDays  int `json:"daily_days"`
+}
+
+// retentionOwner - Organisasi atau user pemilik policy dari parameter URL, dibatasi ke tenant admin
+func retentionOwner(c *gin.Context) (organizationID, userID *uint, ok bool) {
+	if value := c.Param("org_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization ID"})
+			return nil, nil, false
+		}
+		orgID := uint(id)
+		if !services.InTenant(tenantID(c), &orgID) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return nil, nil, false
+		}
+		if _, err := services.FindOrganization(orgID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return nil, nil, false
+		}
+		return &orgID, nil, true
+	}
+
+	id, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return nil, nil, false
+	}
+	var user models.User
+	if err := tenantDB(c).Select("id").First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, nil, false
+	}
+	return nil, &user.ID, true
+}
+
+// GetRetentionPolicyAdmin - Policy retensi organisasi / user beserta retensi yang berlaku
+func GetRetentionPolicyAdmin(c *gin.Context) {
+	organizationID, userID, ok := retentionOwner(c)
+	if !ok {
+		return
+	}
+
+	policy, err := services.FindRetentionPolicy(organizationID, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve retention policy"})
+		return
+	}
+
+	// User tanpa policy sendiri mengikuti policy organisasinya
+	if userID != nil {
+		var user models.User
+		database.DB.Select("id, organization_id").First(&user, *userID)
+		organizationID = user.OrganizationID
+	}
+	effective := services.EffectiveRetention(userID, organizationID)
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "effective": effective})
+}
+
+// SetRetentionPolicyAdmin - Membuat atau mengubah policy retensi organisasi / user
+func SetRetentionPolicyAdmin(c *gin.Context) {
+	organizationID, userID, ok := retentionOwner(c)
+	if !ok {
+		return
+	}
+
+	var input retentionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	policy, err := services.SetRetentionPolicy(organizationID, userID, services.RetentionSettings{
+		RawDays:    input.RawDays,
+		HourlyDays: input.HourlyDays,
+		DailyDays:  input.DailyDays,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRetention) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save retention policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retention policy saved successfully", "policy": policy})
+}
+
+// DeleteRetentionPolicyAdmin - Menghapus policy retensi sehingga kembali ke policy organisasi / default
+func DeleteRetentionPolicyAdmin(c *gin.Context) {
+	organizationID, userID, ok := retentionOwner(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := services.DeleteRetentionPolicy(organizationID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete retention policy"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Retention policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retention policy deleted successfully"})
+}
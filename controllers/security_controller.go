@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/fieldcrypt"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =================== Lockout & Security Log (Admin) ===================
+
+// GetLockoutsAdmin - Melihat username / IP yang sedang diblokir karena login gagal atau rate limit
+func GetLockoutsAdmin(c *gin.Context) {
+	lockouts, err := services.Lockouts(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// ClearLockoutAdmin - Membuka blokir berdasarkan key (contoh: login:user:alice, login:ip:10.0.0.1)
+func ClearLockoutAdmin(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+
+	adminID := c.MustGet("user_id").(uint)
+	if err := services.ClearLockout(c.Request.Context(), key, adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+}
+
+// GetSecurityEventsAdmin - Melihat security log (filter: type, username, limit)
+func GetSecurityEventsAdmin(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	events, err := services.SecurityEvents(c.Query("type"), c.Query("username"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// =================== Field Encryption (Admin) ===================
+
+// GetEncryptionStatusAdmin - Jumlah data sensitif per KEK (untuk memastikan rotasi key sudah selesai)
+func GetEncryptionStatusAdmin(c *gin.Context) {
+	activeKey, err := fieldcrypt.ActiveKeyID()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Field encryption is not configured"})
+		return
+	}
+
+	status, err := services.FieldEncryptionStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch encryption status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"active_key_id": activeKey, "columns": status})
+}
+
+// GetSensorStorageAdmin - Mode penyimpanan sensor_data (plain, timescale, partitioned) beserta partisi / chunk
+func GetSensorStorageAdmin(c *gin.Context) {
+	partitions, err := services.SensorPartitions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sensor storage status"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode":                  services.SensorStorageMode(),
+		"continuous_aggregates": services.SensorAggregatesReady(),
+		"partitions":            partitions,
+	})
+}
+
+// RotateEncryptionKeysAdmin - Membungkus ulang data key dengan KEK aktif dan mengenkripsi data plaintext lama
+func RotateEncryptionKeysAdmin(c *gin.Context) {
+	encrypted, rewrapped, err := services.RotateFieldEncryption()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate encryption keys", "encrypted": encrypted, "rewrapped": rewrapped})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Encryption keys rotated", "encrypted": encrypted, "rewrapped": rewrapped})
+}
@@ -0,0 +1,376 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Interval agregasi yang didukung
+var aggregateIntervals = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// Batas jumlah bucket / titik dalam satu respons
+const (
+	maxAggregateBuckets = 5000
+	defaultLTTBPoints   = 500
+	maxLTTBPoints       = 5000
+)
+
+// Kolom yang boleh dipakai untuk downsampling LTTB
+var lttbMetrics = map[string]string{
+	"bpm":  "bpm",
+	"spo2": "sp_o2",
+	"temp": "temp",
+}
+
+// VitalStats - Statistik satu jenis vital dalam satu bucket
+type VitalStats struct {
+	Min    float64  `json:"min"`
+	Max    float64  `json:"max"`
+	Mean   float64  `json:"mean"`
+	Median *float64 `json:"median"` // nil jika ringkasan sudah digabung dengan data terlambat (median tidak diketahui)
+}
+
+// SensorBucket - Hasil agregasi data sensor dalam satu interval waktu
+type SensorBucket struct {
+	BucketStart time.Time  `json:"bucket_start"`
+	Count       int64      `json:"count"`
+	BPM         VitalStats `json:"bpm"`
+	SpO2        VitalStats `json:"spo2"`
+	Temp        VitalStats `json:"temp"`
+}
+
+// aggregateRow - Hasil mentah query agregasi dari database
+type aggregateRow struct {
+	BucketStart time.Time
+	Count       int64
+	BPMMin      float64
+	BPMMax      float64
+	BPMMean     float64
+	BPMMedian   float64
+	SpO2Min     float64
+	SpO2Max     float64
+	SpO2Mean    float64
+	SpO2Median  float64
+	TempMin     float64
+	TempMax     float64
+	TempMean    float64
+	TempMedian  float64
+}
+
+// DownsampledPoint - Satu titik hasil downsampling LTTB
+type DownsampledPoint struct {
+	ID        uint      `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// GetSensorAggregate - Mendapatkan agregasi (min/max/mean/median/count) data sensor per interval
+// Query parameter: interval (1m|5m|1h|1d), from, to (RFC3339), mode (lttb), metric, max_points
+func GetSensorAggregate(c *gin.Context) {
+	// Ambil ID perangkat dari parameter URL dan konversi ke uint
+	deviceID, err := strconv.Atoi(c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+
+	// Pastikan user boleh membaca data sensor device ini
+	device, ok := authorizeVitalsAccess(c, uint(deviceID))
+	if !ok {
+		return
+	}
+
+	// Rentang waktu, default 24 jam terakhir
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' timestamp (RFC3339 required)"})
+		return
+	}
+	if to == nil {
+		now := time.Now()
+		to = &now
+	}
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' timestamp (RFC3339 required)"})
+		return
+	}
+	if from == nil {
+		start := to.Add(-24 * time.Hour)
+		from = &start
+	}
+	if !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+
+	if c.Query("mode") == "lttb" {
+		respondSensorLTTB(c, device, *from, *to)
+		return
+	}
+
+	interval, ok := aggregateIntervals[c.DefaultQuery("interval", "1h")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Interval must be one of 1m, 5m, 1h, 1d"})
+		return
+	}
+	if to.Sub(*from)/interval > maxAggregateBuckets {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time range too large for this interval"})
+		return
+	}
+
+	// Rentang sebelum raw_retained_from dibaca dari tabel ringkasan karena data mentahnya sudah dihapus retensi.
+	// Interval di bawah 1 jam memakai ringkasan per jam untuk rentang tersebut (per hari jika sudah dihapus).
+	buckets := []SensorBucket{}
+	rawFrom := *from
+	if device.RawRetainedFrom != nil && from.Before(*device.RawRetainedFrom) {
+		rollupTo := *to
+		if device.RawRetainedFrom.Before(rollupTo) {
+			rollupTo = *device.RawRetainedFrom
+		}
+
+		rollups, err := services.SensorRollups(*device, interval, *from, rollupTo)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate sensor data"})
+			return
+		}
+		for _, r := range rollups {
+			buckets = append(buckets, SensorBucket{
+				BucketStart: r.BucketStart,
+				Count:       r.Count,
+				BPM:         VitalStats{Min: r.BPMMin, Max: r.BPMMax, Mean: r.BPMSum / float64(r.Count), Median: r.BPMMedian},
+				SpO2:        VitalStats{Min: r.SpO2Min, Max: r.SpO2Max, Mean: r.SpO2Sum / float64(r.Count), Median: r.SpO2Median},
+				Temp:        VitalStats{Min: r.TempMin, Max: r.TempMax, Mean: r.TempSum / float64(r.Count), Median: r.TempMedian},
+			})
+		}
+		rawFrom = rollupTo
+	}
+
+	// Agregasi dihitung di database, bucket = floor(epoch / interval) * interval.
+	// Pada mode TimescaleDB interval 1h / 1d dibaca dari continuous aggregate.
+	seconds := int64(interval / time.Second)
+	var rows []aggregateRow
+	if view := services.SensorAggregateView(interval); view != "" {
+		err = database.DB.Table(view).
+			Where("device_id = ? AND bucket_start >= ? AND bucket_start < ?", deviceID, rawFrom.Truncate(interval), *to).
+			Order("bucket_start").Scan(&rows).Error
+	} else {
+		err = database.DB.Raw(`
+		SELECT to_timestamp(floor(extract(epoch FROM timestamp) / ?) * ?) AS bucket_start,
+			COUNT(*) AS count,
+			MIN(bpm) AS bpm_min, MAX(bpm) AS bpm_max, AVG(bpm) AS bpm_mean,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY bpm) AS bpm_median,
+			MIN(sp_o2) AS sp_o2_min, MAX(sp_o2) AS sp_o2_max, AVG(sp_o2) AS sp_o2_mean,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY sp_o2) AS sp_o2_median,
+			MIN(temp) AS temp_min, MAX(temp) AS temp_max, AVG(temp) AS temp_mean,
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY temp) AS temp_median
+		FROM sensor_data
+		WHERE device_id = ? AND timestamp >= ? AND timestamp < ?
+		GROUP BY 1
+		ORDER BY 1`, seconds, seconds, deviceID, rawFrom, *to).Scan(&rows).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to aggregate sensor data"})
+		return
+	}
+
+	for _, row := range rows {
+		buckets = append(buckets, SensorBucket{
+			BucketStart: row.BucketStart,
+			Count:       row.Count,
+			BPM:         VitalStats{Min: row.BPMMin, Max: row.BPMMax, Mean: row.BPMMean, Median: &row.BPMMedian},
+			SpO2:        VitalStats{Min: row.SpO2Min, Max: row.SpO2Max, Mean: row.SpO2Mean, Median: &row.SpO2Median},
+			Temp:        VitalStats{Min: row.TempMin, Max: row.TempMax, Mean: row.TempMean, Median: &row.TempMedian},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id":         deviceID,
+		"interval":          c.DefaultQuery("interval", "1h"),
+		"from":              from,
+		"to":                to,
+		"buckets":           buckets,
+		"raw_retained_from": device.RawRetainedFrom,
+	})
+}
+
+// lttbBucketAverage - Rata-rata (x = epoch detik, y = nilai metrik) satu bucket LTTB
+type lttbBucketAverage struct {
+	Bucket int
+	AvgX   float64
+	AvgY   float64
+}
+
+// lttbBucketAverages - Rata-rata setiap bucket LTTB dihitung di database. Data ke-rn (1-based, tanpa
+// titik pertama & terakhir) masuk ke bucket floor((rn-2)/every); every bisa pecahan sehingga dibagi
+// sebagai float8, bukan bigint.
+func lttbBucketAverages(device *models.Device, column string, from, to time.Time, every float64, bucketCount int, total int64) ([]lttbBucketAverage, error) {
+	var rows []lttbBucketAverage
+	err := database.DB.Raw(`
+		SELECT LEAST(FLOOR((rn - 2) / ?::float8), ?)::int AS bucket,
+			AVG(extract(epoch FROM timestamp)) AS avg_x, AVG(`+column+`) AS avg_y
+		FROM (
+			SELECT timestamp, `+column+`, ROW_NUMBER() OVER (ORDER BY timestamp, id) AS rn
+			FROM (?) series
+			WHERE timestamp >= ? AND timestamp < ?
+		) s
+		WHERE rn > 1 AND rn < ?
+		GROUP BY 1
+		ORDER BY 1`, every, bucketCount-1, services.SensorSeries(*device), from, to, total).Scan(&rows).Error
+	return rows, err
+}
+
+// respondSensorLTTB - Downsampling Largest-Triangle-Three-Buckets untuk satu metrik.
+// Rata-rata per bucket dihitung di database, lalu data mentah dibaca secara streaming
+// sehingga memori yang dipakai sebanding dengan jumlah titik hasil, bukan jumlah data.
+// Untuk rentang yang sudah terkena retensi, rata-rata per jam dipakai sebagai data.
+func respondSensorLTTB(c *gin.Context, device *models.Device, from, to time.Time) {
+	metric := c.DefaultQuery("metric", "bpm")
+	column, ok := lttbMetrics[metric]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Metric must be one of bpm, spo2, temp"})
+		return
+	}
+
+	threshold := defaultLTTBPoints
+	if value := c.Query("max_points"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 3 || parsed > maxLTTBPoints {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_points must be between 3 and 5000"})
+			return
+		}
+		threshold = parsed
+	}
+
+	base := services.SensorSeries(*device).Where("timestamp >= ? AND timestamp < ?", from, to)
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+		return
+	}
+
+	// Bucket ke-i berisi data ke-j (0-based, tanpa titik pertama & terakhir) dengan floor((j-1)/every) = i
+	bucketCount := threshold - 2
+	every := float64(total-2) / float64(bucketCount)
+
+	// Rata-rata (x, y) setiap bucket, dipakai sebagai titik ketiga segitiga
+	averages := make([]struct{ X, Y float64 }, bucketCount)
+	var lastPoint DownsampledPoint
+	if total > int64(threshold) {
+		err := base.Session(&gorm.Session{}).Select("id, timestamp, " + column + " AS value").
+			Order("timestamp DESC, id DESC").Limit(1).Scan(&lastPoint).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+			return
+		}
+
+		rows, err := lttbBucketAverages(device, column, from, to, every, bucketCount, total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+			return
+		}
+		for _, row := range rows {
+			averages[row.Bucket].X = row.AvgX
+			averages[row.Bucket].Y = row.AvgY
+		}
+	}
+
+	dbRows, err := base.Session(&gorm.Session{}).Select("id, timestamp, " + column + " AS value").
+		Order("timestamp, id").Rows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+		return
+	}
+	defer dbRows.Close()
+
+	points := []DownsampledPoint{}
+	var selected DownsampledPoint // Titik terpilih terakhir (titik A)
+	var candidate DownsampledPoint
+	bestArea := -1.0
+	currentBucket := 0
+
+	for j := int64(0); dbRows.Next(); j++ {
+		var point DownsampledPoint
+		if err := dbRows.Scan(&point.ID, &point.Timestamp, &point.Value); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+			return
+		}
+
+		// Data sedikit: kirim semua tanpa downsampling
+		if total <= int64(threshold) {
+			points = append(points, point)
+			continue
+		}
+		if j == 0 {
+			selected = point
+			points = append(points, point)
+			continue
+		}
+		if j == total-1 {
+			break
+		}
+
+		bucket := int(math.Floor(float64(j-1) / every))
+		if bucket > bucketCount-1 {
+			bucket = bucketCount - 1
+		}
+		if bucket != currentBucket {
+			// Bucket sebelumnya selesai, simpan titik dengan segitiga terbesar
+			points = append(points, candidate)
+			selected = candidate
+			currentBucket = bucket
+			bestArea = -1
+		}
+
+		// Titik C: rata-rata bucket berikutnya, atau titik terakhir untuk bucket terakhir
+		var nextX, nextY float64
+		if bucket+1 < bucketCount {
+			nextX, nextY = averages[bucket+1].X, averages[bucket+1].Y
+		} else {
+			nextX, nextY = float64(lastPoint.Timestamp.UnixNano())/1e9, lastPoint.Value
+		}
+
+		ax, ay := float64(selected.Timestamp.UnixNano())/1e9, selected.Value
+		bx, by := float64(point.Timestamp.UnixNano())/1e9, point.Value
+		area := math.Abs((ax-nextX)*(by-ay)-(ax-bx)*(nextY-ay)) / 2
+		if area > bestArea {
+			bestArea = area
+			candidate = point
+		}
+	}
+	// Error di tengah pembacaan menghentikan loop lebih awal, jangan kirim series yang terpotong
+	if err := dbRows.Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to downsample sensor data"})
+		return
+	}
+
+	if total > int64(threshold) {
+		points = append(points, candidate, lastPoint)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"device_id":  device.ID,
+		"mode":       "lttb",
+		"metric":     metric,
+		"from":       from,
+		"to":         to,
+		"total":      total,
+		"max_points": threshold,
+		"points":     points,
+	})
+}
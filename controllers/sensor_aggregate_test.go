@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestLTTBBucketAveragesFractionalEvery(t *testing.T) {
+	db := testdb.Open(t)
+
+	user := models.User{Username: "lttb", Password: "x", Email: "lttb@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	device := models.Device{UserID: user.ID, Name: "lttb"}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 10 data dengan bpm = urutan (0..9), titik pertama & terakhir tidak masuk bucket
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for j := 0; j < 10; j++ {
+		reading := models.SensorData{DeviceID: device.ID, BPM: float64(j), Timestamp: start.Add(time.Duration(j) * time.Minute)}
+		if err := db.Create(&reading).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 5 titik -> 3 bucket untuk 8 data tengah, every = 8/3 (bukan bilangan bulat)
+	bucketCount := 3
+	total := int64(10)
+	every := float64(total-2) / float64(bucketCount)
+
+	rows, err := lttbBucketAverages(&device, "bpm", start, start.Add(time.Hour), every, bucketCount, total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != bucketCount {
+		t.Fatalf("expected %d buckets, got %d: %+v", bucketCount, len(rows), rows)
+	}
+
+	// Bucket sama dengan perhitungan di Go: floor((j-1)/every) -> {1,2,3}, {4,5,6}, {7,8}
+	expected := []float64{2, 5, 7.5}
+	for i, row := range rows {
+		if row.Bucket != i || math.Abs(row.AvgY-expected[i]) > 1e-9 {
+			t.Fatalf("bucket %d: expected avg %.2f, got %+v", i, expected[i], row)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"backend/models"
 	"backend/config"
 	"time"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"backend/services"
 )
 
 // AddSensorData - ESP32 mengirim data sensor ke API
@@ -18,9 +21,12 @@ func AddSensorDataByAPI(c *gin.Context) {
 	}
 
 	var input struct {
-		BPM  float64 `json:"bpm" binding:"required"`
-		SpO2 float64 `json:"spo2" binding:"required"`
-		Temp float64 `json:"temp" binding:"required"`
+		BPM       float64 `json:"bpm" binding:"required"`
+		SpO2      float64 `json:"spo2" binding:"required"`
+		Temp      float64 `json:"temp" binding:"required"`
+		ReadingID *string `json:"reading_id"`
+		Seq       *int64  `json:"seq"`
+		BootID    *string `json:"boot_id"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -28,6 +34,15 @@ func AddSensorDataByAPI(c *gin.Context) {
 		return
 	}
 
+	// Idempotency key boleh dikirim lewat header maupun body
+	if key := c.GetHeader("Idempotency-Key"); key != "" && input.ReadingID == nil {
+		input.ReadingID = &key
+	}
+	if msg := services.ValidateReadingIdentity(input.ReadingID, input.BootID, input.Seq); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
 	// Simpan data sensor dengan device_id dari context
 	sensorData := models.SensorData{
 		DeviceID:  deviceID.(uint),
@@ -35,14 +50,187 @@ func AddSensorDataByAPI(c *gin.Context) {
 		SpO2:      input.SpO2,
 		Temp:      input.Temp,
 		Timestamp: time.Now(),
+		ReadingID: input.ReadingID,
+		Seq:       input.Seq,
+		BootID:    input.BootID,
 	}
 
-	if err := database.DB.Create(&sensorData).Error; err != nil {
+	duplicate, missed, err := services.IngestSensorData(database.DB, &sensorData)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add sensor data"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Sensor data added successfully"})
+	// Pengiriman ulang mengembalikan hasil yang sama tanpa membuat data baru
+	if duplicate {
+		c.JSON(http.StatusOK, gin.H{"message": "Sensor data added successfully", "id": sensorData.ID, "duplicate": true})
+		return
+	}
+
+	response := gin.H{"message": "Sensor data added successfully", "id": sensorData.ID}
+	if sensorData.ID == 0 {
+		response["folded"] = true // Digabung ke ringkasan hari yang sudah diringkas retensi
+	}
+	if missed > 0 {
+		response["missed_readings"] = missed
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Batas jumlah data dalam satu batch upload
+const maxSensorBatchSize = 500
+
+// SensorReadingInput - Satu data sensor yang dikirim perangkat dalam batch
+type SensorReadingInput struct {
+	BPM       *float64   `json:"bpm"`
+	SpO2      *float64   `json:"spo2"`
+	Temp      *float64   `json:"temp"`
+	Timestamp *time.Time `json:"timestamp"` // Waktu pengukuran di perangkat (RFC3339)
+	ReadingID *string    `json:"reading_id"`
+	Seq       *int64     `json:"seq"`
+	BootID    *string    `json:"boot_id"` // Berubah setiap perangkat boot, seq dimulai ulang
+}
+
+// SensorBatchResult - Hasil per item dari batch upload
+type SensorBatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"` // "accepted", "duplicate", "folded" (digabung ke ringkasan) atau "rejected"
+	ID     uint   `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Missed int64  `json:"missed_readings,omitempty"` // Nomor urut yang terlewat sebelum data ini
+}
+
+// validateSensorReading - Memeriksa satu data sensor dari batch
+func validateSensorReading(input SensorReadingInput, now time.Time) string {
+	if input.BPM == nil || input.SpO2 == nil || input.Temp == nil {
+		return "bpm, spo2 and temp are required"
+	}
+	if input.Timestamp == nil || input.Timestamp.IsZero() {
+		return "timestamp is required"
+	}
+	if msg := services.ValidateReadingTimestamp(*input.Timestamp, now); msg != "" {
+		return msg
+	}
+	return services.ValidateReadingIdentity(input.ReadingID, input.BootID, input.Seq)
+}
+
+// AddSensorDataBatchByAPI - ESP32 mengirim banyak data sensor yang di-buffer saat offline
+func AddSensorDataBatchByAPI(c *gin.Context) {
+	// Ambil device_id dari context (sudah divalidasi di middleware)
+	deviceID, exists := c.Get("device_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var input struct {
+		Readings []SensorReadingInput `json:"readings" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Readings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Readings must not be empty"})
+		return
+	}
+	if len(input.Readings) > maxSensorBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Batch size exceeds limit of %d readings", maxSensorBatchSize)})
+		return
+	}
+
+	now := time.Now()
+	results := make([]SensorBatchResult, len(input.Readings))
+	accepted, duplicates, folded := 0, 0, 0
+	var missedTotal int64
+	var stored []models.SensorData
+
+	// Simpan semua data yang valid dalam satu transaksi
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for i, reading := range input.Readings {
+			results[i] = SensorBatchResult{Index: i}
+
+			if msg := validateSensorReading(reading, now); msg != "" {
+				results[i].Status = "rejected"
+				results[i].Error = msg
+				continue
+			}
+
+			sensorData := models.SensorData{
+				DeviceID:  deviceID.(uint),
+				BPM:       *reading.BPM,
+				SpO2:      *reading.SpO2,
+				Temp:      *reading.Temp,
+				Timestamp: *reading.Timestamp,
+				ReadingID: reading.ReadingID,
+				Seq:       reading.Seq,
+				BootID:    reading.BootID,
+			}
+
+			// Deteksi seq yang terlewat sama seperti pengiriman satu per satu (HTTP & MQTT)
+			var missed int64
+			if sensorData.Seq != nil {
+				var err error
+				if missed, err = services.CountSkippedSequence(tx, sensorData.DeviceID, sensorData.BootID, *sensorData.Seq); err != nil {
+					return err
+				}
+			}
+
+			duplicate, err := services.StoreSensorData(tx, &sensorData)
+			if err != nil {
+				return err
+			}
+
+			results[i].ID = sensorData.ID
+			if duplicate {
+				results[i].Status = "duplicate"
+				duplicates++
+				continue
+			}
+			// Data terlambat untuk hari yang sudah diringkas retensi hanya digabung ke ringkasan (tanpa ID),
+			// tidak dikirim ke stream live dan tidak dievaluasi alert
+			if sensorData.ID == 0 {
+				results[i].Status = "folded"
+				results[i].Missed = missed
+				missedTotal += missed
+				folded++
+				continue
+			}
+			results[i].Status = "accepted"
+			results[i].Missed = missed
+			missedTotal += missed
+			stored = append(stored, sensorData)
+			accepted++
+		}
+		return nil
+	})
+
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add sensor data"})
+		return
+	}
+
+	// Kirim data baru ke subscriber live dan evaluasi alert setelah transaksi commit
+	for _, sensorData := range stored {
+		services.SensorHub.Publish(sensorData)
+	}
+	services.EvaluateAlertsForReadings(deviceID.(uint), stored)
+	services.RefreshSensorAggregates(stored)
+
+	response := gin.H{
+		"message":    "Sensor data batch processed",
+		"accepted":   accepted,
+		"duplicates": duplicates,
+		"folded":     folded,
+		"rejected":   len(input.Readings) - accepted - duplicates - folded,
+		"results":    results,
+	}
+	if missedTotal > 0 {
+		response["missed_readings"] = missedTotal
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 func GetDeviceStatusByAPI(c *gin.Context) {
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ukuran halaman default dan maksimum untuk riwayat data sensor
+const (
+	defaultSensorPageSize = 100
+	maxSensorPageSize     = 1000
+)
+
+// sensorCursor - Posisi terakhir di halaman sebelumnya (timestamp, id)
+type sensorCursor struct {
+	Timestamp time.Time
+	ID        uint
+}
+
+// encodeSensorCursor - Mengubah cursor menjadi string opaque untuk client
+func encodeSensorCursor(cur sensorCursor) string {
+	raw := fmt.Sprintf("%d:%d", cur.Timestamp.UnixNano(), cur.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeSensorCursor - Membaca cursor dari query parameter
+func decodeSensorCursor(value string) (sensorCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return sensorCursor{}, err
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return sensorCursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return sensorCursor{}, err
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return sensorCursor{}, err
+	}
+
+	return sensorCursor{Timestamp: time.Unix(0, nanos).UTC(), ID: uint(id)}, nil
+}
+
+// parseTimeParam - Membaca parameter waktu (RFC3339) yang opsional
+func parseTimeParam(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
+
+// respondSensorHistory - Mengirim riwayat data sensor dengan filter waktu dan pagination cursor.
+// Query parameter: from, to (RFC3339), order (asc|desc), limit, cursor.
+// Sebelum raw_retained_from data mentah sudah dihapus retensi, diganti rata-rata per jam (id = 0).
+func respondSensorHistory(c *gin.Context, device *models.Device) {
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' timestamp (RFC3339 required)"})
+		return
+	}
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' timestamp (RFC3339 required)"})
+		return
+	}
+	if from != nil && to != nil && from.After(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+
+	order := strings.ToLower(c.DefaultQuery("order", "asc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Order must be 'asc' or 'desc'"})
+		return
+	}
+
+	limit := defaultSensorPageSize
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return
+		}
+		if limit > maxSensorPageSize {
+			limit = maxSensorPageSize
+		}
+	}
+
+	query := services.SensorSeries(*device)
+	if from != nil {
+		query = query.Where("timestamp >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("timestamp <= ?", *to)
+	}
+
+	// Cursor menunjuk data terakhir halaman sebelumnya, lanjutkan setelahnya
+	if value := c.Query("cursor"); value != "" {
+		cur, err := decodeSensorCursor(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		}
+		if order == "asc" {
+			query = query.Where("(timestamp, id) > (?, ?)", cur.Timestamp, cur.ID)
+		} else {
+			query = query.Where("(timestamp, id) < (?, ?)", cur.Timestamp, cur.ID)
+		}
+	}
+
+	// Ambil satu data lebih untuk mengetahui apakah masih ada halaman berikutnya
+	sensorData := []models.SensorData{}
+	if err := query.Order("timestamp " + order).Order("id " + order).Limit(limit + 1).Find(&sensorData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sensor data"})
+		return
+	}
+
+	var nextCursor *string
+	if len(sensorData) > limit {
+		sensorData = sensorData[:limit]
+		last := sensorData[len(sensorData)-1]
+		encoded := encodeSensorCursor(sensorCursor{Timestamp: last.Timestamp, ID: last.ID})
+		nextCursor = &encoded
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sensor_data":       sensorData,
+		"next_cursor":       nextCursor,
+		"has_more":          nextCursor != nil,
+		"limit":             limit,
+		"order":             order,
+		"raw_retained_from": device.RawRetainedFrom,
+	})
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Interval komentar keep-alive agar koneksi tidak diputus proxy
+const streamHeartbeatInterval = 15 * time.Second
+
+// StreamSensorDataByUser - Mengirim data sensor baru secara live lewat Server-Sent Events
+func StreamSensorDataByUser(c *gin.Context) {
+	// Ambil ID perangkat dari parameter URL dan konversi ke uint
+	deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+
+	// Pastikan user boleh membaca data sensor device ini
+	device, ok := authorizeVitalsAccess(c, uint(deviceID))
+	if !ok {
+		return
+	}
+	userID := c.GetUint("user_id")
+	// Akses lewat AccessGrant dicek ulang berkala: grant bisa kedaluwarsa atau dicabut di instance lain
+	shared := device.UserID != userID && !(services.HasPermission(c.GetString("role"), readVitalsAccess.all) &&
+		services.InTenant(tenantID(c), device.OrganizationID))
+
+	sub := services.SensorHub.Subscribe(device.ID, device.UserID, userID)
+	defer services.SensorHub.Unsubscribe(sub)
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Matikan buffering di nginx
+
+	// Kirim header sekarang; audit log ditulis saat itu dan stream dibatalkan jika gagal
+	c.Writer.Flush()
+	if c.Writer.Status() != http.StatusOK {
+		return
+	}
+
+	var reportedDrops uint64
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case data := <-sub.C:
+			// Beri tahu client jika ada data yang dibuang karena koneksi lambat
+			if dropped := sub.Dropped(); dropped > reportedDrops {
+				c.SSEvent("dropped", gin.H{"count": dropped - reportedDrops})
+				reportedDrops = dropped
+			}
+			c.SSEvent("sensor", data)
+		case <-sub.Done():
+			c.SSEvent("revoked", gin.H{"error": "Access to this device has been revoked"})
+			return false
+		case <-heartbeat.C:
+			if shared && !services.HasActiveGrant(device.UserID, userID, models.GrantScopeViewVitals) {
+				c.SSEvent("revoked", gin.H{"error": "Access to this device has been revoked"})
+				return false
+			}
+			_, _ = io.WriteString(w, ": ping\n\n")
+		}
+		return true
+	})
+}
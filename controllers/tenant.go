@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"strconv"
+
+	database "backend/config"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tenantID - Organisasi yang membatasi query user yang login. nil = lintas organisasi (super-admin),
+// super-admin bisa mempersempit ke satu organisasi dengan ?organization_id=
+func tenantID(c *gin.Context) *uint {
+	orgID, _ := c.Get("organization_id")
+	organizationID, _ := orgID.(*uint)
+
+	tenant := services.TenantOf(c.GetString("role"), organizationID)
+	if tenant == nil {
+		if id, err := strconv.ParseUint(c.Query("organization_id"), 10, 32); err == nil {
+			selected := uint(id)
+			return &selected
+		}
+	}
+	return tenant
+}
+
+// tenantDB - Koneksi database untuk handler admin, semua query / update / delete lewat koneksi ini
+// otomatis dibatasi ke tenant user yang login (lihat services.RegisterTenantScope)
+func tenantDB(c *gin.Context) *gorm.DB {
+	return database.DB.WithContext(services.WithTenant(c.Request.Context(), tenantID(c)))
+}
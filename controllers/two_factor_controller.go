@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// currentUser - Mengambil data user yang sedang login dari database
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userID, _ := c.Get("user_id")
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// =================== Two-Factor Authentication (User) ===================
+
+// GetTwoFactorStatusByUser - Status 2FA user dan sisa kode cadangan
+func GetTwoFactorStatusByUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"required":                 services.RoleRequires2FA(user.Role),
+		"recovery_codes_remaining": services.RemainingRecoveryCodes(user.ID),
+	})
+}
+
+// SetupTwoFactorByUser - Membuat secret TOTP baru dan URI provisioning untuk QR code
+func SetupTwoFactorByUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := services.SetupTOTP(*user)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorEnabled) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": uri, // Tampilkan sebagai QR code di aplikasi authenticator
+		"message":          "Scan the QR code, then confirm with a code from your authenticator app",
+	})
+}
+
+// EnableTwoFactorByUser - Mengaktifkan 2FA dengan kode pertama dari authenticator
+func EnableTwoFactorByUser(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := services.EnableTOTP(*user, input.Code)
+	switch {
+	case errors.Is(err, services.ErrTwoFactorEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.Is(err, services.ErrTwoFactorNotSetup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Call /api/2fa/setup first"})
+	case errors.Is(err, services.ErrInvalidTOTPCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication enabled. Store the recovery codes in a safe place, they will not be shown again",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableTwoFactorByUser - Menonaktifkan 2FA (memerlukan password dan kode 2FA)
+func DisableTwoFactorByUser(c *gin.Context) {
+	var input struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if services.RoleRequires2FA(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if err := services.VerifySecondFactor(*user, input.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := services.DisableTOTP(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodesByUser - Membuat ulang kode cadangan (memerlukan kode 2FA)
+func RegenerateRecoveryCodesByUser(c *gin.Context) {
+	var input struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := services.VerifySecondFactor(*user, input.Code); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// =================== Two-Factor Policy (Admin) ===================
+
+// GetSecurityPolicyAdmin - Melihat kebijakan keamanan (role yang wajib 2FA)
+func GetSecurityPolicyAdmin(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"require_2fa_roles": services.Require2FARoles()})
+}
+
+// UpdateSecurityPolicyAdmin - Mengubah daftar role yang wajib memakai 2FA
+func UpdateSecurityPolicyAdmin(c *gin.Context) {
+	var input struct {
+		Require2FARoles []string `json:"require_2fa_roles"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil || input.Require2FARoles == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "require_2fa_roles is required"})
+		return
+	}
+
+	var roles []string
+	for _, role := range input.Require2FARoles {
+		role = strings.TrimSpace(role)
+		if !services.ValidRole(role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role: " + role})
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	adminID := c.MustGet("user_id").(uint)
+	if err := services.SetSetting(services.SettingRequire2FARoles, strings.Join(roles, ","), adminID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update security policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"require_2fa_roles": services.Require2FARoles()})
+}
+
+// ResetTwoFactorAdmin - Menonaktifkan 2FA user yang kehilangan authenticator dan kode cadangan
+func ResetTwoFactorAdmin(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var user models.User
+	if err := tenantDB(c).First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !canManageUser(c, user) {
+		return
+	}
+
+	// Sesi lama dicabut agar user login ulang dan setup 2FA kembali
+	if err := services.DisableTOTP(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+	if err := services.RevokeAllSessions(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
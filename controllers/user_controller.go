@@ -3,8 +3,11 @@ package controllers
 import (
 	database "backend/config"
 	"backend/models"
+	"backend/mqttbridge"
+	"backend/services"
 	"crypto/rand"
 	"encoding/hex"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -37,7 +40,69 @@ func GetDevicesByUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"devices": devices})
+	// Sertakan status konektivitas dan riwayat uptime
+	result, err := withStatusHistory(devices)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+		return
+	}
+
+	// Device milik pasien yang memberikan akses ke user ini (dicatat di audit log per pasien)
+	shared := []DeviceWithStatus{}
+	markAuditTargets(c, nil)
+	if services.HasPermission(c.GetString("role"), models.PermReadSharedVitals) {
+		patientIDs, err := services.SharedPatientIDs(userID.(uint), models.GrantScopeViewVitals)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+			return
+		}
+		markAuditTargets(c, patientIDs)
+		if len(patientIDs) > 0 {
+			var sharedDevices []models.Device
+			if err := database.DB.Where("user_id IN ?", patientIDs).Find(&sharedDevices).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+				return
+			}
+			if shared, err = withStatusHistory(sharedDevices); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch devices"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"devices": result, "shared_devices": shared})
+}
+
+// Jumlah riwayat status yang disertakan per device
+const deviceStatusHistoryLimit = 20
+
+// DeviceWithStatus - Device beserta riwayat online / offline / reboot terbaru
+type DeviceWithStatus struct {
+	models.Device
+	StatusHistory []models.DeviceStatusEvent `json:"status_history"`
+}
+
+// withStatusHistory - Menggabungkan daftar device dengan riwayat statusnya
+func withStatusHistory(devices []models.Device) ([]DeviceWithStatus, error) {
+	ids := make([]uint, len(devices))
+	for i, device := range devices {
+		ids[i] = device.ID
+	}
+
+	history, err := services.DeviceStatusHistory(ids, deviceStatusHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]DeviceWithStatus, len(devices))
+	for i, device := range devices {
+		events := history[device.ID]
+		if events == nil {
+			events = []models.DeviceStatusEvent{}
+		}
+		result[i] = DeviceWithStatus{Device: device, StatusHistory: events}
+	}
+	return result, nil
 }
 
 // GetDeviceByUser - Mendapatkan device tertentu milik user
@@ -49,20 +114,9 @@ func UpdateDeviceByUser(c *gin.Context) {
 		return
 	}
 
-	// Ambil user ID dari token JWT
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
-
-	// Cari device berdasarkan ID dan user ID (agar user hanya bisa edit device miliknya)
-	var device models.Device
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(deviceID), userIDUint).First(&device).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to edit this device"})
+	// Pastikan device milik user atau pasien yang memberikan akses kelola device
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), manageDeviceAccess)
+	if !ok {
 		return
 	}
 
@@ -81,11 +135,14 @@ func UpdateDeviceByUser(c *gin.Context) {
 	device.CurrentState = input.CurrentState
 	device.Delay = input.Delay
 
-	if err := database.DB.Save(&device).Error; err != nil {
+	if err := database.DB.Save(device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update device"})
 		return
 	}
 
+	// Kirim konfigurasi terbaru ke perangkat yang terhubung lewat MQTT
+	mqttbridge.PublishDeviceConfig(device)
+
 	c.JSON(http.StatusOK, gin.H{"message": "Device updated successfully"})
 }
 
@@ -104,9 +161,11 @@ func AddDeviceByUser(c *gin.Context) {
 		return
 	}
 
-	// Set device owner and generate API key
+	// Set device owner and generate API key (hanya hash yang disimpan)
 	device.UserID = userID.(uint)
-	device.APIKey = GenerateAPIKey()
+	device.OrganizationID, _ = c.MustGet("organization_id").(*uint) // Device ikut organisasi pemilik
+	apiKey := GenerateAPIKey()
+	services.SetAPIKey(&device, apiKey)
 
 	if err := database.DB.Create(&device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create device"})
@@ -116,19 +175,70 @@ func AddDeviceByUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Device added successfully",
 		"device":  device,
-		"api_key": device.APIKey,
+		"api_key": apiKey, // Hanya ditampilkan sekali
 	})
 }
 
-// DeleteDeviceByUser - Menghapus device tertentu yang dimiliki user
-func DeleteDeviceByUser(c *gin.Context) {
-	// Get user_id from token
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+// Masa transisi default dan maksimum untuk API Key lama setelah rotasi
+const (
+	defaultKeyGraceMinutes = 24 * 60
+	maxKeyGraceMinutes     = 7 * 24 * 60
+)
+
+// rotateDeviceKey - Membuat API Key baru untuk device, API Key lama tetap berlaku selama masa transisi
+func rotateDeviceKey(c *gin.Context, device *models.Device) {
+	var input struct {
+		GracePeriodMinutes *int `json:"grace_period_minutes"`
+	}
+
+	// Body opsional, tanpa body dipakai masa transisi default
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+			return
+		}
+	}
+
+	grace := defaultKeyGraceMinutes
+	if input.GracePeriodMinutes != nil {
+		grace = *input.GracePeriodMinutes
+	}
+	if grace < 0 || grace > maxKeyGraceMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "grace_period_minutes must be between 0 and 10080"})
+		return
+	}
+
+	apiKey := GenerateAPIKey()
+	if err := services.RotateAPIKey(device, apiKey, time.Duration(grace)*time.Minute); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                     "API key rotated successfully",
+		"api_key":                     apiKey, // Hanya ditampilkan sekali
+		"previous_api_key_expires_at": device.PreviousAPIKeyExpiresAt,
+	})
+}
+
+// RotateDeviceKeyByUser - Mengganti API Key device milik user (atau pasien yang memberikan akses kelola device)
+func RotateDeviceKeyByUser(c *gin.Context) {
+	deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
 		return
 	}
 
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), manageDeviceAccess)
+	if !ok {
+		return
+	}
+
+	rotateDeviceKey(c, device)
+}
+
+// DeleteDeviceByUser - Menghapus device tertentu yang dimiliki user
+func DeleteDeviceByUser(c *gin.Context) {
 	// Get device ID from URL
 	deviceID, err := strconv.ParseUint(c.Param("device_id"), 10, 32)
 	if err != nil {
@@ -136,14 +246,13 @@ func DeleteDeviceByUser(c *gin.Context) {
 		return
 	}
 
-	// Find the device and ensure ownership
-	var device models.Device
-	if err := database.DB.Where("id = ? AND user_id = ?", uint(deviceID), userID.(uint)).First(&device).Error; err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to delete this device"})
+	// Find the device and ensure ownership (akses bersama tidak bisa menghapus device)
+	device, ok := authorizeDeviceAccess(c, uint(deviceID), deleteDeviceAccess)
+	if !ok {
 		return
 	}
 
-	if err := database.DB.Delete(&device).Error; err != nil {
+	if err := database.DB.Delete(device).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete device"})
 		return
 	}
@@ -160,35 +269,94 @@ func GetSensorDataByUser(c *gin.Context) {
 		return
 	}
 
-	// Ambil user ID dan role dari token JWT
-	userID, exists := c.Get("user_id")
-	role, _ := c.Get("role")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	// Pastikan user boleh membaca data sensor device ini (pemilik, atau role dengan akses semua pasien)
+	device, ok := authorizeVitalsAccess(c, uint(deviceID))
+	if !ok {
 		return
 	}
 
-	// Konversi user ID ke uint
-	userIDUint := userID.(uint)
+	// Ambil data sensor berdasarkan device ID (dengan filter waktu & pagination)
+	respondSensorHistory(c, device)
+}
 
-	// Jika bukan admin, pastikan data sensor yang diambil adalah milik user yang sedang login
-	if role != "admin" {
-		var device models.Device
-		// Cek apakah perangkat milik user yang sedang login
-		if err := database.DB.Where("id = ? AND user_id = ?", deviceID, userIDUint).First(&device).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to access this device's sensor data"})
-			return
-		}
+// Rentang waktu maksimum pencarian celah nomor urut dalam satu request
+const maxGapWindow = 31 * 24 * time.Hour
+
+// SequenceGap - Rentang nomor urut yang tidak pernah diterima dari perangkat
+type SequenceGap struct {
+	BootID  *string   `json:"boot_id"`
+	FromSeq int64     `json:"from_seq"`
+	ToSeq   int64     `json:"to_seq"`
+	Missed  int64     `json:"missed"`
+	After   time.Time `json:"after"` // Timestamp data terakhir sebelum celah
+}
+
+// GetMissedReadingsByUser - Mendapatkan celah nomor urut (data yang hilang) dari device tertentu.
+// Query parameter: from, to (RFC3339), default 24 jam terakhir, maksimal 31 hari.
+func GetMissedReadingsByUser(c *gin.Context) {
+	// Ambil ID perangkat dari parameter URL dan konversi ke uint
+	deviceID, err := strconv.Atoi(c.Param("device_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid device ID"})
+		return
+	}
+
+	// Pastikan user boleh membaca data sensor device ini
+	if _, ok := authorizeVitalsAccess(c, uint(deviceID)); !ok {
+		return
 	}
 
-	// Ambil data sensor berdasarkan device ID
-	var sensorData []models.SensorData
-	if err := database.DB.Where("device_id = ?", deviceID).Find(&sensorData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve sensor data"})
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' timestamp (RFC3339 required)"})
+		return
+	}
+	if to == nil {
+		now := time.Now()
+		to = &now
+	}
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' timestamp (RFC3339 required)"})
 		return
 	}
+	if from == nil {
+		start := to.Add(-24 * time.Hour)
+		from = &start
+	}
+	if !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return
+	}
+	if to.Sub(*from) > maxGapWindow {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Time range must not exceed 31 days"})
+		return
+	}
+
+	// Bandingkan setiap seq dengan seq berikutnya dari boot yang sama (seq dimulai ulang setiap boot),
+	// urut waktu pengukuran. Selisih > 1 berarti ada data yang hilang.
+	gaps := []SequenceGap{}
+	err = database.DB.Raw(`
+		SELECT boot_id, seq + 1 AS from_seq, next_seq - 1 AS to_seq, next_seq - seq - 1 AS missed, timestamp AS after
+		FROM (
+			SELECT boot_id, seq, timestamp, id,
+				LEAD(seq) OVER (PARTITION BY boot_id ORDER BY timestamp, id) AS next_seq
+			FROM sensor_data
+			WHERE device_id = ? AND seq IS NOT NULL AND timestamp >= ? AND timestamp <= ?
+		) s
+		WHERE next_seq > seq + 1
+		ORDER BY timestamp, id`, deviceID, *from, *to).Scan(&gaps).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve missed readings"})
+		return
+	}
+
+	var total int64
+	for _, gap := range gaps {
+		total += gap.Missed
+	}
 
-	c.JSON(http.StatusOK, gin.H{"sensor_data": sensorData})
+	c.JSON(http.StatusOK, gin.H{"device_id": deviceID, "from": from, "to": to, "missed_readings": total, "gaps": gaps})
 }
 
 // =================== User Management ===================
@@ -196,6 +364,7 @@ func GetSensorDataByUser(c *gin.Context) {
 // UserInfoByUser - Mendapatkan informasi user
 func UserInfoByUser(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
+	markAuditTarget(c, userID, nil)
 
 	var user models.User
 	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
@@ -210,18 +379,28 @@ func UserInfoByUser(c *gin.Context) {
 // DeleteUserByUser - Menghapus user
 func DeleteUserByUser(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
+	markAuditTarget(c, userID, nil)
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
 
-	if err := database.DB.Delete(&models.User{}, userID).Error; err != nil {
+	// Akun hanya ditandai terhapus, data dihapus permanen setelah masa tenggang
+	purgeAt, err := services.SoftDeleteUser(user)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "User scheduled for deletion", "purge_at": purgeAt})
 }
 
 // UpdateUserByUser - Mengubah informasi user
 func UpdateUserByUser(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
+	markAuditTarget(c, userID, nil)
 
 	var input struct {
 		Username       *string `json:"username"`
@@ -252,13 +431,20 @@ func UpdateUserByUser(c *gin.Context) {
 	if input.Username != nil {
 		user.Username = *input.Username
 	}
-	if input.Email != nil {
+	// Email baru harus diverifikasi ulang
+	emailChanged := input.Email != nil && *input.Email != user.Email
+	if emailChanged {
 		user.Email = *input.Email
+		user.EmailVerified = false
 	}
 	if input.FullName != nil {
 		user.FullName = input.FullName
 	}
 	if input.MedicalHistory != nil {
+		if !services.HasPermission(c.GetString("role"), models.PermEditOwnMedicalHistory) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not allowed to edit medical history"})
+			return
+		}
 		user.MedicalHistory = input.MedicalHistory
 	}
 	if input.Address != nil {
@@ -288,9 +474,53 @@ func UpdateUserByUser(c *gin.Context) {
 		return
 	}
 
+	if emailChanged {
+		if err := services.SendVerificationEmail(user, true); err != nil {
+			log.Println("Failed to send verification email:", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
 }
 
+// UpdateSharedMedicalHistoryByUser - Clinician mengubah riwayat medis pasien yang memberi akses edit_medical_history
+func UpdateSharedMedicalHistoryByUser(c *gin.Context) {
+	patientID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		MedicalHistory *string `json:"medical_history" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input", "detail": err.Error()})
+		return
+	}
+
+	markAuditTarget(c, uint(patientID), nil)
+	userID := c.MustGet("user_id").(uint)
+	if !services.HasActiveGrant(uint(patientID), userID, models.GrantScopeEditMedical) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	var patient models.User
+	if err := database.DB.First(&patient, patientID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Patient not found"})
+		return
+	}
+
+	// Update lewat struct agar riwayat medis tetap dienkripsi serializer
+	patient.MedicalHistory = input.MedicalHistory
+	if err := database.DB.Model(&patient).Select("medical_history", "updated_at").Updates(&patient).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update medical history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Medical history updated successfully"})
+}
 
 // ChangePasswordByUser - Mengubah password user
 func ChangePasswordByUser(c *gin.Context) {
@@ -306,6 +536,8 @@ func ChangePasswordByUser(c *gin.Context) {
 		return
 	}
 
+	markAuditTarget(c, userID, nil)
+
 	var user models.User
 	if err := database.DB.First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
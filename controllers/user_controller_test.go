@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMissedReadingsArePerBoot(t *testing.T) {
+	db := testdb.Open(t)
+	user := models.User{Username: "gaps", Password: "x", Email: "gaps@example.com", Role: models.RolePatient}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	device := models.Device{UserID: user.ID, Name: "gaps"}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Boot a: 1, 2, 5 (3-4 hilang). Boot b dimulai ulang dari 1 dan diselingi boot a: tidak ada celah.
+	start := time.Now().Add(-time.Hour)
+	bootA, bootB := "a", "b"
+	readings := []struct {
+		boot *string
+		seq  int64
+	}{{&bootA, 1}, {&bootB, 1}, {&bootA, 2}, {&bootB, 2}, {&bootA, 5}, {&bootB, 3}}
+	for i, r := range readings {
+		seq := r.seq
+		reading := models.SensorData{DeviceID: device.ID, BPM: 70, Seq: &seq, BootID: r.boot, Timestamp: start.Add(time.Duration(i) * time.Minute)}
+		if err := db.Create(&reading).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/sensor/:device_id/gaps", func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		c.Set("role", models.RolePatient)
+	}, GetMissedReadingsByUser)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/sensor/%d/gaps", device.ID), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		MissedReadings int64         `json:"missed_readings"`
+		Gaps           []SequenceGap `json:"gaps"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.MissedReadings != 2 || len(response.Gaps) != 1 || *response.Gaps[0].BootID != "a" || response.Gaps[0].FromSeq != 3 {
+		t.Fatalf("expected one gap of 2 readings in boot a, got %+v", response)
+	}
+}
@@ -0,0 +1,191 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Format nilai terenkripsi:
+//
+//	enc:v2:<id KEK>:<data key terbungkus KEK>:<nonce + ciphertext>
+//
+// Setiap nilai memakai data key (AES-256) sendiri yang dibungkus dengan KEK,
+// sehingga rotasi KEK cukup membungkus ulang data key tanpa mengenkripsi ulang data.
+// v2 terikat ke baris (RowAAD), v1 hanya terikat ke kolom dan masih bisa dibaca
+// sampai dienkripsi ulang oleh rotasi.
+const (
+	envelopePrefix       = "enc:v2:"
+	legacyEnvelopePrefix = "enc:v1:"
+)
+
+// AAD untuk data key terbungkus
+const dataKeyAAD = "fieldcrypt:data-key"
+
+// ErrMalformed - Nilai terenkripsi tidak sesuai format
+var ErrMalformed = errors.New("malformed encrypted value")
+
+// IsEncrypted - Mengecek apakah nilai kolom sudah terenkripsi
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix) || strings.HasPrefix(value, legacyEnvelopePrefix)
+}
+
+// IsRowBound - Mengecek apakah nilai terenkripsi memakai format v2 (AAD berisi primary key baris)
+func IsRowBound(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID - ID KEK yang membungkus data key dari nilai terenkripsi
+func KeyID(value string) (string, error) {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return "", err
+	}
+	return parts[0], nil
+}
+
+// Encrypt - Mengenkripsi plaintext dengan data key baru, aad (RowAAD) mengikat ciphertext ke baris dan kolomnya
+func Encrypt(plaintext []byte, aad string) (string, error) {
+	k, err := keyring()
+	if err != nil {
+		return "", err
+	}
+	kek, err := k.key(k.active)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(kek, dataKey, []byte(dataKeyAAD))
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataKey, plaintext, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+
+	return envelopePrefix + k.active + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Decrypt - Mendekripsi nilai terenkripsi dengan aad yang sama saat enkripsi
+func Decrypt(value string, aad string) ([]byte, error) {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := unwrapDataKey(parts[0], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return open(dataKey, ciphertext, []byte(aad))
+}
+
+// Rewrap - Membungkus ulang data key dengan KEK aktif (rotasi key).
+// Mengembalikan false jika nilai sudah memakai KEK aktif.
+func Rewrap(value string) (string, bool, error) {
+	parts, err := splitEnvelope(value)
+	if err != nil {
+		return "", false, err
+	}
+	k, err := keyring()
+	if err != nil {
+		return "", false, err
+	}
+	if parts[0] == k.active {
+		return value, false, nil
+	}
+	prefix := value[:len(envelopePrefix)] // Versi format tetap, AAD tidak berubah
+
+	dataKey, err := unwrapDataKey(parts[0], parts[1])
+	if err != nil {
+		return "", false, err
+	}
+	kek, err := k.key(k.active)
+	if err != nil {
+		return "", false, err
+	}
+	wrapped, err := seal(kek, dataKey, []byte(dataKeyAAD))
+	if err != nil {
+		return "", false, err
+	}
+
+	return prefix + k.active + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + parts[2], true, nil
+}
+
+// splitEnvelope - Memecah nilai terenkripsi menjadi [id KEK, data key terbungkus, ciphertext]
+func splitEnvelope(value string) ([]string, error) {
+	if !IsEncrypted(value) {
+		return nil, ErrMalformed
+	}
+	// Prefix v1 dan v2 sama panjang
+	parts := strings.Split(value[len(envelopePrefix):], ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return nil, ErrMalformed
+	}
+	return parts, nil
+}
+
+// unwrapDataKey - Membuka data key dengan KEK yang membungkusnya
+func unwrapDataKey(keyID, encoded string) ([]byte, error) {
+	k, err := keyring()
+	if err != nil {
+		return nil, err
+	}
+	kek, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return open(kek, wrapped, []byte(dataKeyAAD))
+}
+
+// seal - AES-GCM, hasil berupa nonce diikuti ciphertext
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open - Kebalikan dari seal
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+// newGCM - Cipher AES-GCM untuk key 32 byte
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,127 @@
+package fieldcrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testKey - KEK acak dalam format entry keyring "id:base64key"
+func testKey(t *testing.T, id string) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return id + ":" + base64.StdEncoding.EncodeToString(key)
+}
+
+// useKeyring - Memasang keyring untuk satu test
+func useKeyring(t *testing.T, spec string) {
+	t.Helper()
+	k, err := NewKeyring(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(k)
+	t.Cleanup(func() { SetKeyring(nil) })
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+
+	aad := RowAAD("users", "medical_history", 7)
+	value, err := Encrypt([]byte("asthma"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(value) || !IsRowBound(value) || strings.Contains(value, "asthma") {
+		t.Fatalf("unexpected ciphertext %q", value)
+	}
+	if id, err := KeyID(value); err != nil || id != "k1" {
+		t.Fatalf("expected key k1, got %q (err %v)", id, err)
+	}
+
+	plaintext, err := Decrypt(value, aad)
+	if err != nil || string(plaintext) != "asthma" {
+		t.Fatalf("expected asthma, got %q (err %v)", plaintext, err)
+	}
+}
+
+func TestDecryptRejectsOtherRowOrColumn(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+
+	value, err := Encrypt([]byte("asthma"), RowAAD("users", "medical_history", 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, aad := range []string{
+		RowAAD("users", "medical_history", 8),
+		RowAAD("users", "address", 7),
+		ColumnAAD("users", "medical_history"),
+	} {
+		if _, err := Decrypt(value, aad); err == nil {
+			t.Errorf("ciphertext decrypted with AAD %q", aad)
+		}
+	}
+}
+
+func TestDecryptRejectsTamperedCiphertext(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+
+	aad := RowAAD("users", "address", 3)
+	value, err := Encrypt([]byte("Jl. Merdeka 1"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(value, ":")
+	sealed, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed[len(sealed)-1] ^= 0x01
+	parts[4] = base64.RawStdEncoding.EncodeToString(sealed)
+
+	if _, err := Decrypt(strings.Join(parts, ":"), aad); err == nil {
+		t.Fatal("tampered ciphertext decrypted")
+	}
+	if _, err := Decrypt("enc:v2:k1:only-two", aad); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected ErrMalformed, got %v", err)
+	}
+}
+
+func TestRewrapAfterKeyRotation(t *testing.T) {
+	oldKey, newKey := testKey(t, "old"), testKey(t, "new")
+	useKeyring(t, oldKey)
+
+	aad := RowAAD("users", "medical_history", 1)
+	value, err := Encrypt([]byte("diabetes"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// KEK baru aktif, KEK lama masih tersedia untuk membuka data key
+	useKeyring(t, newKey+","+oldKey)
+	wrapped, changed, err := Rewrap(value)
+	if err != nil || !changed {
+		t.Fatalf("expected rewrap, got changed=%v err=%v", changed, err)
+	}
+	if id, _ := KeyID(wrapped); id != "new" || !IsRowBound(wrapped) {
+		t.Fatalf("rewrapped value should use key new with the v2 format, got %q", wrapped)
+	}
+	if _, changed, err := Rewrap(wrapped); err != nil || changed {
+		t.Fatalf("value on the active key should not change, got changed=%v err=%v", changed, err)
+	}
+
+	// Setelah KEK lama dihapus hanya nilai yang sudah dibungkus ulang yang terbaca
+	useKeyring(t, newKey)
+	if plaintext, err := Decrypt(wrapped, aad); err != nil || string(plaintext) != "diabetes" {
+		t.Fatalf("expected diabetes, got %q (err %v)", plaintext, err)
+	}
+	if _, err := Decrypt(value, aad); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey for the old key, got %v", err)
+	}
+}
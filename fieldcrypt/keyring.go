@@ -0,0 +1,125 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Env berisi daftar KEK dengan format "id:base64key,id_lama:base64key".
+// Key pertama adalah key aktif, key berikutnya hanya dipakai untuk membaca data lama.
+const keysEnv = "FIELD_ENCRYPTION_KEYS"
+
+// Error konfigurasi key
+var (
+	ErrNoKeys     = errors.New("field encryption keys are not configured (" + keysEnv + ")")
+	ErrUnknownKey = errors.New("unknown key-encryption key")
+)
+
+// Keyring - Kumpulan key-encryption key (KEK) untuk membungkus data key
+type Keyring struct {
+	active string
+	keys   map[string][]byte
+}
+
+// NewKeyring - Membaca keyring dari string "id:base64key,..." (key AES-256, 32 byte)
+func NewKeyring(spec string) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("invalid key entry %q, expected id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes encoded as base64", id)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+
+		k.keys[id] = key
+		if k.active == "" {
+			k.active = id
+		}
+	}
+
+	if k.active == "" {
+		return nil, ErrNoKeys
+	}
+	return k, nil
+}
+
+// ActiveID - ID key yang dipakai untuk enkripsi baru
+func (k *Keyring) ActiveID() string {
+	return k.active
+}
+
+// key - Mengambil KEK berdasarkan ID
+func (k *Keyring) key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, id)
+	}
+	return key, nil
+}
+
+var (
+	mu      sync.RWMutex
+	current *Keyring
+)
+
+// LoadFromEnv - Memuat keyring dari env FIELD_ENCRYPTION_KEYS
+func LoadFromEnv() error {
+	spec := os.Getenv(keysEnv)
+	if spec == "" {
+		return ErrNoKeys
+	}
+	k, err := NewKeyring(spec)
+	if err != nil {
+		return err
+	}
+	SetKeyring(k)
+	return nil
+}
+
+// SetKeyring - Mengganti keyring yang dipakai untuk enkripsi / dekripsi
+func SetKeyring(k *Keyring) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = k
+}
+
+// ActiveKeyID - ID key aktif pada keyring saat ini
+func ActiveKeyID() (string, error) {
+	k, err := keyring()
+	if err != nil {
+		return "", err
+	}
+	return k.ActiveID(), nil
+}
+
+// keyring - Keyring saat ini, dimuat dari env saat pertama kali dipakai
+func keyring() (*Keyring, error) {
+	mu.RLock()
+	k := current
+	mu.RUnlock()
+	if k != nil {
+		return k, nil
+	}
+
+	if err := LoadFromEnv(); err != nil {
+		return nil, err
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	return current, nil
+}
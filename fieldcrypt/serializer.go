@@ -0,0 +1,158 @@
+package fieldcrypt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm/schema"
+)
+
+// ErrMissingPrimaryKey - Primary key baris belum ada sehingga AAD tidak bisa dibentuk
+var ErrMissingPrimaryKey = errors.New("primary key is required for encrypted fields")
+
+// Format plaintext lama kolom tanggal (sebelum dienkripsi, kolom bertipe timestamp)
+var legacyTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999-07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Serializer - Serializer GORM untuk field terenkripsi (tag `gorm:"serializer:encrypted"`).
+// Mendukung field string dan time.Time (boleh pointer). Field tetap bertipe biasa di model
+// sehingga kode lain dan respons JSON tidak berubah, hanya nilai di database yang terenkripsi.
+type Serializer struct{}
+
+// Scan - Mendekripsi nilai dari database ke field model. Nilai plaintext lama tetap terbaca.
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := field.ReflectValueOf(ctx, dst)
+
+	var raw string
+	switch v := dbValue.(type) {
+	case nil:
+		fieldValue.Set(reflect.Zero(field.FieldType))
+		return nil
+	case string:
+		raw = v
+	case []byte:
+		raw = string(v)
+	case time.Time:
+		raw = v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Errorf("fieldcrypt: unsupported database value %T for %s", dbValue, field.Name)
+	}
+
+	plaintext := raw
+	if IsEncrypted(raw) {
+		// v1 lama hanya terikat ke kolom, v2 ke baris: ciphertext baris lain ditolak
+		aad := columnAAD(field)
+		if IsRowBound(raw) {
+			var err error
+			if aad, err = rowAAD(ctx, field, dst); err != nil {
+				return fmt.Errorf("fieldcrypt: decrypt %s: %w", field.Name, err)
+			}
+		}
+		decrypted, err := Decrypt(raw, aad)
+		if err != nil {
+			return fmt.Errorf("fieldcrypt: decrypt %s: %w", field.Name, err)
+		}
+		plaintext = string(decrypted)
+	}
+
+	var value reflect.Value
+	switch field.IndirectFieldType {
+	case reflect.TypeOf(""):
+		value = reflect.ValueOf(plaintext)
+	case reflect.TypeOf(time.Time{}):
+		parsed, err := ParseTime(plaintext)
+		if err != nil {
+			return fmt.Errorf("fieldcrypt: parse %s: %w", field.Name, err)
+		}
+		value = reflect.ValueOf(parsed)
+	default:
+		return fmt.Errorf("fieldcrypt: unsupported field type %s for %s", field.FieldType, field.Name)
+	}
+
+	if field.FieldType.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.IndirectFieldType)
+		ptr.Elem().Set(value)
+		value = ptr
+	}
+	fieldValue.Set(value)
+	return nil
+}
+
+// Value - Mengenkripsi nilai field sebelum disimpan, nil tetap disimpan sebagai NULL
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var plaintext string
+	switch v := fieldValue.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		plaintext = v
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		plaintext = *v
+	case time.Time:
+		plaintext = v.Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return nil, nil
+		}
+		plaintext = v.Format(time.RFC3339Nano)
+	default:
+		return nil, fmt.Errorf("fieldcrypt: unsupported field type %T for %s", fieldValue, field.Name)
+	}
+
+	aad, err := rowAAD(ctx, field, dst)
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: encrypt %s: %w", field.Name, err)
+	}
+	return Encrypt([]byte(plaintext), aad)
+}
+
+// ParseTime - Membaca plaintext tanggal (RFC3339 atau format timestamp PostgreSQL lama)
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range legacyTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time value %q", value)
+}
+
+// ColumnAAD - Mengikat ciphertext ke tabel dan kolomnya agar tidak bisa dipindah ke kolom lain
+func ColumnAAD(table, column string) string {
+	return table + "." + column
+}
+
+// RowAAD - Mengikat ciphertext ke tabel, kolom dan primary key barisnya agar tidak bisa
+// disalin ke kolom yang sama milik baris lain
+func RowAAD(table, column string, id interface{}) string {
+	return fmt.Sprintf("%s#%v", ColumnAAD(table, column), id)
+}
+
+// columnAAD - AAD format v1 untuk field model
+func columnAAD(field *schema.Field) string {
+	return ColumnAAD(field.Schema.Table, field.DBName)
+}
+
+// rowAAD - AAD format v2 untuk field model. Primary key harus sudah terisi (kolom id harus ikut
+// di-SELECT dan baris baru harus mendapat id sebelum INSERT).
+func rowAAD(ctx context.Context, field *schema.Field, dst reflect.Value) (string, error) {
+	primary := field.Schema.PrioritizedPrimaryField
+	if primary == nil {
+		return "", ErrMissingPrimaryKey
+	}
+	id, zero := primary.ValueOf(ctx, dst)
+	if zero {
+		return "", ErrMissingPrimaryKey
+	}
+	return RowAAD(field.Schema.Table, field.DBName, id), nil
+}
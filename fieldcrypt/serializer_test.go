@@ -0,0 +1,92 @@
+package fieldcrypt
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm/schema"
+)
+
+type serializerTestUser struct {
+	ID             uint
+	MedicalHistory *string `gorm:"type:text;serializer:encrypted"`
+}
+
+func init() {
+	schema.RegisterSerializer("encrypted", Serializer{})
+}
+
+// medicalHistoryField - Field terenkripsi dari model test
+func medicalHistoryField(t *testing.T) *schema.Field {
+	t.Helper()
+	s, err := schema.Parse(&serializerTestUser{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.LookUpField("MedicalHistory")
+}
+
+func TestSerializerBindsCiphertextToRow(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+	field := medicalHistoryField(t)
+	ctx := context.Background()
+
+	history := "asthma"
+	owner := serializerTestUser{ID: 1, MedicalHistory: &history}
+	value, err := Serializer{}.Value(ctx, field, reflect.ValueOf(&owner).Elem(), owner.MedicalHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read serializerTestUser
+	read.ID = 1
+	if err := (Serializer{}).Scan(ctx, field, reflect.ValueOf(&read).Elem(), value); err != nil {
+		t.Fatal(err)
+	}
+	if read.MedicalHistory == nil || *read.MedicalHistory != history {
+		t.Fatalf("expected %q, got %v", history, read.MedicalHistory)
+	}
+
+	// Ciphertext yang disalin ke baris lain tidak bisa dibaca
+	other := serializerTestUser{ID: 2}
+	if err := (Serializer{}).Scan(ctx, field, reflect.ValueOf(&other).Elem(), value); err == nil {
+		t.Fatal("ciphertext copied to another row decrypted")
+	}
+}
+
+func TestSerializerRequiresPrimaryKey(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+	field := medicalHistoryField(t)
+
+	history := "asthma"
+	user := serializerTestUser{MedicalHistory: &history}
+	if _, err := (Serializer{}).Value(context.Background(), field, reflect.ValueOf(&user).Elem(), user.MedicalHistory); !errors.Is(err, ErrMissingPrimaryKey) {
+		t.Fatalf("expected ErrMissingPrimaryKey, got %v", err)
+	}
+}
+
+func TestSerializerReadsLegacyValues(t *testing.T) {
+	useKeyring(t, testKey(t, "k1"))
+	field := medicalHistoryField(t)
+	ctx := context.Background()
+
+	// Nilai v1 (hanya terikat ke kolom) dan plaintext lama tetap terbaca sampai dirotasi
+	v1, err := Encrypt([]byte("asthma"), ColumnAAD("serializer_test_users", "medical_history"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 = legacyEnvelopePrefix + v1[len(envelopePrefix):]
+
+	for _, stored := range []string{v1, "asthma"} {
+		user := serializerTestUser{ID: 1}
+		if err := (Serializer{}).Scan(ctx, field, reflect.ValueOf(&user).Elem(), stored); err != nil {
+			t.Fatal(err)
+		}
+		if user.MedicalHistory == nil || *user.MedicalHistory != "asthma" {
+			t.Fatalf("expected asthma from %q, got %v", stored, user.MedicalHistory)
+		}
+	}
+}
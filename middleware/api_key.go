@@ -2,8 +2,8 @@ package middleware
 
 import (
 	"net/http"
-	"backend/models"
-	"backend/config"
+	"strconv"
+	"backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,16 +19,26 @@ func APIKeyMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		var device models.Device
-		if err := database.DB.Where("api_key = ?", apiKey).First(&device).Error; err != nil {
+		device, err := services.FindDeviceByAPIKey(apiKey)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API Key"})
 			c.Abort()
 			return
 		}
 
+		// Catat heartbeat device, uptime dikirim firmware lewat header X-Device-Uptime (detik)
+		var uptime *int64
+		if value, err := strconv.ParseInt(c.GetHeader("X-Device-Uptime"), 10, 64); err == nil && value >= 0 {
+			uptime = &value
+		}
+		services.TouchDevice(device, c.ClientIP(), uptime)
+
 		// Menyimpan device_id dan api_key ke context
 		c.Set("device_id", device.ID)
 		c.Set("api_key", apiKey)
+		// Pasien pemilik data untuk audit log
+		c.Set(services.AuditTargetUserKey, device.UserID)
+		c.Set(services.AuditTargetDeviceKey, device.ID)
 		c.Next()
 	}
 }
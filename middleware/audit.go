@@ -0,0 +1,160 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/models"
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errAuditUnavailable - Respons dibatalkan karena akses tidak bisa dicatat ke audit log
+var errAuditUnavailable = errors.New("audit log unavailable")
+
+// Audit - Mencatat akses ke data profil / vital sign ke audit log.
+// Pasien / device target diambil dari handler (services.AuditTarget*Key) atau dari parameter URL.
+//
+// Untuk request baca (GET) audit log ditulis sebelum byte pertama respons dikirim; jika gagal
+// setelah dicoba ulang, data tidak dikirim dan client menerima 503. Untuk request yang mengubah
+// data, perubahan sudah tersimpan saat handler selesai sehingga audit log hanya dicoba ulang.
+func Audit(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			_ = services.RecordAudit(auditEntries(c, action, c.Writer.Status(), start)...)
+			return
+		}
+
+		writer := &auditedWriter{ResponseWriter: c.Writer, record: func(status int) error {
+			return services.RecordAudit(auditEntries(c, action, status, start)...)
+		}}
+		c.Writer = writer
+		defer func() { c.Writer = writer.ResponseWriter }()
+
+		c.Next()
+		writer.commit()
+	}
+}
+
+// auditEntries - Catatan audit untuk request ini, satu per pasien jika respons berisi data beberapa pasien
+func auditEntries(c *gin.Context, action string, status int, start time.Time) []models.AuditLog {
+	entry := models.AuditLog{
+		ActorRole:      c.GetString("role"),
+		Action:         action,
+		Method:         c.Request.Method,
+		Path:           c.Request.URL.Path,
+		TargetUserID:   auditTarget(c, services.AuditTargetUserKey, "user_id"),
+		TargetDeviceID: auditTarget(c, services.AuditTargetDeviceKey, "device_id"),
+		IP:             c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		Outcome:        auditOutcome(status),
+		StatusCode:     status,
+		CreatedAt:      start,
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		actorID := userID.(uint)
+		entry.ActorID = &actorID
+	} else if _, ok := c.Get("device_id"); ok {
+		// Request dari perangkat (API Key), bukan dari user
+		entry.ActorRole = models.AuditActorDevice
+	}
+
+	value, ok := c.Get(services.AuditTargetUsersKey)
+	if !ok {
+		return []models.AuditLog{entry}
+	}
+	// Daftar kosong berarti respons tidak berisi data pasien lain, tidak perlu dicatat
+	targets, _ := value.([]uint)
+	entries := make([]models.AuditLog, len(targets))
+	for i := range targets {
+		entries[i] = entry
+		entries[i].TargetUserID = &targets[i]
+	}
+	return entries
+}
+
+// auditTarget - ID target dari context handler, atau dari parameter URL jika handler tidak mengisinya
+func auditTarget(c *gin.Context, key, param string) *uint {
+	if value, ok := c.Get(key); ok {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	if id, err := strconv.ParseUint(c.Param(param), 10, 32); err == nil {
+		target := uint(id)
+		return &target
+	}
+	return nil
+}
+
+// auditOutcome - Mengelompokkan status HTTP menjadi hasil akses
+func auditOutcome(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return models.AuditSuccess
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || status == http.StatusNotFound:
+		return models.AuditDenied
+	default:
+		return models.AuditError
+	}
+}
+
+// auditedWriter - Menulis audit log tepat sebelum respons pertama kali dikirim (status sudah
+// diketahui saat itu). Jika audit log gagal, respons diganti 503 dan tulisan berikutnya ditolak.
+type auditedWriter struct {
+	gin.ResponseWriter
+	record    func(status int) error
+	committed bool
+	failed    bool
+}
+
+// commit - Menulis audit log sekali, mengembalikan false jika respons harus dibatalkan
+func (w *auditedWriter) commit() bool {
+	if w.committed {
+		return !w.failed
+	}
+	w.committed = true
+	if err := w.record(w.ResponseWriter.Status()); err == nil {
+		return true
+	}
+
+	w.failed = true
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Del("Content-Disposition")
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.ResponseWriter.WriteString(`{"error":"Audit log is unavailable, please try again later"}`)
+	return false
+}
+
+func (w *auditedWriter) Write(data []byte) (int, error) {
+	if !w.commit() {
+		return 0, errAuditUnavailable
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditedWriter) WriteString(s string) (int, error) {
+	if !w.commit() {
+		return 0, errAuditUnavailable
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditedWriter) WriteHeaderNow() {
+	if w.commit() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *auditedWriter) Flush() {
+	w.commit()
+	w.ResponseWriter.Flush()
+}
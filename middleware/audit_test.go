@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditedContext - Context test dengan auditedWriter yang memakai fungsi record tertentu
+func auditedContext(record func(status int) error) (*gin.Context, *httptest.ResponseRecorder, *auditedWriter) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	writer := &auditedWriter{ResponseWriter: c.Writer, record: record}
+	c.Writer = writer
+	return c, recorder, writer
+}
+
+func TestAuditedWriterRecordsBeforeResponse(t *testing.T) {
+	var recorded []int
+	c, recorder, writer := auditedContext(func(status int) error {
+		recorded = append(recorded, status)
+		return nil
+	})
+
+	c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+	writer.commit()
+
+	if len(recorded) != 1 || recorded[0] != http.StatusForbidden {
+		t.Fatalf("expected one audit entry with status 403, got %v", recorded)
+	}
+	if recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), "Forbidden") {
+		t.Fatalf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestAuditedWriterWithholdsDataWhenAuditFails(t *testing.T) {
+	c, recorder, writer := auditedContext(func(int) error { return errors.New("database down") })
+
+	c.Header("Content-Disposition", "attachment; filename=export.zip")
+	c.JSON(http.StatusOK, gin.H{"medical_history": "asthma"})
+	writer.commit()
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", recorder.Code)
+	}
+	if strings.Contains(recorder.Body.String(), "asthma") || recorder.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("patient data leaked without an audit entry: %q", recorder.Body.String())
+	}
+}
@@ -5,16 +5,19 @@ import (
 	"os"
 	"strings"
 
+	"backend/services"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
 )
 
 // Struct simpan payload dari token JWT
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Role     string `json:"role"`
-	Email    string `json:"email"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	Email     string `json:"email"`
+	SessionID uint   `json:"sid"` // ID sesi di database, dicek setiap request
 	jwt.StandardClaims
 }
 
@@ -59,19 +62,72 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Konversi user_id ke uint (karena awalnya float64)
 		userID := uint(claims.UserID)
 
+		// Pastikan sesi belum dicabut (logout) dan user masih ada
+		user, err := services.ActiveSessionUser(claims.SessionID, userID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			c.Abort()
+			return
+		}
+
+		// Kebijakan 2FA: role yang wajib 2FA harus setup dulu sebelum mengakses endpoint lain
+		if !user.TOTPEnabled && services.RoleRequires2FA(user.Role) && !allowedBefore2FASetup(c.FullPath()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication setup required", "two_factor_setup_required": true})
+			c.Abort()
+			return
+		}
+
+		// Data user diambil dari database agar perubahan role langsung berlaku
 		c.Set("user_id", userID)
-		c.Set("username", claims.Username)
-		c.Set("role", claims.Role)
-		c.Set("email", claims.Email)
+		c.Set("username", user.Username)
+		c.Set("role", user.Role)
+		c.Set("organization_id", user.OrganizationID)
+		c.Set("email", user.Email)
+		c.Set("email_verified", user.EmailVerified)
+		c.Set("session_id", claims.SessionID)
+		c.Next()
+	}
+}
+
+// allowedBefore2FASetup - Endpoint yang tetap bisa diakses sebelum 2FA wajib di-setup
+func allowedBefore2FASetup(path string) bool {
+	return strings.HasPrefix(path, "/api/2fa") || path == "/logout" || path == "/logout-all"
+}
+
+// RequirePermission - Hanya mengizinkan user yang role-nya memiliki semua permission yang diminta
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, permission := range permissions {
+			if !services.HasPermission(role, permission) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Access forbidden"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// QueryTokenMiddleware - Mengizinkan JWT dikirim lewat query ?access_token=
+// untuk client yang tidak bisa mengatur header (misalnya EventSource di browser)
+func QueryTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
 		c.Next()
 	}
 }
 
-func AdminOnly() gin.HandlerFunc {
+// VerifiedEmailOnly - Menolak request dari user yang belum verifikasi email,
+// hanya aktif jika kebijakan REQUIRE_EMAIL_VERIFIED diaktifkan
+func VerifiedEmailOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		role, exists := c.Get("role")
-		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Access forbidden"})
+		if services.RequireVerifiedEmail() && !c.GetBool("email_verified") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email verification required"})
 			c.Abort()
 			return
 		}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Parameter query yang berisi kredensial: JWT untuk EventSource (?access_token=), link download export,
+// verifikasi email dan penghapusan akun (?token=)
+var sensitiveQueryParams = []string{"access_token", "token"}
+
+// RequestLogger - Logger request seperti gin.Logger, tetapi nilai kredensial di query string disamarkan
+// agar token tidak tersimpan di log server
+func RequestLogger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: func(param gin.LogFormatterParams) string {
+		var statusColor, methodColor, resetColor string
+		if param.IsOutputColor() {
+			statusColor = param.StatusCodeColor()
+			methodColor = param.MethodColor()
+			resetColor = param.ResetColor()
+		}
+		if param.Latency > time.Minute {
+			param.Latency = param.Latency.Truncate(time.Second)
+		}
+		return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			statusColor, param.StatusCode, resetColor,
+			param.Latency,
+			param.ClientIP,
+			methodColor, param.Method, resetColor,
+			redactQuery(param.Path),
+			param.ErrorMessage,
+		)
+	}})
+}
+
+// redactQuery - Mengganti nilai parameter kredensial di path (beserta query string) dengan REDACTED
+func redactQuery(path string) string {
+	base, rawQuery, found := strings.Cut(path, "?")
+	if !found {
+		return path
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// Query tidak bisa diurai, jangan ambil risiko menulis isinya
+		return base + "?REDACTED"
+	}
+	redacted := false
+	for _, name := range sensitiveQueryParams {
+		if _, ok := query[name]; ok {
+			query[name] = []string{"REDACTED"}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return base + "?" + query.Encode()
+}
@@ -0,0 +1,19 @@
+package middleware
+
+import "testing"
+
+func TestRedactQueryHidesTokens(t *testing.T) {
+	tests := map[string]string{
+		"/api/stream?access_token=eyJhbGci.payload.sig":         "/api/stream?access_token=REDACTED",
+		"/exports/download?token=abc&x=1":                       "/exports/download?token=REDACTED&x=1",
+		"/api/sensor?interval=1h&limit=10":                      "/api/sensor?interval=1h&limit=10",
+		"/api/users":                                            "/api/users",
+		"/verify-email?token=%zz":                               "/verify-email?REDACTED",
+		"/api/stream?device_id=1&access_token=a&access_token=b": "/api/stream?access_token=REDACTED&device_id=1",
+	}
+	for path, expected := range tests {
+		if got := redactQuery(path); got != expected {
+			t.Errorf("%s: expected %s, got %s", path, expected, got)
+		}
+	}
+}
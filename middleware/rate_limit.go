@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitByIP - Membatasi jumlah request per IP untuk endpoint publik (misalnya registrasi)
+func RateLimitByIP(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if retryAfter := services.HitPublicEndpoint(c.Request.Context(), scope, c.ClientIP()); retryAfter > 0 {
+			seconds := int(retryAfter.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later", "retry_after": seconds})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
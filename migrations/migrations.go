@@ -0,0 +1,402 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// File migrasi: sql/<versi>_<nama>.up.sql dan sql/<versi>_<nama>.down.sql
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// Kunci advisory lock agar migrasi tidak dijalankan bersamaan oleh beberapa proses
+const migrationLockKey = 7310412
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Schema sementara tempat migrasi awal dibuat saat `migrate baseline` memeriksa database lama
+const baselineCheckSchema = "migrate_baseline_check"
+
+var (
+	// ErrSchemaBehind - Database belum menjalankan semua migrasi yang dikenal server
+	ErrSchemaBehind = errors.New("database schema is behind")
+	// ErrBaselineMismatch - Tabel / kolom database lama tidak sama dengan migrasi awal
+	ErrBaselineMismatch = errors.New("database schema does not match the initial migration")
+)
+
+// SchemaMigration - Baris tabel schema_migrations (versi yang sudah dijalankan)
+type SchemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Migration - Satu migrasi bernomor
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status - Status satu migrasi (AppliedAt nil = belum dijalankan)
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// Load - Membaca semua migrasi dari file yang di-embed, urut berdasarkan versi
+func Load() ([]Migration, error) {
+	return loadFrom(files)
+}
+
+// loadFrom - Membaca migrasi dari direktori sql di fsys
+func loadFrom(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		content, err := fs.ReadFile(fsys, path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// ensureVersionTable - Membuat tabel schema_migrations jika belum ada
+func ensureVersionTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamptz NOT NULL
+	)`).Error
+}
+
+// appliedVersions - Versi yang sudah dijalankan beserta waktunya (kosong jika tabel versi belum ada)
+func appliedVersions(db *gorm.DB) (map[int64]SchemaMigration, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return map[int64]SchemaMigration{}, nil
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// GetStatus - Status semua migrasi, termasuk versi di database yang tidak dikenal server ini
+func GetStatus(db *gorm.DB) ([]Status, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name + " (unknown)", AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending - Migrasi yang belum dijalankan
+func Pending(db *gorm.DB) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// RequireCurrent - Error jika masih ada migrasi yang belum dijalankan (dipakai saat server start)
+func RequireCurrent(db *gorm.DB) error {
+	pending, err := Pending(db)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// Database lama dari AutoMigrate: tabel sudah ada tetapi belum ada versi yang tercatat
+	if pending[0].Version == 1 && db.Migrator().HasTable("users") {
+		return fmt.Errorf("%w: tables were created by AutoMigrate, run `go run ./cmd/migrate baseline` then `go run ./cmd/migrate up`",
+			ErrSchemaBehind)
+	}
+	return fmt.Errorf("%w: %d pending migration(s) starting at %d_%s, run `go run ./cmd/migrate up`",
+		ErrSchemaBehind, len(pending), pending[0].Version, pending[0].Name)
+}
+
+// Up - Menjalankan migrasi yang belum dijalankan (steps <= 0 berarti semua).
+// Setiap migrasi berjalan dalam transaksinya sendiri bersama pencatatan versinya.
+func Up(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := ensureVersionTable(db); err != nil {
+		return nil, err
+	}
+	pending, err := Pending(db)
+	if err != nil {
+		return nil, err
+	}
+	if steps > 0 && steps < len(pending) {
+		pending = pending[:steps]
+	}
+
+	done := []Migration{}
+	for _, migration := range pending {
+		applied := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Bisa saja sudah dijalankan proses lain selama menunggu lock
+			if recorded, err := lockVersion(tx, migration.Version); err != nil || recorded {
+				return err
+			}
+
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			applied = true
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if applied {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// Down - Membatalkan migrasi terakhir yang sudah dijalankan sebanyak steps (minimal 1)
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if err := ensureVersionTable(db); err != nil {
+		return nil, err
+	}
+	if steps < 1 {
+		steps = 1
+	}
+
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		rolledBack := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// Bisa saja sudah dibatalkan proses lain selama menunggu lock
+			if recorded, err := lockVersion(tx, migration.Version); err != nil || !recorded {
+				return err
+			}
+
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			rolledBack = true
+			return tx.Where("version = ?", migration.Version).Delete(&SchemaMigration{}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("rollback of %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		if rolledBack {
+			done = append(done, migration)
+		}
+	}
+	return done, nil
+}
+
+// lockVersion - Mengambil advisory lock migrasi sampai transaksi selesai, lalu membaca ulang
+// apakah versi sudah tercatat (status sebelum lock bisa sudah diubah proses lain)
+func lockVersion(tx *gorm.DB, version int64) (bool, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+		return false, err
+	}
+
+	var count int64
+	if err := tx.Model(&SchemaMigration{}).Where("version = ?", version).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// schemaColumn - Satu kolom dari information_schema.columns
+type schemaColumn struct {
+	TableName  string
+	ColumnName string
+	UdtName    string
+}
+
+// Baseline - Menandai database lama yang dibuat AutoMigrate sebagai versi 1 (migrasi awal) tanpa
+// menjalankannya. Migrasi awal dibuat dulu di schema sementara lalu setiap tabel & kolomnya
+// dibandingkan dengan database; jika ada yang hilang atau tipenya berbeda, tidak ada yang ditandai.
+func Baseline(db *gorm.DB) (Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return Migration{}, err
+	}
+	if len(migrations) == 0 {
+		return Migration{}, errors.New("no migrations found")
+	}
+	initial := migrations[0]
+
+	if err := ensureVersionTable(db); err != nil {
+		return Migration{}, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", migrationLockKey).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&SchemaMigration{}).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("database already has migrations, baseline is only for databases created by AutoMigrate")
+		}
+
+		expected, err := initialColumns(tx, initial)
+		if err != nil {
+			return err
+		}
+
+		var live []schemaColumn
+		err = tx.Raw(`SELECT table_name, column_name, udt_name FROM information_schema.columns
+			WHERE table_schema = current_schema()`).Scan(&live).Error
+		if err != nil {
+			return err
+		}
+		liveTypes := make(map[string]string, len(live))
+		for _, column := range live {
+			liveTypes[column.TableName+"."+column.ColumnName] = column.UdtName
+		}
+
+		problems := []string{}
+		for _, column := range expected {
+			name := column.TableName + "." + column.ColumnName
+			udtName, ok := liveTypes[name]
+			switch {
+			case !ok:
+				problems = append(problems, name+" is missing")
+			case udtName != column.UdtName:
+				problems = append(problems, fmt.Sprintf("%s is %s, expected %s", name, udtName, column.UdtName))
+			}
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%w: %s", ErrBaselineMismatch, strings.Join(problems, "; "))
+		}
+
+		return tx.Create(&SchemaMigration{Version: initial.Version, Name: initial.Name, AppliedAt: time.Now()}).Error
+	})
+	return initial, err
+}
+
+// initialColumns - Kolom yang dibuat migrasi awal, dibaca dari schema sementara yang langsung di-rollback
+func initialColumns(tx *gorm.DB, initial Migration) ([]schemaColumn, error) {
+	if err := tx.SavePoint("baseline_check").Error; err != nil {
+		return nil, err
+	}
+
+	var columns []schemaColumn
+	statements := []string{
+		"CREATE SCHEMA " + baselineCheckSchema,
+		"SET LOCAL search_path TO " + baselineCheckSchema,
+		initial.Up,
+	}
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			tx.RollbackTo("baseline_check")
+			return nil, fmt.Errorf("initial migration check failed: %w", err)
+		}
+	}
+	err := tx.Raw(`SELECT table_name, column_name, udt_name FROM information_schema.columns
+		WHERE table_schema = ? ORDER BY table_name, ordinal_position`, baselineCheckSchema).Scan(&columns).Error
+
+	// Schema sementara dan search_path kembali seperti semula
+	if rollbackErr := tx.RollbackTo("baseline_check").Error; err == nil {
+		err = rollbackErr
+	}
+	return columns, err
+}
@@ -0,0 +1,187 @@
+package migrations
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadFromSortsAndPairsFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0002_add_index.up.sql":      {Data: []byte("CREATE INDEX a ON t (x);")},
+		"sql/0002_add_index.down.sql":    {Data: []byte("DROP INDEX a;")},
+		"sql/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE t (x int);")},
+		"sql/0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	migrations, err := loadFrom(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "create_table" || migrations[1].Version != 2 || migrations[1].Name != "add_index" {
+		t.Fatalf("unexpected order or names: %+v", migrations)
+	}
+	if migrations[0].Up != "CREATE TABLE t (x int);" || migrations[0].Down != "DROP TABLE t;" {
+		t.Fatalf("up/down content mixed up: %+v", migrations[0])
+	}
+}
+
+func TestLoadFromRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"sql/0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (x int);")},
+		},
+		"missing up": {
+			"sql/0001_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+		"invalid name": {
+			"sql/create_table.sql": {Data: []byte("CREATE TABLE t (x int);")},
+		},
+		"name mismatch": {
+			"sql/0001_create_table.up.sql": {Data: []byte("CREATE TABLE t (x int);")},
+			"sql/0001_create_tbl.down.sql": {Data: []byte("DROP TABLE t;")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadFrom(fsys); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrationsLoad(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, migration := range migrations {
+		if migration.Version != int64(i+1) {
+			t.Fatalf("migration versions must be consecutive, got %d at position %d", migration.Version, i)
+		}
+	}
+}
+
+// openTestDB - Database kosong untuk test. TEST_DATABASE_DSN harus menunjuk database khusus test
+// karena schema public dihapus dan dibuat ulang.
+func openTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public").Error; err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUpDownUpRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := Up(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations) {
+		t.Fatalf("expected %d migrations applied, got %d", len(migrations), len(done))
+	}
+	if err := RequireCurrent(db); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err = Down(db, len(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations) {
+		t.Fatalf("expected %d migrations rolled back, got %d", len(migrations), len(done))
+	}
+	if db.Migrator().HasTable("users") {
+		t.Fatal("users table still exists after rolling back every migration")
+	}
+	if err := RequireCurrent(db); !errors.Is(err, ErrSchemaBehind) {
+		t.Fatalf("expected ErrSchemaBehind, got %v", err)
+	}
+
+	if _, err := Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+	if done, err := Up(db, 0); err != nil || len(done) != 0 {
+		t.Fatalf("second up should be a no-op, got %d migrations, err %v", len(done), err)
+	}
+}
+
+func TestDownTwiceDoesNotRepeat(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := Down(db, 1)
+	if err != nil || len(first) != 1 {
+		t.Fatalf("expected one rollback, got %d, err %v", len(first), err)
+	}
+	second, err := Down(db, 1)
+	if err != nil || len(second) != 1 || second[0].Version == first[0].Version {
+		t.Fatalf("second rollback should undo the previous migration, got %+v, err %v", second, err)
+	}
+}
+
+func TestBaselineChecksColumns(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := Up(db, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Database yang sama persis dengan migrasi awal boleh ditandai
+	if err := db.Exec("DELETE FROM schema_migrations").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Baseline(db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Baseline(db); err == nil {
+		t.Fatal("baseline must refuse a database that already has migrations")
+	}
+
+	// Kolom hilang (AutoMigrate versi lama) tidak boleh ditandai
+	statements := []string{"DELETE FROM schema_migrations", "ALTER TABLE users DROP COLUMN deleted_at"}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := Baseline(db)
+	if !errors.Is(err, ErrBaselineMismatch) || !strings.Contains(err.Error(), "users.deleted_at is missing") {
+		t.Fatalf("expected missing column error, got %v", err)
+	}
+	var count int64
+	db.Model(&SchemaMigration{}).Count(&count)
+	if count != 0 {
+		t.Fatal("baseline recorded a version despite the mismatch")
+	}
+	if db.Migrator().HasTable(baselineCheckSchema + ".users") {
+		t.Fatal("temporary baseline schema was not rolled back")
+	}
+}
@@ -0,0 +1,394 @@
+-- Skema awal (sama dengan hasil AutoMigrate versi terakhir). Database lama yang dibuat
+-- AutoMigrate tidak menjalankan file ini, tandai dengan `migrate baseline` (kolom diperiksa dulu).
+
+CREATE TABLE "organizations" (
+    "id" bigserial,
+    "name" text NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "uni_organizations_name" UNIQUE ("name")
+);
+
+CREATE TABLE "users" (
+    "id" bigserial,
+    "username" text NOT NULL,
+    "password" text NOT NULL,
+    "email" text NOT NULL,
+    "role" text DEFAULT 'patient',
+    "organization_id" bigint,
+    "full_name" text,
+    "date_of_birth" text,
+    "medical_history" text,
+    "address" text,
+    "province" text,
+    "city" text,
+    "postal_code" text,
+    "email_verified" boolean DEFAULT false,
+    "verification_sent_at" timestamptz,
+    "totp_secret" text,
+    "totp_enabled" boolean DEFAULT false,
+    "totp_last_counter" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_users_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT "uni_users_username" UNIQUE ("username"),
+    CONSTRAINT "uni_users_email" UNIQUE ("email")
+);
+CREATE INDEX "idx_users_deleted_at" ON "users" ("deleted_at");
+CREATE INDEX "idx_users_organization_id" ON "users" ("organization_id");
+
+CREATE TABLE "devices" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "organization_id" bigint,
+    "name" text NOT NULL,
+    "api_key" text,
+    "api_key_prefix" text,
+    "api_key_hash" text,
+    "previous_api_key_prefix" text,
+    "previous_api_key_hash" text,
+    "previous_api_key_expires_at" timestamptz,
+    "delay" bigint DEFAULT 10,
+    "current_state" text DEFAULT 'inactive',
+    "connectivity" text DEFAULT 'unknown',
+    "last_seen_at" timestamptz,
+    "last_ip" text,
+    "uptime" bigint,
+    "raw_retained_from" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_devices_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_devices_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT "uni_devices_api_key" UNIQUE ("api_key")
+);
+CREATE INDEX "idx_devices_previous_api_key_prefix" ON "devices" ("previous_api_key_prefix");
+CREATE INDEX "idx_devices_api_key_prefix" ON "devices" ("api_key_prefix");
+CREATE INDEX "idx_devices_organization_id" ON "devices" ("organization_id");
+
+CREATE TABLE "sensor_data" (
+    "id" bigserial,
+    "device_id" bigint NOT NULL,
+    "bpm" decimal,
+    "sp_o2" decimal,
+    "temp" decimal,
+    "timestamp" timestamptz,
+    "reading_id" varchar(64),
+    "seq" bigint,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sensor_data_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_sensor_device_time" ON "sensor_data" ("device_id","timestamp");
+CREATE UNIQUE INDEX "idx_sensor_device_seq" ON "sensor_data" ("device_id","seq");
+CREATE UNIQUE INDEX "idx_sensor_device_reading" ON "sensor_data" ("device_id","reading_id");
+
+CREATE TABLE "alert_rules" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "device_id" bigint,
+    "name" text,
+    "metric" text NOT NULL,
+    "operator" text NOT NULL,
+    "threshold" decimal NOT NULL,
+    "consecutive" bigint DEFAULT 1,
+    "severity" text DEFAULT 'warning',
+    "enabled" boolean DEFAULT true,
+    "created_by" bigint,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_alert_rules_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_alert_rules_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_alert_rules_device_id" ON "alert_rules" ("device_id");
+CREATE INDEX "idx_alert_rules_user_id" ON "alert_rules" ("user_id");
+
+CREATE TABLE "alerts" (
+    "id" bigserial,
+    "rule_id" bigint,
+    "user_id" bigint NOT NULL,
+    "device_id" bigint NOT NULL,
+    "type" text DEFAULT 'threshold',
+    "sensor_data_id" bigint,
+    "metric" text,
+    "value" decimal,
+    "threshold" decimal,
+    "severity" text,
+    "message" text,
+    "status" text DEFAULT 'open',
+    "triggered_at" timestamptz,
+    "acknowledged_at" timestamptz,
+    "acknowledged_by" bigint,
+    "resolved_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_alerts_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_alerts_rule" FOREIGN KEY ("rule_id") REFERENCES "alert_rules"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT "fk_alerts_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_alerts_user_id" ON "alerts" ("user_id");
+CREATE INDEX "idx_alerts_rule_id" ON "alerts" ("rule_id");
+CREATE INDEX "idx_alerts_status" ON "alerts" ("status");
+CREATE INDEX "idx_alerts_device_id" ON "alerts" ("device_id");
+
+CREATE TABLE "notification_channels" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "type" text NOT NULL,
+    "target" text NOT NULL,
+    "secret" text,
+    "enabled" boolean DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_notification_channels_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_notification_channels_user_id" ON "notification_channels" ("user_id");
+
+CREATE TABLE "notification_settings" (
+    "user_id" bigserial,
+    "quiet_hours_enabled" boolean DEFAULT false,
+    "quiet_hours_start" text DEFAULT '22:00',
+    "quiet_hours_end" text DEFAULT '07:00',
+    "timezone" text DEFAULT 'UTC',
+    "critical_bypasses_qh" boolean DEFAULT true,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("user_id"),
+    CONSTRAINT "fk_notification_settings_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE "notification_outboxes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "alert_id" bigint,
+    "channel_id" bigint,
+    "type" text NOT NULL,
+    "target" text NOT NULL,
+    "secret" text,
+    "subject" text,
+    "body" text,
+    "payload" text,
+    "status" text DEFAULT 'pending',
+    "attempts" bigint DEFAULT 0,
+    "next_attempt_at" timestamptz,
+    "last_error" text,
+    "sent_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_notification_outboxes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_notification_outboxes_alert" FOREIGN KEY ("alert_id") REFERENCES "alerts"("id") ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT "fk_notification_outboxes_channel" FOREIGN KEY ("channel_id") REFERENCES "notification_channels"("id") ON DELETE SET NULL ON UPDATE CASCADE
+);
+CREATE INDEX "idx_notification_outboxes_user_id" ON "notification_outboxes" ("user_id");
+CREATE INDEX "idx_outbox_due" ON "notification_outboxes" ("status","next_attempt_at");
+CREATE INDEX "idx_notification_outboxes_alert_id" ON "notification_outboxes" ("alert_id");
+
+CREATE TABLE "device_status_events" (
+    "id" bigserial,
+    "device_id" bigint NOT NULL,
+    "status" text NOT NULL,
+    "uptime" bigint,
+    "occurred_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_device_status_events_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_device_status_time" ON "device_status_events" ("device_id","occurred_at");
+
+CREATE TABLE "sessions" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "refresh_token_hash" text NOT NULL,
+    "previous_refresh_token_hash" text,
+    "user_agent" text,
+    "ip" text,
+    "last_used_at" timestamptz,
+    "expires_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_sessions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_sessions_previous_refresh_token_hash" ON "sessions" ("previous_refresh_token_hash");
+CREATE UNIQUE INDEX "idx_sessions_refresh_token_hash" ON "sessions" ("refresh_token_hash");
+CREATE INDEX "idx_sessions_user_id" ON "sessions" ("user_id");
+
+CREATE TABLE "password_reset_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token_hash" text NOT NULL,
+    "expires_at" timestamptz,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_password_reset_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX "idx_password_reset_tokens_token_hash" ON "password_reset_tokens" ("token_hash");
+CREATE INDEX "idx_password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_recovery_codes_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_recovery_codes_code_hash" ON "recovery_codes" ("code_hash");
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE "system_settings" (
+    "key" varchar(100),
+    "value" text NOT NULL,
+    "updated_by" bigint,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("key")
+);
+
+CREATE TABLE "login_throttles" (
+    "key" varchar(255),
+    "count" bigint NOT NULL DEFAULT 0,
+    "last_hit" timestamptz,
+    "blocked_until" timestamptz,
+    "locked" boolean DEFAULT false,
+    PRIMARY KEY ("key")
+);
+CREATE INDEX "idx_login_throttles_blocked_until" ON "login_throttles" ("blocked_until");
+
+CREATE TABLE "security_events" (
+    "id" bigserial,
+    "type" varchar(50) NOT NULL,
+    "user_id" bigint,
+    "username" text,
+    "ip" text,
+    "user_agent" text,
+    "detail" text,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_security_events_created_at" ON "security_events" ("created_at");
+CREATE INDEX "idx_security_events_username" ON "security_events" ("username");
+CREATE INDEX "idx_security_events_user_id" ON "security_events" ("user_id");
+CREATE INDEX "idx_security_events_type" ON "security_events" ("type");
+
+CREATE TABLE "access_grants" (
+    "id" bigserial,
+    "patient_id" bigint NOT NULL,
+    "grantee_email" text NOT NULL,
+    "grantee_id" bigint,
+    "scope" varchar(20) NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "expires_at" timestamptz,
+    "accepted_at" timestamptz,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_access_grants_patient" FOREIGN KEY ("patient_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_access_grants_grantee" FOREIGN KEY ("grantee_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_access_grants_status" ON "access_grants" ("status");
+CREATE INDEX "idx_access_grants_grantee_id" ON "access_grants" ("grantee_id");
+CREATE INDEX "idx_access_grants_grantee_email" ON "access_grants" ("grantee_email");
+CREATE INDEX "idx_access_grants_patient_id" ON "access_grants" ("patient_id");
+
+CREATE TABLE "audit_logs" (
+    "id" bigserial,
+    "actor_id" bigint,
+    "actor_role" varchar(50),
+    "action" varchar(50) NOT NULL,
+    "method" varchar(10),
+    "path" text,
+    "target_user_id" bigint,
+    "target_device_id" bigint,
+    "ip" text,
+    "user_agent" text,
+    "outcome" varchar(20) NOT NULL,
+    "status_code" bigint,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX "idx_audit_logs_actor_id" ON "audit_logs" ("actor_id");
+CREATE INDEX "idx_audit_logs_created_at" ON "audit_logs" ("created_at");
+CREATE INDEX "idx_audit_logs_outcome" ON "audit_logs" ("outcome");
+CREATE INDEX "idx_audit_logs_target_device_id" ON "audit_logs" ("target_device_id");
+CREATE INDEX "idx_audit_logs_target_user_id" ON "audit_logs" ("target_user_id");
+CREATE INDEX "idx_audit_logs_action" ON "audit_logs" ("action");
+
+CREATE TABLE "data_exports" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "status" varchar(20) NOT NULL DEFAULT 'pending',
+    "file_path" text,
+    "size_bytes" bigint,
+    "readings" bigint,
+    "error" text,
+    "created_at" timestamptz,
+    "completed_at" timestamptz,
+    "expires_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_data_exports_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_data_exports_status" ON "data_exports" ("status");
+CREATE INDEX "idx_data_exports_user_id" ON "data_exports" ("user_id");
+
+CREATE TABLE "retention_policies" (
+    "id" bigserial,
+    "organization_id" bigint,
+    "user_id" bigint,
+    "raw_days" bigint NOT NULL,
+    "hourly_days" bigint NOT NULL,
+    "daily_days" bigint NOT NULL,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_retention_policies_organization" FOREIGN KEY ("organization_id") REFERENCES "organizations"("id") ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT "fk_retention_policies_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE UNIQUE INDEX "idx_retention_policies_organization_id" ON "retention_policies" ("organization_id");
+CREATE UNIQUE INDEX "idx_retention_policies_user_id" ON "retention_policies" ("user_id");
+
+CREATE TABLE "sensor_rollup_hourly" (
+    "device_id" bigint,
+    "bucket_start" timestamptz,
+    "count" bigint NOT NULL,
+    "bpm_min" decimal,
+    "bpm_max" decimal,
+    "bpm_sum" decimal,
+    "bpm_median" decimal,
+    "sp_o2_min" decimal,
+    "sp_o2_max" decimal,
+    "sp_o2_sum" decimal,
+    "sp_o2_median" decimal,
+    "temp_min" decimal,
+    "temp_max" decimal,
+    "temp_sum" decimal,
+    "temp_median" decimal,
+    PRIMARY KEY ("device_id","bucket_start"),
+    CONSTRAINT "fk_sensor_rollup_hourly_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE "sensor_rollup_daily" (
+    "device_id" bigint,
+    "bucket_start" timestamptz,
+    "count" bigint NOT NULL,
+    "bpm_min" decimal,
+    "bpm_max" decimal,
+    "bpm_sum" decimal,
+    "bpm_median" decimal,
+    "sp_o2_min" decimal,
+    "sp_o2_max" decimal,
+    "sp_o2_sum" decimal,
+    "sp_o2_median" decimal,
+    "temp_min" decimal,
+    "temp_max" decimal,
+    "temp_sum" decimal,
+    "temp_median" decimal,
+    PRIMARY KEY ("device_id","bucket_start"),
+    CONSTRAINT "fk_sensor_rollup_daily_device" FOREIGN KEY ("device_id") REFERENCES "devices"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Gagal jika sudah ada seq yang sama untuk satu device (setelah reboot), hapus duplikatnya dulu
+DROP INDEX IF EXISTS "idx_sensor_device_seq";
+CREATE UNIQUE INDEX "idx_sensor_device_seq" ON "sensor_data" ("device_id","seq");
+ALTER TABLE "sensor_data" DROP COLUMN "boot_id";
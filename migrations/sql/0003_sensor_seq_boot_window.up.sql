@@ -0,0 +1,5 @@
+-- Nomor urut (seq) perangkat bisa mulai dari awal setelah reboot / flash ulang firmware, sehingga
+-- (device_id, seq) tidak lagi unik. Duplikat seq dicek per boot_id dalam rentang waktu tertentu.
+ALTER TABLE "sensor_data" ADD COLUMN "boot_id" varchar(64);
+DROP INDEX IF EXISTS "idx_sensor_device_seq";
+CREATE INDEX "idx_sensor_device_seq" ON "sensor_data" ("device_id","seq","timestamp");
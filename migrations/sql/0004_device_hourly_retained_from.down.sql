@@ -0,0 +1 @@
+ALTER TABLE "devices" DROP COLUMN "hourly_retained_from";
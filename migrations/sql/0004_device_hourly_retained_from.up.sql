@@ -0,0 +1,2 @@
+-- Batas ringkasan per jam yang masih tersimpan, sebelum waktu ini data sensor dibaca dari ringkasan per hari
+ALTER TABLE "devices" ADD COLUMN "hourly_retained_from" timestamptz;
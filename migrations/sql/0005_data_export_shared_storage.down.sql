@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "data_export_chunks";
+DROP INDEX IF EXISTS "idx_data_exports_lease";
+DROP INDEX IF EXISTS "idx_data_exports_user_in_progress";
+UPDATE "data_exports" SET "status" = 'expired' WHERE "status" = 'completed';
+ALTER TABLE "data_exports" ADD COLUMN "file_path" text;
+ALTER TABLE "data_exports" DROP COLUMN "attempts";
+ALTER TABLE "data_exports" DROP COLUMN "lease_expires_at";
+ALTER TABLE "data_exports" DROP COLUMN "lease_owner";
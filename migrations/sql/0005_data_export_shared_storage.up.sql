@@ -0,0 +1,26 @@
+-- File export disimpan di database (dibaca semua instance), job export dikerjakan lewat lease
+-- (lease_owner / lease_expires_at) sehingga instance lain bisa melanjutkan job dari instance yang mati.
+ALTER TABLE "data_exports" ADD COLUMN "lease_owner" text;
+ALTER TABLE "data_exports" ADD COLUMN "lease_expires_at" timestamptz;
+ALTER TABLE "data_exports" ADD COLUMN "attempts" bigint NOT NULL DEFAULT 0;
+
+-- File di EXPORT_DIR lokal tidak ikut dipindahkan, export yang sudah selesai harus diminta ulang
+UPDATE "data_exports" SET "status" = 'expired' WHERE "status" = 'completed';
+ALTER TABLE "data_exports" DROP COLUMN "file_path";
+
+-- Hanya satu export yang berjalan per user
+UPDATE "data_exports" SET "status" = 'failed', "error" = 'Export was interrupted, please try again'
+WHERE "status" IN ('pending', 'running') AND "id" NOT IN (
+    SELECT MAX("id") FROM "data_exports" WHERE "status" IN ('pending', 'running') GROUP BY "user_id"
+);
+CREATE UNIQUE INDEX "idx_data_exports_user_in_progress" ON "data_exports" ("user_id") WHERE "status" IN ('pending', 'running');
+CREATE INDEX "idx_data_exports_lease" ON "data_exports" ("status","lease_expires_at");
+
+CREATE TABLE "data_export_chunks" (
+    "export_id" bigint,
+    "attempt" bigint,
+    "seq" bigint,
+    "data" bytea NOT NULL,
+    PRIMARY KEY ("export_id","attempt","seq"),
+    CONSTRAINT "fk_data_export_chunks_export" FOREIGN KEY ("export_id") REFERENCES "data_exports"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
@@ -0,0 +1,9 @@
+-- mfa_token hanya boleh ditukar dengan sesi satu kali, jti token yang sudah dipakai disimpan sampai token kedaluwarsa
+CREATE TABLE "used_mfa_tokens" (
+    "jti" varchar(64),
+    "user_id" bigint NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("jti"),
+    CONSTRAINT "fk_used_mfa_tokens_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE ON UPDATE CASCADE
+);
+CREATE INDEX "idx_used_mfa_tokens_expires_at" ON "used_mfa_tokens" ("expires_at");
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_access_grants_patient_email_open";
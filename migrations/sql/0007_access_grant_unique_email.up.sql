@@ -0,0 +1,9 @@
+-- Hanya satu undangan / akses yang berjalan per pasien dan email grantee. Duplikat lama dicabut,
+-- akses yang sudah aktif diutamakan lalu yang terbaru.
+UPDATE "access_grants" SET "status" = 'revoked', "revoked_at" = NOW()
+WHERE "status" IN ('pending', 'active') AND "id" NOT IN (
+    SELECT DISTINCT ON ("patient_id", "grantee_email") "id" FROM "access_grants"
+    WHERE "status" IN ('pending', 'active')
+    ORDER BY "patient_id", "grantee_email", ("status" = 'active') DESC, "id" DESC
+);
+CREATE UNIQUE INDEX "idx_access_grants_patient_email_open" ON "access_grants" ("patient_id","grantee_email") WHERE "status" IN ('pending', 'active');
@@ -0,0 +1,2 @@
+-- Keterangan tambahan audit log (misalnya jumlah data yang dihapus saat akun di-purge), sebelumnya ditulis ke kolom path
+ALTER TABLE "audit_logs" ADD COLUMN "details" jsonb;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "idx_alerts_rule_device_active";
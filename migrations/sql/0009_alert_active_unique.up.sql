@@ -0,0 +1,9 @@
+-- Hanya satu alert aktif (open / acknowledged) per rule dan device, sehingga evaluasi paralel untuk device
+-- yang sama (HTTP & MQTT, beberapa batch) tidak membuat alert dan notifikasi ganda. Duplikat lama di-resolve.
+UPDATE "alerts" SET "status" = 'resolved', "resolved_at" = NOW()
+WHERE "rule_id" IS NOT NULL AND "status" IN ('open', 'acknowledged') AND "id" NOT IN (
+    SELECT MIN("id") FROM "alerts"
+    WHERE "rule_id" IS NOT NULL AND "status" IN ('open', 'acknowledged')
+    GROUP BY "rule_id", "device_id"
+);
+CREATE UNIQUE INDEX "idx_alerts_rule_device_active" ON "alerts" ("rule_id","device_id") WHERE "status" IN ('open', 'acknowledged');
@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Status alert
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+// Jenis alert
+const (
+	AlertTypeThreshold = "threshold"
+	AlertTypeOffline   = "offline"
+)
+
+// Model AlertRule (Batas nilai vital per pasien)
+type AlertRule struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	User        User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	DeviceID    *uint     `gorm:"index" json:"device_id"` // nil = berlaku untuk semua device milik user
+	Device      *Device   `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Name        string    `json:"name"`
+	Metric      string    `gorm:"not null" json:"metric"`   // bpm, spo2, temp
+	Operator    string    `gorm:"not null" json:"operator"` // <, <=, >, >=
+	Threshold   float64   `gorm:"not null" json:"threshold"`
+	Consecutive int       `gorm:"default:1" json:"consecutive"` // Jumlah data berturut-turut yang harus melanggar
+	Severity    string    `gorm:"default:'warning'" json:"severity"`
+	Enabled     bool      `gorm:"default:true" json:"enabled"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Model Alert (Kejadian pelanggaran AlertRule)
+type Alert struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	RuleID         *uint      `gorm:"index" json:"rule_id"`
+	Rule           *AlertRule `gorm:"foreignKey:RuleID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	User           User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	DeviceID       uint       `gorm:"not null;index" json:"device_id"`
+	Device         Device     `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Type           string     `gorm:"default:'threshold'" json:"type"` // threshold, offline
+	SensorDataID   *uint      `json:"sensor_data_id"`                  // Data sensor yang memicu alert
+	Metric         string     `json:"metric"`
+	Value          float64    `json:"value"`
+	Threshold      float64    `json:"threshold"`
+	Severity       string     `json:"severity"`
+	Message        string     `json:"message"`
+	Status         string     `gorm:"default:'open';index" json:"status"`
+	TriggeredAt    time.Time  `json:"triggered_at"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	AcknowledgedBy *uint      `json:"acknowledged_by"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Hasil akses yang dicatat di audit log
+const (
+	AuditSuccess = "success"
+	AuditDenied  = "denied" // Ditolak (401 / 403 / 404)
+	AuditError   = "error"
+)
+
+// ActorRole untuk akses yang dilakukan perangkat (API Key / MQTT), bukan user
+const AuditActorDevice = "device"
+
+// ErrAuditLogImmutable - Audit log hanya boleh ditambah, tidak boleh diubah atau dihapus
+var ErrAuditLogImmutable = errors.New("audit log is append-only")
+
+// Model AuditLog (Siapa mengakses data profil / vital sign pasien dan kapan).
+// Tanpa foreign key agar catatan tetap ada setelah user atau device dihapus.
+type AuditLog struct {
+	ID             uint                   `gorm:"primaryKey" json:"id"`
+	ActorID        *uint                  `gorm:"index" json:"actor_id"`
+	ActorRole      string                 `gorm:"size:50" json:"actor_role"`
+	Action         string                 `gorm:"size:50;not null;index" json:"action"` // Contoh: profile.read, vitals.read, user.update
+	Method         string                 `gorm:"size:10" json:"method"`
+	Path           string                 `json:"path"`
+	TargetUserID   *uint                  `gorm:"index" json:"target_user_id"`   // Pasien pemilik data
+	TargetDeviceID *uint                  `gorm:"index" json:"target_device_id"` // Device yang datanya diakses
+	IP             string                 `json:"ip"`
+	UserAgent      string                 `json:"user_agent"`
+	Outcome        string                 `gorm:"size:20;not null;index" json:"outcome"`
+	StatusCode     int                    `json:"status_code"`
+	Details        map[string]interface{} `gorm:"type:jsonb;serializer:json" json:"details,omitempty"` // Keterangan tambahan dari job sistem
+	CreatedAt      time.Time              `gorm:"index" json:"created_at"`
+}
+
+// BeforeUpdate - Menolak perubahan audit log
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete - Menolak penghapusan audit log
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Status konektivitas device
+const (
+	DeviceOnline  = "online"
+	DeviceOffline = "offline"
+	DeviceReboot  = "reboot"
+)
+
+// Model DeviceStatusEvent (Riwayat online / offline / reboot device)
+type DeviceStatusEvent struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	DeviceID   uint      `gorm:"not null;index:idx_device_status_time,priority:1" json:"device_id"`
+	Device     Device    `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Status     string    `gorm:"not null" json:"status"`
+	Uptime     *int64    `json:"uptime"`
+	OccurredAt time.Time `gorm:"index:idx_device_status_time,priority:2" json:"occurred_at"`
+}
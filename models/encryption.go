@@ -0,0 +1,23 @@
+package models
+
+import (
+	"backend/fieldcrypt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Field dengan tag `gorm:"serializer:encrypted"` dienkripsi (AES-GCM, envelope encryption)
+// sebelum disimpan dan didekripsi otomatis saat dibaca
+func init() {
+	schema.RegisterSerializer("encrypted", fieldcrypt.Serializer{})
+}
+
+// BeforeCreate - Mengambil id dari sequence sebelum INSERT karena ciphertext field terenkripsi
+// terikat ke primary key baris (lihat fieldcrypt.RowAAD)
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.ID != 0 {
+		return nil
+	}
+	return tx.Raw("SELECT nextval(pg_get_serial_sequence('users', 'id'))").Scan(&u.ID).Error
+}
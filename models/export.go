@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Status export data pasien
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired" // File sudah dihapus setelah masa berlaku habis
+)
+
+// Model DataExport (Permintaan "download my data": profil, device dan riwayat data sensor dalam ZIP).
+// Job dikerjakan instance yang memegang lease; lease diperpanjang selama export berjalan.
+type DataExport struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	UserID         uint       `gorm:"not null;index" json:"user_id"`
+	User           User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Status         string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	LeaseOwner     string     `json:"-"` // Instance yang sedang mengerjakan export
+	LeaseExpiresAt *time.Time `json:"-"` // Instance lain boleh mengambil alih setelah waktu ini
+	Attempts       int        `gorm:"not null;default:0" json:"-"`
+	SizeBytes      int64      `json:"size_bytes"`
+	Readings       int64      `json:"readings"` // Jumlah data sensor di dalam export
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	ExpiresAt      *time.Time `json:"expires_at"` // File dihapus setelah waktu ini
+}
+
+// Model DataExportChunk (Isi file ZIP export, dipotong per beberapa MB agar bisa dibaca semua instance).
+// Attempt memisahkan potongan dari percobaan yang berbeda jika lease sempat diambil alih.
+type DataExportChunk struct {
+	ExportID uint       `gorm:"primaryKey;autoIncrement:false"`
+	Export   DataExport `gorm:"foreignKey:ExportID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;"`
+	Attempt  int        `gorm:"primaryKey;autoIncrement:false"`
+	Seq      int        `gorm:"primaryKey;autoIncrement:false"`
+	Data     []byte     `gorm:"not null"`
+}
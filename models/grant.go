@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Cakupan akses yang diberikan pasien (berurutan dari yang paling terbatas)
+const (
+	GrantScopeViewVitals    = "view_vitals"          // Melihat data vital sign
+	GrantScopeViewAlerts    = "view_alerts"          // Melihat vital sign dan menerima alert
+	GrantScopeManageDevices = "manage_devices"       // Termasuk mengatur device (delay, status, API Key)
+	GrantScopeEditMedical   = "edit_medical_history" // Termasuk mengubah riwayat medis (hanya clinician)
+)
+
+// Status akses
+const (
+	GrantPending = "pending"
+	GrantActive  = "active"
+	GrantRevoked = "revoked"
+)
+
+// Model AccessGrant (Akses yang diberikan pasien ke caregiver / clinician, diundang lewat email)
+type AccessGrant struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	PatientID    uint       `gorm:"not null;index" json:"patient_id"`
+	Patient      User       `gorm:"foreignKey:PatientID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	GranteeEmail string     `gorm:"not null;index" json:"grantee_email"`
+	GranteeID    *uint      `gorm:"index" json:"grantee_id"` // Terisi setelah undangan diterima
+	Grantee      *User      `gorm:"foreignKey:GranteeID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Scope        string     `gorm:"size:20;not null" json:"scope"`
+	Status       string     `gorm:"size:20;not null;default:'pending';index" json:"status"`
+	ExpiresAt    *time.Time `json:"expires_at"` // Opsional, akses otomatis berakhir
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Jenis channel notifikasi
+const (
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelPush    = "push"
+)
+
+// Status pesan di outbox
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+// Model NotificationChannel (Tujuan notifikasi alert milik user)
+type NotificationChannel struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	Type      string    `gorm:"not null" json:"type"`   // email, webhook, push
+	Target    string    `gorm:"not null" json:"target"` // Alamat email, URL webhook, atau token push
+	Secret    string    `json:"-"`                      // Secret untuk tanda tangan webhook
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Model NotificationSetting (Preferensi notifikasi per user, termasuk quiet hours)
+type NotificationSetting struct {
+	UserID             uint      `gorm:"primaryKey" json:"user_id"`
+	User               User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	QuietHoursEnabled  bool      `gorm:"default:false" json:"quiet_hours_enabled"`
+	QuietHoursStart    string    `gorm:"default:'22:00'" json:"quiet_hours_start"` // Format HH:MM
+	QuietHoursEnd      string    `gorm:"default:'07:00'" json:"quiet_hours_end"`   // Format HH:MM
+	Timezone           string    `gorm:"default:'UTC'" json:"timezone"`            // Nama zona waktu IANA
+	CriticalBypassesQH bool      `gorm:"default:true" json:"critical_bypasses_quiet_hours"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// Model NotificationOutbox (Antrian notifikasi yang tahan restart)
+type NotificationOutbox struct {
+	ID            uint                 `gorm:"primaryKey" json:"id"`
+	UserID        uint                 `gorm:"not null;index" json:"user_id"`
+	User          User                 `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	AlertID       *uint                `gorm:"index" json:"alert_id"`
+	Alert         *Alert               `gorm:"foreignKey:AlertID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	ChannelID     *uint                `json:"channel_id"`
+	Channel       *NotificationChannel `gorm:"foreignKey:ChannelID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	Type          string               `gorm:"not null" json:"type"`
+	Target        string               `gorm:"not null" json:"target"`
+	Secret        string               `json:"-"`
+	Subject       string               `json:"subject"`
+	Body          string               `json:"body"`
+	Payload       string               `gorm:"type:text" json:"payload"` // JSON untuk webhook & push
+	Status        string               `gorm:"default:'pending';index:idx_outbox_due,priority:1" json:"status"`
+	Attempts      int                  `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time            `gorm:"index:idx_outbox_due,priority:2" json:"next_attempt_at"`
+	LastError     string               `json:"last_error"`
+	SentAt        *time.Time           `json:"sent_at"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Model Organization (Klinik / tenant yang memiliki user dan device)
+type Organization struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"unique;not null" json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
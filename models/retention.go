@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Model RetentionPolicy (Masa simpan data sensor per organisasi atau per user).
+// Policy user mengalahkan policy organisasi, policy organisasi mengalahkan default dari environment.
+type RetentionPolicy struct {
+	ID             uint          `gorm:"primaryKey" json:"id"`
+	OrganizationID *uint         `gorm:"uniqueIndex" json:"organization_id"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	UserID         *uint         `gorm:"uniqueIndex" json:"user_id"`
+	User           *User         `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	RawDays        int           `gorm:"not null" json:"raw_days"`    // Data mentah disimpan selama N hari, lalu diringkas
+	HourlyDays     int           `gorm:"not null" json:"hourly_days"` // Ringkasan per jam (0 = selamanya)
+	DailyDays      int           `gorm:"not null" json:"daily_days"`  // Ringkasan per hari (0 = selamanya)
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// SensorRollup - Ringkasan data sensor satu device dalam satu bucket waktu.
+// Disimpan jumlah (sum) agar rata-rata tetap benar saat digabung dengan data yang datang terlambat.
+// Median tidak bisa digabung tanpa data mentah, jadi bernilai nil untuk bucket yang sudah digabung.
+type SensorRollup struct {
+	DeviceID    uint      `gorm:"primaryKey;autoIncrement:false" json:"device_id"`
+	BucketStart time.Time `gorm:"primaryKey" json:"bucket_start"`
+	Count       int64     `gorm:"not null" json:"count"`
+	BPMMin      float64   `json:"bpm_min"`
+	BPMMax      float64   `json:"bpm_max"`
+	BPMSum      float64   `json:"bpm_sum"`
+	BPMMedian   *float64  `json:"bpm_median"`
+	SpO2Min     float64   `json:"spo2_min"`
+	SpO2Max     float64   `json:"spo2_max"`
+	SpO2Sum     float64   `json:"spo2_sum"`
+	SpO2Median  *float64  `json:"spo2_median"`
+	TempMin     float64   `json:"temp_min"`
+	TempMax     float64   `json:"temp_max"`
+	TempSum     float64   `json:"temp_sum"`
+	TempMedian  *float64  `json:"temp_median"`
+}
+
+// Model SensorRollupHourly (Ringkasan data sensor per jam)
+type SensorRollupHourly struct {
+	SensorRollup
+	Device Device `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+}
+
+// TableName - Nama tabel ringkasan per jam
+func (SensorRollupHourly) TableName() string {
+	return "sensor_rollup_hourly"
+}
+
+// Model SensorRollupDaily (Ringkasan data sensor per hari, UTC)
+type SensorRollupDaily struct {
+	SensorRollup
+	Device Device `gorm:"foreignKey:DeviceID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+}
+
+// TableName - Nama tabel ringkasan per hari
+func (SensorRollupDaily) TableName() string {
+	return "sensor_rollup_daily"
+}
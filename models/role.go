@@ -0,0 +1,30 @@
+package models
+
+// Role user
+const (
+	RolePatient    = "patient"
+	RoleCaregiver  = "caregiver"
+	RoleClinician  = "clinician"
+	RoleOrgAdmin   = "org-admin"
+	RoleSuperAdmin = "super-admin"
+)
+
+// Permission yang dimiliki role. Akhiran menunjukkan cakupan data:
+// own = milik sendiri, shared = dibagikan pasien ke user, all = semua pasien.
+const (
+	PermReadOwnVitals            = "vitals:read:own"
+	PermReadSharedVitals         = "vitals:read:shared"
+	PermReadAllVitals            = "vitals:read:all"
+	PermManageOwnDevices         = "devices:manage:own"
+	PermManageSharedDevices      = "devices:manage:shared"
+	PermManageAllDevices         = "devices:manage:all"
+	PermEditOwnMedicalHistory    = "medical_history:edit:own"
+	PermEditSharedMedicalHistory = "medical_history:edit:shared"
+	PermEditAllMedicalHistory    = "medical_history:edit:all"
+	PermManageAllAlertRules      = "alert_rules:manage:all" // Membuat / menghapus alert rule atas nama pasien
+	PermManageUsers              = "users:manage"
+	PermManageSecurity           = "security:manage"
+	PermManageOrganizations      = "organizations:manage" // Lintas organisasi (hanya super-admin)
+	PermReadAuditLog             = "audit:read"
+	PermManageRetention          = "retention:manage" // Masa simpan data sensor organisasi / user
+)
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Model RecoveryCode (Kode cadangan 2FA sekali pakai, hanya hash yang disimpan)
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	CodeHash  string     `gorm:"not null;index" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Model UsedMFAToken (jti mfa_token yang sudah ditukar dengan sesi, disimpan sampai token kedaluwarsa)
+type UsedMFAToken struct {
+	JTI       string    `gorm:"column:jti;primaryKey;size:64" json:"jti"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+// Model SystemSetting (Pengaturan global yang bisa diubah admin, disimpan sebagai key-value)
+type SystemSetting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `gorm:"not null" json:"value"`
+	UpdatedBy *uint     `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Model LoginThrottle (State rate limit login / registrasi untuk store SQL)
+type LoginThrottle struct {
+	Key          string    `gorm:"primaryKey;size:255" json:"key"`
+	Count        int       `gorm:"not null;default:0" json:"count"`
+	LastHit      time.Time `json:"last_hit"`
+	BlockedUntil time.Time `gorm:"index" json:"blocked_until"`
+	Locked       bool      `gorm:"default:false" json:"locked"`
+}
+
+// Jenis event pada security log
+const (
+	SecurityLoginFailed    = "login_failed"
+	SecurityAccountLocked  = "account_locked"
+	SecurityLockoutCleared = "lockout_cleared"
+	SecurityRateLimited    = "rate_limited"
+)
+
+// Model SecurityEvent (Security log: login gagal, lockout, dll)
+type SecurityEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Type      string    `gorm:"size:50;not null;index" json:"type"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	Username  string    `gorm:"index" json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Model Session (Sesi login, menyimpan refresh token yang di-rotasi)
+type Session struct {
+	ID                       uint       `gorm:"primaryKey" json:"id"`
+	UserID                   uint       `gorm:"not null;index" json:"user_id"`
+	User                     User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	RefreshTokenHash         string     `gorm:"uniqueIndex;not null" json:"-"`
+	PreviousRefreshTokenHash *string    `gorm:"index" json:"-"` // Untuk mendeteksi refresh token lama yang dipakai ulang
+	UserAgent                string     `json:"user_agent"`
+	IP                       string     `json:"ip"`
+	LastUsedAt               time.Time  `json:"last_used_at"`
+	ExpiresAt                time.Time  `json:"expires_at"`
+	RevokedAt                *time.Time `json:"revoked_at"`
+	CreatedAt                time.Time  `json:"created_at"`
+}
+
+// Model PasswordResetToken (Token reset password sekali pakai, hanya hash yang disimpan)
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	User      User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -1,46 +1,73 @@
 package models
 
-import "time"
+import (
+	"time"
 
-// Model User
+	"gorm.io/gorm"
+)
+
+// Model User (DateOfBirth, MedicalHistory, Address dan TOTPSecret dienkripsi di database)
 type User struct {
-	ID             uint       `gorm:"primaryKey" json:"id"`
-	Username       string     `gorm:"unique;not null" json:"username"`
-	Password       string     `gorm:"not null" json:"-"`
-	Email          string     `gorm:"unique;not null" json:"email"`
-	Role           string     `gorm:"default:'user'" json:"role"`
-	FullName       *string    `json:"full_name"`
-	DateOfBirth    *time.Time `json:"date_of_birth"`
-	MedicalHistory *string    `json:"medical_history"`
-	Address        *string    `json:"address"`
-	Province       *string    `json:"province"`
-	City           *string    `json:"city"`
-	PostalCode     *string    `json:"postal_code"`
-	EmailVerified  bool       `gorm:"default:false" json:"email_verified"`
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
+	ID                 uint           `gorm:"primaryKey" json:"id"`
+	Username           string         `gorm:"unique;not null" json:"username"`
+	Password           string         `gorm:"not null" json:"-"`
+	Email              string         `gorm:"unique;not null" json:"email"`
+	Role               string         `gorm:"default:'patient'" json:"role"`
+	OrganizationID     *uint          `gorm:"index" json:"organization_id"` // Klinik tempat user terdaftar (nil = belum ditempatkan)
+	Organization       *Organization  `gorm:"foreignKey:OrganizationID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	FullName           *string        `json:"full_name"`
+	DateOfBirth        *time.Time     `gorm:"type:text;serializer:encrypted" json:"date_of_birth"`
+	MedicalHistory     *string        `gorm:"type:text;serializer:encrypted" json:"medical_history"`
+	Address            *string        `gorm:"type:text;serializer:encrypted" json:"address"`
+	Province           *string        `json:"province"`
+	City               *string        `json:"city"`
+	PostalCode         *string        `json:"postal_code"`
+	EmailVerified      bool           `gorm:"default:false" json:"email_verified"`
+	VerificationSentAt *time.Time     `json:"-"`                                       // Waktu terakhir email verifikasi dikirim (untuk rate limit)
+	TOTPSecret         *string        `gorm:"type:text;serializer:encrypted" json:"-"` // Secret TOTP (2FA), terisi sejak setup
+	TOTPEnabled        bool           `gorm:"default:false" json:"two_factor_enabled"` // 2FA aktif setelah kode pertama diverifikasi
+	TOTPLastCounter    int64          `json:"-"`                                       // Counter TOTP terakhir yang dipakai (mencegah replay)
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at"` // Akun dihapus (masih bisa dipulihkan sampai dihapus permanen)
 }
 
 // Model Device (Alat yang dimiliki user)
 type Device struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	UserID       uint      `gorm:"not null" json:"user_id"`
-	User         User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
-	Name         string    `gorm:"not null" json:"name"`
-	APIKey       string    `gorm:"unique;not null" json:"api_key"` // API Key unik untuk ESP32-S3
-	Delay        int       `gorm:"default:10" json:"delay"`
-	CurrentState string    `gorm:"default:'inactive'" json:"current_state"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                      uint          `gorm:"primaryKey" json:"id"`
+	UserID                  uint          `gorm:"not null" json:"user_id"`
+	User                    User          `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
+	OrganizationID          *uint         `gorm:"index" json:"organization_id"` // Mengikuti organisasi pemilik device
+	Organization            *Organization `gorm:"foreignKey:OrganizationID;constraint:OnDelete:SET NULL,OnUpdate:CASCADE;" json:"-"`
+	Name                    string        `gorm:"not null" json:"name"`
+	APIKey                  *string       `gorm:"unique" json:"-"`             // Kolom lama (plaintext), dikosongkan setelah di-hash
+	APIKeyPrefix            string        `gorm:"index" json:"api_key_prefix"` // Beberapa karakter awal API Key untuk pencarian
+	APIKeyHash              string        `json:"-"`                           // SHA-256 dari API Key untuk ESP32-S3
+	PreviousAPIKeyPrefix    *string       `gorm:"index" json:"-"`              // API Key lama yang masih berlaku setelah rotasi
+	PreviousAPIKeyHash      *string       `json:"-"`
+	PreviousAPIKeyExpiresAt *time.Time    `json:"previous_api_key_expires_at"`
+	Delay                   int           `gorm:"default:10" json:"delay"`
+	CurrentState            string        `gorm:"default:'inactive'" json:"current_state"`
+	Connectivity            string        `gorm:"default:'unknown'" json:"connectivity"` // unknown, online, offline
+	LastSeenAt              *time.Time    `json:"last_seen_at"`
+	LastIP                  string        `json:"last_ip"`
+	Uptime                  *int64        `json:"uptime"`               // Uptime dari firmware (detik)
+	RawRetainedFrom         *time.Time    `json:"raw_retained_from"`    // Data sebelum waktu ini hanya tersedia sebagai ringkasan (rollup)
+	HourlyRetainedFrom      *time.Time    `json:"hourly_retained_from"` // Ringkasan per jam sebelum waktu ini sudah dihapus, tersisa ringkasan per hari
+	CreatedAt               time.Time     `json:"created_at"`
+	UpdatedAt               time.Time     `json:"updated_at"`
 }
 
 // Model SensorData (Data sensor dari alat)
 type SensorData struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	DeviceID  uint      `gorm:"not null" json:"device_id"`
+	DeviceID  uint      `gorm:"not null;uniqueIndex:idx_sensor_device_reading;index:idx_sensor_device_seq,priority:1;index:idx_sensor_device_time,priority:1" json:"device_id"`
 	Device    Device    `gorm:"foreignKey:DeviceID;references:ID;constraint:OnDelete:CASCADE,OnUpdate:CASCADE;" json:"-"`
 	BPM       float64   `json:"bpm"`
 	SpO2      float64   `json:"spo2"`
 	Temp      float64   `json:"temp"`
-	Timestamp time.Time `json:"timestamp"`
+	Timestamp time.Time `gorm:"index:idx_sensor_device_time,priority:2;index:idx_sensor_device_seq,priority:3" json:"timestamp"`
+	ReadingID *string   `gorm:"size:64;uniqueIndex:idx_sensor_device_reading" json:"reading_id,omitempty"` // Idempotency key dari perangkat
+	Seq       *int64    `gorm:"index:idx_sensor_device_seq,priority:2" json:"seq,omitempty"`               // Nomor urut per boot perangkat
+	BootID    *string   `gorm:"size:64" json:"boot_id,omitempty"`                                          // ID boot perangkat, seq dimulai ulang setiap boot
 }
@@ -0,0 +1,240 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/services"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Client MQTT global, nil jika bridge tidak diaktifkan
+var Client mqtt.Client
+
+// Topik yang dipakai perangkat
+const (
+	sensorTopic = "devices/+/sensor"      // Perangkat -> server: data sensor
+	ackTopic    = "devices/%d/sensor/ack" // Server -> perangkat: hasil penyimpanan
+	configTopic = "devices/%d/config"     // Server -> perangkat: delay & current_state (retained)
+)
+
+// Payload data sensor yang dikirim perangkat lewat MQTT
+type sensorPayload struct {
+	APIKey    string     `json:"api_key"`
+	BPM       *float64   `json:"bpm"`
+	SpO2      *float64   `json:"spo2"`
+	Temp      *float64   `json:"temp"`
+	Timestamp *time.Time `json:"timestamp"`
+	ReadingID *string    `json:"reading_id"`
+	Seq       *int64     `json:"seq"`
+	BootID    *string    `json:"boot_id"`
+	Uptime    *int64     `json:"uptime"` // Uptime firmware (detik)
+}
+
+// Payload balasan ke perangkat
+type ackPayload struct {
+	ReadingID      *string `json:"reading_id,omitempty"`
+	Seq            *int64  `json:"seq,omitempty"`
+	ID             uint    `json:"id,omitempty"`
+	Duplicate      bool    `json:"duplicate,omitempty"`
+	Folded         bool    `json:"folded,omitempty"` // Digabung ke ringkasan, tidak disimpan sebagai data mentah
+	MissedReadings int64   `json:"missed_readings,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// Payload konfigurasi device, sama dengan respons GET /api/device/status
+type configPayload struct {
+	Delay        int    `json:"delay"`
+	CurrentState string `json:"current_state"`
+}
+
+// Connect - Menghubungkan bridge ke broker MQTT jika MQTT_BROKER_URL diisi
+func Connect() {
+	broker := os.Getenv("MQTT_BROKER_URL")
+	if broker == "" {
+		log.Println("MQTT bridge disabled (MQTT_BROKER_URL not set)")
+		return
+	}
+
+	clientID := os.Getenv("MQTT_CLIENT_ID")
+	if clientID == "" {
+		clientID = "hose-backend"
+	}
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(clientID).
+		SetUsername(os.Getenv("MQTT_USERNAME")).
+		SetPassword(os.Getenv("MQTT_PASSWORD")).
+		SetAutoReconnect(true).
+		SetCleanSession(false). // Broker menyimpan pesan QoS 1 selama bridge terputus
+		SetOnConnectHandler(onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Println("MQTT connection lost:", err)
+		})
+
+	Client = mqtt.NewClient(opts)
+	token := Client.Connect()
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		// Auto reconnect tetap berjalan di background
+		log.Println("Warning: MQTT broker not reachable yet:", token.Error())
+		return
+	}
+
+	fmt.Println("🚀 MQTT bridge connected to", broker)
+}
+
+// onConnect - Dipanggil setiap kali (re)connect ke broker
+func onConnect(client mqtt.Client) {
+	if token := client.Subscribe(sensorTopic, 1, handleSensorMessage); token.Wait() && token.Error() != nil {
+		log.Println("MQTT subscribe failed:", token.Error())
+		return
+	}
+
+	// Publikasikan ulang konfigurasi semua device agar retained message selalu terbaru
+	var devices []models.Device
+	if err := database.DB.Find(&devices).Error; err != nil {
+		log.Println("MQTT failed to load devices:", err)
+		return
+	}
+	for i := range devices {
+		PublishDeviceConfig(&devices[i])
+	}
+}
+
+// PublishDeviceConfig - Mengirim delay & current_state device ke topik config (retained)
+func PublishDeviceConfig(device *models.Device) {
+	if Client == nil || !Client.IsConnectionOpen() {
+		return
+	}
+
+	payload, _ := json.Marshal(configPayload{Delay: device.Delay, CurrentState: device.CurrentState})
+	Client.Publish(fmt.Sprintf(configTopic, device.ID), 1, true, payload)
+}
+
+// publisher - Bagian client MQTT yang dipakai untuk mengirim ack (diganti client palsu di test)
+type publisher interface {
+	Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token
+}
+
+// handleSensorMessage - Menyimpan data sensor dari topik devices/{id}/sensor
+func handleSensorMessage(client mqtt.Client, msg mqtt.Message) {
+	processSensorMessage(client, msg.Topic(), msg.Payload(), time.Now())
+}
+
+// parseSensorMessage - Mengambil device_id dari topik devices/{id}/sensor dan mengurai payload JSON
+func parseSensorMessage(topic string, payload []byte) (uint, sensorPayload, error) {
+	var input sensorPayload
+	parts := strings.Split(topic, "/")
+	if len(parts) != 3 {
+		return 0, input, errors.New("unexpected topic")
+	}
+	deviceID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, input, fmt.Errorf("invalid device id %q", parts[1])
+	}
+	if err := json.Unmarshal(payload, &input); err != nil {
+		return 0, input, fmt.Errorf("malformed payload: %w", err)
+	}
+	return uint(deviceID), input, nil
+}
+
+// sensorReading - Memvalidasi payload dengan aturan yang sama seperti endpoint batch HTTP.
+// Timestamp boleh kosong (dianggap waktu diterima), tetapi timestamp yang terlalu jauh di depan jam server ditolak.
+func sensorReading(device *models.Device, input sensorPayload, now time.Time) (models.SensorData, string) {
+	if input.BPM == nil || input.SpO2 == nil || input.Temp == nil {
+		return models.SensorData{}, "bpm, spo2 and temp are required"
+	}
+	timestamp := now
+	if input.Timestamp != nil && !input.Timestamp.IsZero() {
+		if errMsg := services.ValidateReadingTimestamp(*input.Timestamp, now); errMsg != "" {
+			return models.SensorData{}, errMsg
+		}
+		timestamp = *input.Timestamp
+	}
+	if errMsg := services.ValidateReadingIdentity(input.ReadingID, input.BootID, input.Seq); errMsg != "" {
+		return models.SensorData{}, errMsg
+	}
+
+	return models.SensorData{
+		DeviceID:  device.ID,
+		BPM:       *input.BPM,
+		SpO2:      *input.SpO2,
+		Temp:      *input.Temp,
+		Timestamp: timestamp,
+		ReadingID: input.ReadingID,
+		Seq:       input.Seq,
+		BootID:    input.BootID,
+	}, ""
+}
+
+// processSensorMessage - Mengautentikasi, menyimpan data sensor dan mengirim ack ke devices/{id}/sensor/ack
+func processSensorMessage(client publisher, topic string, payload []byte, now time.Time) {
+	deviceID, input, err := parseSensorMessage(topic, payload)
+	if err != nil {
+		// Tanpa device yang terautentikasi tidak ada topik ack yang bisa dibalas
+		log.Printf("MQTT rejected message on %s: %v", topic, err)
+		return
+	}
+
+	// Autentikasi: API Key harus milik device yang ada di topik
+	device, err := services.FindDeviceByAPIKey(input.APIKey)
+	if input.APIKey == "" || err != nil || device.ID != deviceID {
+		log.Printf("MQTT rejected message on %s: invalid API key", topic)
+		return
+	}
+
+	// Catat heartbeat device (alamat IP tidak diketahui lewat MQTT)
+	services.TouchDevice(device, "", input.Uptime)
+
+	ack := ackPayload{ReadingID: input.ReadingID, Seq: input.Seq}
+	defer func() {
+		recordIngestAudit(device, topic, ack.Error)
+		payload, _ := json.Marshal(ack)
+		client.Publish(fmt.Sprintf(ackTopic, device.ID), 1, false, payload)
+	}()
+
+	sensorData, errMsg := sensorReading(device, input, now)
+	if errMsg != "" {
+		log.Printf("MQTT rejected reading on %s: %s", topic, errMsg)
+		ack.Error = errMsg
+		return
+	}
+
+	duplicate, missed, err := services.IngestSensorData(database.DB, &sensorData)
+	if err != nil {
+		ack.Error = "Failed to add sensor data"
+		return
+	}
+
+	ack.ID = sensorData.ID
+	ack.Duplicate = duplicate
+	ack.Folded = !duplicate && sensorData.ID == 0
+	ack.MissedReadings = missed
+}
+
+// recordIngestAudit - Mencatat penulisan data sensor lewat MQTT ke audit log (sama seperti endpoint API Key)
+func recordIngestAudit(device *models.Device, topic, errMsg string) {
+	outcome := models.AuditSuccess
+	if errMsg != "" {
+		outcome = models.AuditError
+	}
+	_ = services.RecordAudit(models.AuditLog{
+		ActorRole:      models.AuditActorDevice,
+		Action:         "vitals.write",
+		Method:         "MQTT",
+		Path:           topic,
+		TargetUserID:   &device.UserID,
+		TargetDeviceID: &device.ID,
+		Outcome:        outcome,
+	})
+}
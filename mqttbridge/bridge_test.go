@@ -0,0 +1,149 @@
+package mqttbridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/services"
+	"backend/testdb"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gorm.io/gorm"
+)
+
+// fakePublisher - Client MQTT palsu yang mencatat pesan yang dikirim
+type fakePublisher struct {
+	topics   []string
+	payloads [][]byte
+}
+
+func (f *fakePublisher) Publish(topic string, _ byte, _ bool, payload interface{}) mqtt.Token {
+	f.topics = append(f.topics, topic)
+	f.payloads = append(f.payloads, payload.([]byte))
+	return &mqtt.DummyToken{}
+}
+
+// lastAck - Ack terakhir yang dikirim ke perangkat
+func (f *fakePublisher) lastAck(t *testing.T) ackPayload {
+	t.Helper()
+	if len(f.payloads) == 0 {
+		t.Fatal("expected an ack to be published")
+	}
+	var ack ackPayload
+	if err := json.Unmarshal(f.payloads[len(f.payloads)-1], &ack); err != nil {
+		t.Fatal(err)
+	}
+	return ack
+}
+
+func createBridgeDevice(t *testing.T, db *gorm.DB, name, apiKey string) models.Device {
+	t.Helper()
+	user := models.User{Username: name, Password: "x", Email: name + "@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	device := models.Device{UserID: user.ID, Name: name}
+	services.SetAPIKey(&device, apiKey)
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return device
+}
+
+func TestParseSensorMessageRejectsMalformedInput(t *testing.T) {
+	tests := map[string][]byte{
+		"devices/sensor":             []byte(`{}`),
+		"devices/abc/sensor":         []byte(`{}`),
+		"devices/1/sensor":           []byte(`{"bpm":`),
+		"devices/99999999999/sensor": []byte(`{}`),
+	}
+	for topic, payload := range tests {
+		if _, _, err := parseSensorMessage(topic, payload); err == nil {
+			t.Errorf("%s %s: expected an error", topic, payload)
+		}
+	}
+
+	deviceID, input, err := parseSensorMessage("devices/7/sensor", []byte(`{"api_key":"k","bpm":70}`))
+	if err != nil || deviceID != 7 || input.APIKey != "k" || input.BPM == nil || *input.BPM != 70 {
+		t.Fatalf("unexpected result %d %+v (err %v)", deviceID, input, err)
+	}
+}
+
+func TestSensorReadingTimestampMatchesBatchRules(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	device := &models.Device{ID: 1}
+	value := 70.0
+	payload := func(timestamp *time.Time) sensorPayload {
+		return sensorPayload{BPM: &value, SpO2: &value, Temp: &value, Timestamp: timestamp}
+	}
+
+	if reading, errMsg := sensorReading(device, payload(nil), now); errMsg != "" || !reading.Timestamp.Equal(now) {
+		t.Fatalf("missing timestamp should default to now, got %v (%s)", reading.Timestamp, errMsg)
+	}
+	withinSkew := now.Add(services.MaxClockSkew - time.Second)
+	if reading, errMsg := sensorReading(device, payload(&withinSkew), now); errMsg != "" || !reading.Timestamp.Equal(withinSkew) {
+		t.Fatalf("timestamp within clock skew should be kept, got %v (%s)", reading.Timestamp, errMsg)
+	}
+	future := now.Add(services.MaxClockSkew + time.Second)
+	if _, errMsg := sensorReading(device, payload(&future), now); errMsg != "timestamp is in the future" {
+		t.Fatalf("expected future timestamp to be rejected, got %q", errMsg)
+	}
+	if _, errMsg := sensorReading(device, sensorPayload{BPM: &value}, now); errMsg == "" {
+		t.Fatal("expected missing vitals to be rejected")
+	}
+}
+
+func TestProcessSensorMessageStoresReadingAndAcks(t *testing.T) {
+	db := testdb.Open(t)
+	device := createBridgeDevice(t, db, "mqtt", "mqtt-key")
+	client := &fakePublisher{}
+
+	payload := []byte(`{"api_key":"mqtt-key","bpm":70,"spo2":98,"temp":36.5,"reading_id":"r-1"}`)
+	processSensorMessage(client, fmt.Sprintf("devices/%d/sensor", device.ID), payload, time.Now())
+
+	ack := client.lastAck(t)
+	if client.topics[0] != fmt.Sprintf(ackTopic, device.ID) || ack.Error != "" || ack.ID == 0 {
+		t.Fatalf("expected stored reading ack on %s, got %+v on %v", fmt.Sprintf(ackTopic, device.ID), ack, client.topics)
+	}
+
+	// Pengiriman ulang reading_id yang sama dibalas sebagai duplikat
+	processSensorMessage(client, fmt.Sprintf("devices/%d/sensor", device.ID), payload, time.Now())
+	if again := client.lastAck(t); !again.Duplicate || again.ID != ack.ID {
+		t.Fatalf("expected duplicate ack for reading %d, got %+v", ack.ID, again)
+	}
+}
+
+func TestProcessSensorMessageRejectsFutureTimestamp(t *testing.T) {
+	db := testdb.Open(t)
+	device := createBridgeDevice(t, db, "mqtt-future", "future-key")
+	client := &fakePublisher{}
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	payload := []byte(`{"api_key":"future-key","bpm":70,"spo2":98,"temp":36.5,"timestamp":"` + future + `"}`)
+	processSensorMessage(client, fmt.Sprintf("devices/%d/sensor", device.ID), payload, time.Now())
+
+	if ack := client.lastAck(t); ack.Error != "timestamp is in the future" {
+		t.Fatalf("expected future timestamp error, got %+v", ack)
+	}
+	var count int64
+	db.Model(&models.SensorData{}).Where("device_id = ?", device.ID).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no stored readings, got %d", count)
+	}
+}
+
+func TestProcessSensorMessageIgnoresWrongDevice(t *testing.T) {
+	db := testdb.Open(t)
+	device := createBridgeDevice(t, db, "mqtt-owner", "owner-key")
+	client := &fakePublisher{}
+
+	// API Key milik device lain tidak boleh menulis ke topik device ini
+	payload := []byte(`{"api_key":"owner-key","bpm":70,"spo2":98,"temp":36.5}`)
+	processSensorMessage(client, fmt.Sprintf("devices/%d/sensor", device.ID+1), payload, time.Now())
+	if len(client.topics) != 0 {
+		t.Fatalf("expected no ack for an unauthenticated message, got %v", client.topics)
+	}
+}
@@ -0,0 +1,53 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier - Mengirim notifikasi lewat email (SMTP).
+// Untuk pengujian lokal bisa diarahkan ke MailHog / Mailpit (misalnya localhost:1025).
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send - Mengirim email plain text ke msg.Target
+func (n *SMTPNotifier) Send(ctx context.Context, msg Message) error {
+	if strings.ContainsAny(msg.Target, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header value")
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.From)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.Target)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	var auth smtp.Auth
+	if n.Username != "" {
+		auth = smtp.PlainAuth("", n.Username, n.Password, n.Host)
+	}
+
+	// net/smtp tidak mendukung context, jalankan di goroutine agar tetap bisa timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(fmt.Sprintf("%s:%d", n.Host, n.Port), auth, n.From, []string{msg.Target}, []byte(body.String()))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,42 @@
+package notifier
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// MemoryNotifier - Menyimpan pesan di memori tanpa mengirimnya, untuk pengujian lokal
+type MemoryNotifier struct {
+	mu       sync.Mutex
+	Messages []Message
+}
+
+// Send - Menyimpan pesan ke daftar Messages
+func (n *MemoryNotifier) Send(_ context.Context, msg Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.Messages = append(n.Messages, msg)
+	return nil
+}
+
+// Last - Mengambil pesan terakhir yang dikirim ke target tertentu
+func (n *MemoryNotifier) Last(target string) (Message, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := len(n.Messages) - 1; i >= 0; i-- {
+		if n.Messages[i].Target == target {
+			return n.Messages[i], true
+		}
+	}
+	return Message{}, false
+}
+
+// LogNotifier - Menulis pesan ke log, dipakai sebagai mailer saat SMTP belum dikonfigurasi (development)
+type LogNotifier struct{}
+
+// Send - Mencetak pesan ke log
+func (LogNotifier) Send(_ context.Context, msg Message) error {
+	log.Printf("[mail] to=%s subject=%q\n%s", msg.Target, msg.Subject, msg.Body)
+	return nil
+}
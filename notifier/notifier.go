@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Message - Pesan notifikasi yang akan dikirim ke satu tujuan
+type Message struct {
+	Target  string // Alamat email, URL webhook, atau token push
+	Secret  string // Secret untuk tanda tangan webhook
+	Subject string
+	Body    string
+	Payload []byte // Data JSON (webhook & push)
+}
+
+// Notifier - Kanal pengiriman notifikasi
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Registry - Notifier yang tersedia berdasarkan jenis channel (email, webhook, push)
+type Registry map[string]Notifier
+
+// Get - Mendapatkan notifier untuk jenis channel tertentu
+func (r Registry) Get(channelType string) (Notifier, error) {
+	n, ok := r[channelType]
+	if !ok {
+		return nil, fmt.Errorf("notifier %q is not configured", channelType)
+	}
+	return n, nil
+}
+
+// FromEnv - Membuat registry dari environment variable.
+// Email aktif jika SMTP_HOST diisi, push aktif jika PUSH_GATEWAY_URL diisi,
+// webhook selalu aktif karena URL dan secret disimpan per channel.
+func FromEnv() Registry {
+	registry := Registry{"webhook": NewWebhookNotifier()}
+
+	if smtp := smtpFromEnv(); smtp != nil {
+		registry["email"] = smtp
+	}
+
+	if url := os.Getenv("PUSH_GATEWAY_URL"); url != "" {
+		registry["push"] = NewPushNotifier(url, os.Getenv("PUSH_GATEWAY_KEY"))
+	}
+
+	return registry
+}
+
+// ErrMailerNotConfigured - Email akun tidak bisa dikirim karena SMTP belum dikonfigurasi
+// dan MAIL_DRIVER=log tidak dipilih secara eksplisit
+var ErrMailerNotConfigured = errors.New("mailer is not configured: set SMTP_HOST or MAIL_DRIVER=log")
+
+// MailerFromEnv - Mailer untuk email akun (verifikasi, reset password) dari MAIL_DRIVER.
+// "smtp" (default) memakai SMTP_HOST, "log" hanya menulis email ke log (untuk development).
+func MailerFromEnv() (Notifier, error) {
+	switch driver := strings.ToLower(os.Getenv("MAIL_DRIVER")); driver {
+	case "", "smtp":
+		if smtp := smtpFromEnv(); smtp != nil {
+			return smtp, nil
+		}
+		return nil, ErrMailerNotConfigured
+	case "log":
+		return LogNotifier{}, nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q (use smtp or log)", driver)
+	}
+}
+
+// smtpFromEnv - Membaca konfigurasi SMTP, nil jika SMTP_HOST kosong
+func smtpFromEnv() *SMTPNotifier {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		port = 587
+	}
+	return &SMTPNotifier{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+}
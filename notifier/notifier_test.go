@@ -0,0 +1,32 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailerFromEnvRequiresExplicitLogDriver(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	t.Setenv("MAIL_DRIVER", "")
+	if _, err := MailerFromEnv(); !errors.Is(err, ErrMailerNotConfigured) {
+		t.Fatalf("expected ErrMailerNotConfigured without SMTP_HOST, got %v", err)
+	}
+
+	t.Setenv("MAIL_DRIVER", "log")
+	if mailer, err := MailerFromEnv(); err != nil || mailer != (LogNotifier{}) {
+		t.Fatalf("expected LogNotifier with MAIL_DRIVER=log, got %v (err %v)", mailer, err)
+	}
+
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	if mailer, err := MailerFromEnv(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := mailer.(*SMTPNotifier); !ok {
+		t.Fatalf("expected SMTPNotifier, got %T", mailer)
+	}
+
+	t.Setenv("MAIL_DRIVER", "sendgrid")
+	if _, err := MailerFromEnv(); err == nil {
+		t.Fatal("expected an error for an unknown MAIL_DRIVER")
+	}
+}
@@ -0,0 +1,45 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// PushNotifier - Mengirim notifikasi ke push gateway generik lewat HTTP
+type PushNotifier struct {
+	GatewayURL string
+	APIKey     string
+	Client     *http.Client
+}
+
+// NewPushNotifier - Membuat PushNotifier dengan timeout default
+func NewPushNotifier(gatewayURL, apiKey string) *PushNotifier {
+	return &PushNotifier{GatewayURL: gatewayURL, APIKey: apiKey, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Send - Mengirim {token, title, body, data} ke push gateway
+func (n *PushNotifier) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"token": msg.Target,
+		"title": msg.Subject,
+		"body":  msg.Body,
+		"data":  json.RawMessage(msg.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.GatewayURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+n.APIKey)
+	}
+
+	return doRequest(n.Client, req)
+}
@@ -0,0 +1,145 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress - URL webhook menuju alamat internal (loopback, link-local, jaringan privat)
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// Rentang alamat yang tidak dicakup net.IP.IsPrivate tetapi tetap bukan alamat publik
+var forbiddenNetworks = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "64:ff9b::/96")
+
+// WebhookNotifier - Mengirim notifikasi ke URL HTTP milik user dengan tanda tangan HMAC-SHA256.
+// Penerima memverifikasi header X-Signature = "sha256=" + hex(HMAC(secret, timestamp + "." + body)).
+type WebhookNotifier struct {
+	Client *http.Client
+}
+
+// NewWebhookNotifier - Membuat WebhookNotifier dengan timeout default. Alamat tujuan diperiksa lagi saat
+// koneksi dibuka (setelah DNS di-resolve), karena DNS bisa diubah sejak URL divalidasi (DNS rebinding).
+func NewWebhookNotifier() *WebhookNotifier {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialControl}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &WebhookNotifier{Client: &http.Client{Timeout: 10 * time.Second, Transport: transport}}
+}
+
+// ValidateWebhookURL - Memeriksa URL webhook: http(s), punya host, dan semua alamat hasil resolve DNS publik
+func ValidateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return errors.New("invalid webhook URL")
+	}
+
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("cannot resolve webhook host: %w", err)
+	}
+	for _, address := range addresses {
+		if !allowedIP(address.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// dialControl - Menolak koneksi ke alamat internal, dipanggil dengan alamat IP yang benar-benar dituju
+func dialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !allowedIP(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// allowedIP - Alamat publik yang boleh dituju webhook. WEBHOOK_ALLOW_PRIVATE_NETWORKS=true
+// mengizinkan alamat internal (hanya untuk development).
+func allowedIP(ip net.IP) bool {
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true" {
+		return true
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDRs - Daftar jaringan dari notasi CIDR
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// Sign - Menghitung tanda tangan webhook
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send - Mengirim payload JSON ke msg.Target
+func (n *WebhookNotifier) Send(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Target, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Signature", Sign(msg.Secret, timestamp, msg.Payload))
+
+	return doRequest(n.Client, req)
+}
+
+// doRequest - Menjalankan request dan menganggap status selain 2xx sebagai error
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+	return nil
+}
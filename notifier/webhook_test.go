@@ -0,0 +1,62 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateWebhookURLRejectsInternalAddresses(t *testing.T) {
+	targets := []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.10/hook",
+		"http://172.16.0.1/hook",
+		"http://100.64.0.1/hook",
+		"http://[::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+	}
+	for _, target := range targets {
+		if err := ValidateWebhookURL(context.Background(), target); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: expected ErrForbiddenAddress, got %v", target, err)
+		}
+	}
+}
+
+func TestValidateWebhookURLRejectsInvalidURLs(t *testing.T) {
+	for _, target := range []string{"ftp://93.184.216.34/hook", "http:///hook", "not a url"} {
+		if err := ValidateWebhookURL(context.Background(), target); err == nil || errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("%s: expected invalid URL error, got %v", target, err)
+		}
+	}
+}
+
+func TestValidateWebhookURLAcceptsPublicAddress(t *testing.T) {
+	if err := ValidateWebhookURL(context.Background(), "https://93.184.216.34/hook"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookSendRefusesInternalAddressAtDialTime(t *testing.T) {
+	received := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer server.Close()
+
+	// URL lolos validasi sebelumnya lalu DNS berubah ke alamat internal: koneksi tetap ditolak
+	err := NewWebhookNotifier().Send(context.Background(), Message{Target: server.URL, Secret: "s", Payload: []byte("{}")})
+	if !errors.Is(err, ErrForbiddenAddress) || received {
+		t.Fatalf("expected ErrForbiddenAddress without delivery, got %v (received=%v)", err, received)
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	if err := NewWebhookNotifier().Send(context.Background(), Message{Target: server.URL, Secret: "s", Payload: []byte("{}")}); err != nil || !received {
+		t.Fatalf("expected delivery when private networks are allowed, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Interval pembersihan key yang sudah tidak aktif
+const memorySweepInterval = 5 * time.Minute
+
+// MemoryStore - Store di memori proses (default, hanya berlaku untuk satu instance)
+type MemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]*State
+	lastSweep time.Time
+	retention time.Duration
+}
+
+// NewMemoryStore - Membuat MemoryStore. State yang tidak aktif lebih lama dari retention dihapus.
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{states: make(map[string]*State), retention: retention}
+}
+
+// Get - Mengambil state key
+func (s *MemoryStore) Get(_ context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[key]; ok {
+		return *state, nil
+	}
+	return State{Key: key}, nil
+}
+
+// Hit - Mencatat satu percobaan untuk key
+func (s *MemoryStore) Hit(_ context.Context, key string, policy Policy, now time.Time) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state(key, now)
+	policy.Apply(state, now)
+	return *state, nil
+}
+
+// Attempt - Mengecek blokir dan mencatat percobaan untuk key dalam satu lock
+func (s *MemoryStore) Attempt(_ context.Context, key string, policy Policy, now time.Time) (State, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state(key, now)
+	allowed := policy.Attempt(state, now)
+	return *state, allowed, nil
+}
+
+// state - State key (dibuat jika belum ada), mu harus sudah dikunci
+func (s *MemoryStore) state(key string, now time.Time) *State {
+	s.sweep(now)
+	state, ok := s.states[key]
+	if !ok {
+		state = &State{Key: key}
+		s.states[key] = state
+	}
+	return state
+}
+
+// Reset - Menghapus state key (misalnya setelah login berhasil atau dibuka admin)
+func (s *MemoryStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// Blocked - Daftar key yang sedang diblokir
+func (s *MemoryStore) Blocked(_ context.Context, now time.Time) ([]State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var blocked []State
+	for _, state := range s.states {
+		if state.BlockedUntil.After(now) {
+			blocked = append(blocked, *state)
+		}
+	}
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].Key < blocked[j].Key })
+	return blocked, nil
+}
+
+// sweep - Menghapus state yang sudah lama tidak aktif dan tidak diblokir
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, state := range s.states {
+		if now.Sub(state.LastHit) > s.retention && !state.BlockedUntil.After(now) {
+			delete(s.states, key)
+		}
+	}
+}
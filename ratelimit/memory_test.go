@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreAttemptIsAtomic(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	policy := Policy{Limit: 5, Window: time.Minute, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	now := time.Now()
+
+	// Request paralel: hanya Limit + 1 yang lolos sebelum key diblokir
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := store.Attempt(context.Background(), "ip:1", policy, now); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != int32(policy.Limit+1) {
+		t.Fatalf("expected %d requests to pass, got %d", policy.Limit+1, got)
+	}
+	blocked, _ := store.Blocked(context.Background(), now)
+	if len(blocked) != 1 || blocked[0].Count != policy.Limit+1 {
+		t.Fatalf("expected key blocked after %d hits, got %+v", policy.Limit+1, blocked)
+	}
+}
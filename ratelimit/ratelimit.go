@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Policy - Aturan pembatasan untuk satu jenis key (misalnya login per IP atau per username)
+type Policy struct {
+	Limit           int           // Jumlah percobaan bebas sebelum backoff berlaku
+	Window          time.Duration // Hitungan direset jika tidak ada percobaan selama Window
+	BaseDelay       time.Duration // Jeda pertama setelah Limit terlampaui, lalu berlipat dua
+	MaxDelay        time.Duration // Jeda maksimum backoff
+	LockoutAfter    int           // Kunci sementara setelah N percobaan (0 = tanpa lockout)
+	LockoutDuration time.Duration
+}
+
+// State - Kondisi pembatasan untuk satu key
+type State struct {
+	Key          string    `json:"key"`
+	Count        int       `json:"count"`
+	LastHit      time.Time `json:"last_hit"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	Locked       bool      `json:"locked"` // true jika diblokir karena lockout (bukan sekadar backoff)
+}
+
+// RetryAfter - Sisa waktu blokir, 0 jika tidak diblokir
+func (s State) RetryAfter(now time.Time) time.Duration {
+	if s.BlockedUntil.After(now) {
+		return s.BlockedUntil.Sub(now)
+	}
+	return 0
+}
+
+// Apply - Mencatat satu percobaan pada state sesuai policy
+func (p Policy) Apply(state *State, now time.Time) {
+	if p.Window > 0 && now.Sub(state.LastHit) > p.Window {
+		state.Count = 0
+		state.Locked = false
+	}
+	state.Count++
+	state.LastHit = now
+
+	if p.LockoutAfter > 0 && state.Count >= p.LockoutAfter {
+		state.BlockedUntil = now.Add(p.LockoutDuration)
+		state.Locked = true
+		return
+	}
+
+	if state.Count > p.Limit {
+		state.BlockedUntil = now.Add(p.backoff(state.Count - p.Limit - 1))
+	}
+}
+
+// backoff - BaseDelay dilipatgandakan sebanyak doublings kali, dibatasi MaxDelay (tanpa overflow)
+func (p Policy) backoff(doublings int) time.Duration {
+	if doublings >= 63 || p.BaseDelay <= 0 || p.BaseDelay > p.MaxDelay>>uint(doublings) {
+		return p.MaxDelay
+	}
+	return p.BaseDelay << uint(doublings)
+}
+
+// Attempt - Memutuskan satu request: ditolak tanpa dihitung jika key sedang diblokir,
+// selain itu dicatat dengan Apply. Mengembalikan true jika request boleh diproses.
+func (p Policy) Attempt(state *State, now time.Time) bool {
+	if state.BlockedUntil.After(now) {
+		return false
+	}
+	p.Apply(state, now)
+	return true
+}
+
+// Store - Penyimpanan state pembatasan. Memory untuk satu instance, SQL untuk banyak instance.
+type Store interface {
+	Get(ctx context.Context, key string) (State, error)
+	Hit(ctx context.Context, key string, policy Policy, now time.Time) (State, error)
+	// Attempt - Pengecekan blokir dan pencatatan dalam satu operasi terkunci (lihat Policy.Attempt)
+	Attempt(ctx context.Context, key string, policy Policy, now time.Time) (State, bool, error)
+	Reset(ctx context.Context, key string) error
+	Blocked(ctx context.Context, now time.Time) ([]State, error)
+}
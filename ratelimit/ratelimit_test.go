@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyApply(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Limit: 3, Window: 15 * time.Minute, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 10, LockoutDuration: time.Hour}
+
+	tests := []struct {
+		name        string
+		policy      Policy
+		state       State
+		wantCount   int
+		wantBlocked time.Duration // Sisa blokir setelah Apply
+		wantLocked  bool
+	}{
+		{"within limit", policy, State{Count: 1, LastHit: now}, 2, 0, false},
+		{"first over limit", policy, State{Count: 3, LastHit: now}, 4, time.Second, false},
+		{"backoff doubles", policy, State{Count: 5, LastHit: now}, 6, 4 * time.Second, false},
+		{"backoff capped at max delay", Policy{Limit: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}, State{Count: 9, LastHit: now}, 10, 30 * time.Second, false},
+		{"no overflow on huge count", Policy{Limit: 0, BaseDelay: time.Second, MaxDelay: time.Minute}, State{Count: 200, LastHit: now}, 201, time.Minute, false},
+		{"no overflow just past shift width", Policy{Limit: 0, BaseDelay: time.Second, MaxDelay: time.Minute}, State{Count: 34, LastHit: now}, 35, time.Minute, false},
+		{"window reset", policy, State{Count: 8, LastHit: now.Add(-time.Hour), Locked: true}, 1, 0, false},
+		{"lockout", policy, State{Count: 9, LastHit: now}, 10, time.Hour, true},
+	}
+	for _, tt := range tests {
+		state := tt.state
+		tt.policy.Apply(&state, now)
+		if state.Count != tt.wantCount || state.RetryAfter(now) != tt.wantBlocked || state.Locked != tt.wantLocked {
+			t.Errorf("%s: expected count %d, blocked %v, locked %v; got count %d, blocked %v, locked %v",
+				tt.name, tt.wantCount, tt.wantBlocked, tt.wantLocked, state.Count, state.RetryAfter(now), state.Locked)
+		}
+	}
+}
+
+func TestPolicyAttemptRejectsWhileBlocked(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := Policy{Limit: 1, Window: time.Minute, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+
+	state := State{}
+	if !policy.Attempt(&state, now) || !policy.Attempt(&state, now) {
+		t.Fatal("expected requests up to and including the one that exceeds the limit to pass")
+	}
+	if policy.Attempt(&state, now.Add(time.Second)) || state.Count != 2 {
+		t.Fatalf("expected blocked request to be rejected without counting, got count %d", state.Count)
+	}
+	if !policy.Attempt(&state, now.Add(11*time.Second)) {
+		t.Fatal("expected request after the block to pass")
+	}
+}
@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SQLStore - Store di database, dipakai bersama oleh beberapa instance server
+type SQLStore struct {
+	DB *gorm.DB
+}
+
+// NewSQLStore - Membuat SQLStore (tabel login_throttles)
+func NewSQLStore(db *gorm.DB) *SQLStore {
+	return &SQLStore{DB: db}
+}
+
+// toState - Konversi model database ke State
+func toState(row models.LoginThrottle) State {
+	return State{Key: row.Key, Count: row.Count, LastHit: row.LastHit, BlockedUntil: row.BlockedUntil, Locked: row.Locked}
+}
+
+// Get - Mengambil state key
+func (s *SQLStore) Get(ctx context.Context, key string) (State, error) {
+	var row models.LoginThrottle
+	err := s.DB.WithContext(ctx).Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return State{Key: key}, nil
+	}
+	if err != nil {
+		return State{}, err
+	}
+	return toState(row), nil
+}
+
+// Hit - Mencatat satu percobaan; baris dikunci (FOR UPDATE) agar aman dari request paralel
+func (s *SQLStore) Hit(ctx context.Context, key string, policy Policy, now time.Time) (State, error) {
+	return s.update(ctx, key, func(state *State) bool {
+		policy.Apply(state, now)
+		return true
+	})
+}
+
+// Attempt - Mengecek blokir dan mencatat percobaan pada baris yang sama yang sudah dikunci
+func (s *SQLStore) Attempt(ctx context.Context, key string, policy Policy, now time.Time) (State, bool, error) {
+	var allowed bool
+	state, err := s.update(ctx, key, func(state *State) bool {
+		allowed = policy.Attempt(state, now)
+		return allowed
+	})
+	return state, allowed, err
+}
+
+// update - Mengunci baris key (dibuat jika belum ada), menjalankan change lalu menyimpan state jika change
+// mengembalikan true
+func (s *SQLStore) update(ctx context.Context, key string, change func(*State) bool) (State, error) {
+	var state State
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := models.LoginThrottle{Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).First(&row).Error; err != nil {
+			return err
+		}
+
+		state = toState(row)
+		if !change(&state) {
+			return nil
+		}
+		return tx.Model(&models.LoginThrottle{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"count":         state.Count,
+			"last_hit":      state.LastHit,
+			"blocked_until": state.BlockedUntil,
+			"locked":        state.Locked,
+		}).Error
+	})
+	return state, err
+}
+
+// Reset - Menghapus state key
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	return s.DB.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// Blocked - Daftar key yang sedang diblokir
+func (s *SQLStore) Blocked(ctx context.Context, now time.Time) ([]State, error) {
+	var rows []models.LoginThrottle
+	if err := s.DB.WithContext(ctx).Where("blocked_until > ?", now).Order("key").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	states := make([]State, len(rows))
+	for i, row := range rows {
+		states[i] = toState(row)
+	}
+	return states, nil
+}
+
+// Prune - Menghapus baris yang sudah lama tidak aktif
+func (s *SQLStore) Prune(ctx context.Context, olderThan time.Time) error {
+	return s.DB.WithContext(ctx).Where("last_hit < ? AND blocked_until < ?", olderThan, olderThan).
+		Delete(&models.LoginThrottle{}).Error
+}
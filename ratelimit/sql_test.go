@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/testdb"
+)
+
+func TestSQLStoreAttemptBlocksAfterLimit(t *testing.T) {
+	store := NewSQLStore(testdb.Open(t))
+	policy := Policy{Limit: 2, Window: time.Minute, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	now := time.Now()
+	ctx := context.Background()
+
+	for i := 0; i < policy.Limit+1; i++ {
+		if _, ok, err := store.Attempt(ctx, "register:ip:1", policy, now); err != nil || !ok {
+			t.Fatalf("attempt %d: expected to pass (err %v)", i+1, err)
+		}
+	}
+	state, ok, err := store.Attempt(ctx, "register:ip:1", policy, now)
+	if err != nil || ok || state.Count != policy.Limit+1 || state.RetryAfter(now) != time.Minute {
+		t.Fatalf("expected blocked attempt without counting, got %+v (ok %v, err %v)", state, ok, err)
+	}
+
+	if err := store.Reset(ctx, "register:ip:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := store.Attempt(ctx, "register:ip:1", policy, now); !ok {
+		t.Fatal("expected attempt to pass after reset")
+	}
+}
@@ -1,13 +1,17 @@
 package routes
 
 import (
+	"log"
 	"net/http"
 	"time"
 
 	database "backend/config"
 	"backend/controllers"
 	"backend/middleware"
+	"backend/migrations"
 	"backend/models"
+	"backend/mqttbridge"
+	"backend/services"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -18,11 +22,60 @@ func SetupRouter() *gin.Engine {
 	// Inisialisasi database
 	database.ConnectDatabase()
 
-	// Auto Migrate Model
-	database.DB.AutoMigrate(&models.User{}, &models.Device{}, &models.SensorData{})
+	// Server tidak mengubah skema sendiri: tolak start jika masih ada migrasi
+	// yang belum dijalankan (jalankan dulu `go run ./cmd/migrate up`)
+	if err := migrations.RequireCurrent(database.DB); err != nil {
+		log.Fatal("❌ ", err)
+	}
 
-	// Membuat instance gin router
-	r := gin.Default()
+	// Mode penyimpanan sensor_data (tabel biasa, hypertable TimescaleDB, atau partisi per bulan),
+	// konversi dijalankan lewat `go run ./cmd/migrate sensor-storage <mode>`
+	if err := services.InitSensorStorage(); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// Enkripsi field sensitif (KEK dari FIELD_ENCRYPTION_KEYS)
+	services.InitFieldEncryption()
+
+	// Server juga tidak mengubah data lama sendiri: tolak start jika masih ada field plaintext,
+	// API Key plaintext, atau role lama (jalankan dulu `go run ./cmd/migrate data`)
+	if err := services.RequireLegacyDataMigrated(); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// Pengirim email akun (SMTP, atau MAIL_DRIVER=log untuk development)
+	services.InitMailer()
+
+	// Filter tenant otomatis untuk query handler admin
+	if err := services.RegisterTenantScope(database.DB); err != nil {
+		log.Fatal("❌ ", err)
+	}
+
+	// Store rate limit login (memory atau SQL)
+	services.InitLimiter()
+
+	// Inisialisasi MQTT bridge (opsional, aktif jika MQTT_BROKER_URL diisi)
+	mqttbridge.Connect()
+
+	// Worker pengirim notifikasi dari outbox
+	services.StartOutboxWorker()
+
+	// Worker export data (job dari semua instance) dan pembersih file export yang kedaluwarsa
+	services.StartExportWorker()
+
+	// Worker penghapus permanen akun yang masa tenggangnya sudah habis
+	services.StartAccountPurger()
+
+	// Worker retensi data sensor (ringkasan per jam / per hari, lalu hapus data mentah lama)
+	services.StartRetentionScheduler()
+
+	// Worker pendeteksi device offline
+	services.StartHeartbeatChecker()
+
+	// Membuat instance gin router. Logger bawaan gin.Default menulis query string apa adanya,
+	// sehingga dipakai logger yang menyamarkan token (?access_token=, ?token=)
+	r := gin.New()
+	r.Use(middleware.RequestLogger(), gin.Recovery())
 
 	// Pengaturan CORS
 	r.Use(cors.New(cors.Config{
@@ -34,8 +87,19 @@ func SetupRouter() *gin.Engine {
 	}))
 
 	// =================== Public Routes (Tanpa JWT) ===================
-	r.POST("/register", controllers.Register)
+	r.POST("/register", middleware.RateLimitByIP("register"), controllers.Register)
 	r.POST("/login", controllers.Login)
+	r.POST("/login/2fa", controllers.LoginTwoFactor)                                                    // Langkah kedua login jika 2FA aktif (kode TOTP / kode cadangan)
+	r.POST("/refresh", controllers.RefreshToken)                                                        // Tukar refresh token dengan access token baru
+	r.POST("/password/forgot", middleware.RateLimitByIP("password_forgot"), controllers.ForgotPassword) // Minta link reset password lewat email
+	r.POST("/password/reset", controllers.ResetPassword)                                                // Ganti password dengan token reset
+	r.GET("/exports/download", middleware.Audit("export.download"), controllers.DownloadExport)         // Unduh export data lewat link sementara (?token=)
+	r.GET("/verify-email", controllers.VerifyEmail)                                                     // Verifikasi email dari link yang dikirim saat registrasi
+	r.GET("/account/restore", middleware.Audit("user.restore"), controllers.RestoreAccount)             // Pulihkan akun yang dihapus lewat link email (?token=)
+
+	// Logout (Memerlukan JWT)
+	r.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)        // Cabut sesi saat ini
+	r.POST("/logout-all", middleware.AuthMiddleware(), controllers.LogoutAll) // Cabut semua sesi milik user
 
 	// =================== Protected Routes (Memerlukan JWT) ===================
 	protected := r.Group("/api")
@@ -58,42 +122,145 @@ func SetupRouter() *gin.Engine {
 	})
 
 	// User Routes (User)
-	protected.GET("/user", controllers.UserInfoByUser)                       // Dapatkan informasi user
-	protected.PATCH("/user", controllers.UpdateUserByUser)                   // Update informasi user
-	protected.DELETE("/user", controllers.DeleteUserByUser)                  // Hapus user
-	protected.PUT("/user/change-password", controllers.ChangePasswordByUser) // Ubah password user
+	protected.GET("/user", middleware.Audit("profile.read"), controllers.UserInfoByUser)                           // Dapatkan informasi user
+	protected.PATCH("/user", middleware.Audit("profile.update"), controllers.UpdateUserByUser)                     // Update informasi user
+	protected.DELETE("/user", middleware.Audit("profile.delete"), controllers.DeleteUserByUser)                    // Hapus user
+	protected.PUT("/user/change-password", middleware.Audit("profile.password"), controllers.ChangePasswordByUser) // Ubah password user
+	protected.PUT("/patients/:user_id/medical-history", middleware.RequirePermission(models.PermEditSharedMedicalHistory),
+		middleware.Audit("profile.medical_history"), controllers.UpdateSharedMedicalHistoryByUser) // Ubah riwayat medis pasien yang memberi akses edit_medical_history
+	protected.POST("/user/resend-verification", controllers.ResendVerificationEmail) // Kirim ulang email verifikasi (dibatasi)
+
+	// Session Routes (User)
+	protected.GET("/sessions", controllers.GetSessionsByUser)                  // Dapatkan sesi login aktif (perangkat, user agent, IP)
+	protected.DELETE("/sessions/:session_id", controllers.RevokeSessionByUser) // Cabut sesi tertentu
+
+	// Two-Factor Authentication Routes (User)
+	protected.GET("/2fa", controllers.GetTwoFactorStatusByUser)                      // Status 2FA dan sisa kode cadangan
+	protected.POST("/2fa/setup", controllers.SetupTwoFactorByUser)                   // Buat secret TOTP dan URI QR code
+	protected.POST("/2fa/enable", controllers.EnableTwoFactorByUser)                 // Aktifkan 2FA dengan kode dari authenticator
+	protected.POST("/2fa/disable", controllers.DisableTwoFactorByUser)               // Nonaktifkan 2FA (password + kode)
+	protected.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodesByUser) // Buat ulang kode cadangan
 
 	// Device Routes (User)
-	protected.GET("/devices", controllers.GetDevicesByUser)                // Dapatkan semua device yang dimiliki user
-	protected.PUT("/device/:device_id", controllers.UpdateDeviceByUser)    // Update device tertentu wajib dimiliki user
-	protected.POST("/device", controllers.AddDeviceByUser)                 // Tambah device baru untuk user
-	protected.DELETE("/device/:device_id", controllers.DeleteDeviceByUser) // Hapus device tertentu yang dimiliki user
-	protected.GET("/sensor/:device_id", controllers.GetSensorDataByUser)   // Dapatkan data sensor dari device tertentu yang dimiliki user
+	protected.GET("/devices", middleware.Audit("devices.shared.read"), controllers.GetDevicesByUser)          // Dapatkan semua device yang dimiliki user
+	protected.PUT("/device/:device_id", middleware.Audit("device.update"), controllers.UpdateDeviceByUser)    // Update device milik user (atau pasien yang memberi akses manage_devices)
+	protected.DELETE("/device/:device_id", middleware.Audit("device.delete"), controllers.DeleteDeviceByUser) // Hapus device tertentu yang dimiliki user
+	protected.POST("/device/:device_id/rotate-key", controllers.RotateDeviceKeyByUser)                        // Ganti API Key device (API Key lama berlaku selama masa transisi)
+	protected.POST("/device", middleware.RequirePermission(models.PermManageOwnDevices), middleware.VerifiedEmailOnly(),
+		controllers.AddDeviceByUser) // Tambah device baru untuk user
+	protected.GET("/sensor/:device_id", middleware.Audit("vitals.read"), controllers.GetSensorDataByUser)               // Dapatkan data sensor dari device tertentu yang dimiliki user
+	protected.GET("/sensor/:device_id/gaps", middleware.Audit("vitals.read"), controllers.GetMissedReadingsByUser)      // Dapatkan rentang data sensor yang hilang (berdasarkan seq)
+	protected.GET("/sensor/:device_id/aggregate", middleware.Audit("vitals.aggregate"), controllers.GetSensorAggregate) // Agregasi / downsampling data sensor untuk grafik
+
+	// Access Grant Routes (Pasien berbagi akses ke caregiver / clinician)
+	protected.GET("/grants", controllers.GetGrantsByUser)                                                         // Daftar akses yang diberikan pasien
+	protected.POST("/grants", middleware.RequirePermission(models.PermReadOwnVitals), controllers.AddGrantByUser) // Undang user lewat email (scope & masa berlaku)
+	protected.DELETE("/grants/:grant_id", controllers.RevokeGrantByUser)                                          // Cabut akses (pasien atau grantee)
+	protected.GET("/grants/received", controllers.GetReceivedGrantsByUser)                                        // Undangan dan akses yang diterima user
+	protected.POST("/grants/:grant_id/accept", controllers.AcceptGrantByUser)                                     // Terima undangan akses
+
+	// Data Export Routes (User)
+	protected.POST("/exports", middleware.Audit("export.request"), controllers.RequestExportByUser) // Minta export seluruh data (ZIP berisi JSON & CSV)
+	protected.GET("/exports", controllers.GetExportsByUser)                                         // Daftar export milik user
+	protected.GET("/exports/:export_id", controllers.GetExportByUser)                               // Status export dan link download sementara
+
+	// Audit Routes (User)
+	protected.GET("/audit/access", controllers.GetAccessHistoryByUser) // Siapa yang mengakses data saya dan kapan
+
+	// Alert Routes (User)
+	protected.GET("/alert-rules", controllers.GetAlertRulesByUser)                         // Dapatkan semua alert rule milik user
+	protected.POST("/alert-rules", controllers.AddAlertRuleByUser)                         // Tambah alert rule
+	protected.PUT("/alert-rules/:rule_id", controllers.UpdateAlertRuleByUser)              // Update alert rule
+	protected.DELETE("/alert-rules/:rule_id", controllers.DeleteAlertRuleByUser)           // Hapus alert rule
+	protected.GET("/alerts", middleware.Audit("alerts.read"), controllers.GetAlertsByUser) // Dapatkan alert milik user (?patient_id= untuk pasien yang memberi akses)
+	protected.POST("/alerts/:alert_id/acknowledge", controllers.AcknowledgeAlertByUser)    // Tandai alert sudah diketahui
+	protected.POST("/alerts/:alert_id/resolve", controllers.ResolveAlertByUser)            // Tandai alert selesai
+
+	// Notification Routes (User)
+	protected.GET("/notification-channels", controllers.GetNotificationChannelsByUser)                  // Dapatkan channel notifikasi milik user
+	protected.POST("/notification-channels", controllers.AddNotificationChannelByUser)                  // Tambah channel notifikasi (email, webhook, push)
+	protected.PATCH("/notification-channels/:channel_id", controllers.UpdateNotificationChannelByUser)  // Aktifkan / nonaktifkan channel notifikasi
+	protected.DELETE("/notification-channels/:channel_id", controllers.DeleteNotificationChannelByUser) // Hapus channel notifikasi
+	protected.GET("/notification-settings", controllers.GetNotificationSettingsByUser)                  // Dapatkan pengaturan quiet hours
+	protected.PUT("/notification-settings", controllers.UpdateNotificationSettingsByUser)               // Ubah pengaturan quiet hours
+
+	// =================== Streaming Routes (JWT lewat header atau query) ===================
+	stream := r.Group("/api/stream")
+	stream.Use(middleware.QueryTokenMiddleware(), middleware.AuthMiddleware())
+	stream.GET("/sensor/:device_id", middleware.Audit("vitals.stream"), controllers.StreamSensorDataByUser) // Live data sensor via Server-Sent Events
 
 	// =================== Device API Routes (Memerlukan API) ===================
 	deviceAPI := r.Group("/api/device")
-	deviceAPI.Use(middleware.APIKeyMiddleware())               // Middleware untuk memeriksa API Key
-	deviceAPI.POST("/sensor", controllers.AddSensorDataByAPI)  // Endpoint untuk menambahkan data sensor ke device tertentu
-	deviceAPI.GET("/status", controllers.GetDeviceStatusByAPI) // Endpoint untuk melihat status device
+	deviceAPI.Use(middleware.APIKeyMiddleware())                                                           // Middleware untuk memeriksa API Key
+	deviceAPI.POST("/sensor", middleware.Audit("vitals.write"), controllers.AddSensorDataByAPI)            // Endpoint untuk menambahkan data sensor ke device tertentu
+	deviceAPI.POST("/sensor/batch", middleware.Audit("vitals.write"), controllers.AddSensorDataBatchByAPI) // Endpoint untuk upload banyak data sensor (backfill saat offline)
+	deviceAPI.GET("/status", controllers.GetDeviceStatusByAPI)                                             // Endpoint untuk melihat status device
 
-	// =================== Admin Routes (Memerlukan Token Admin) ===================
+	// =================== Admin Routes (Memerlukan Permission Admin) ===================
 	protectedAdmin := r.Group("/admin")
-	protectedAdmin.Use(middleware.AuthMiddleware(), middleware.AdminOnly())
-
-	// Routes untuk User Management (Hanya Admin)
-	protectedAdmin.POST("/users", controllers.CreateUserAdmin)            // Tambah user
-	protectedAdmin.GET("/users", controllers.GetAllUsersAdmin)            // Dapatkan semua user
-	protectedAdmin.PUT("/users/:user_id", controllers.UpdateUserAdmin)    // Update user
-	protectedAdmin.DELETE("/users/:user_id", controllers.DeleteUserAdmin) // Hapus user
-
-	// Routes untuk Device Management (Hanya Admin)
-	protectedAdmin.POST("/devices", controllers.CreateDeviceAdmin)              // Tambah device
-	protectedAdmin.GET("/devices", controllers.GetAllDevicesAdmin)              // Dapatkan semua device
-	protectedAdmin.PUT("/devices/:device_id", controllers.UpdateDeviceAdmin)    // Update device
-	protectedAdmin.DELETE("/devices/:device_id", controllers.DeleteDeviceAdmin) // Hapus device
-
-	// Routes untuk Sensor Data Management (Hanya Admin)
-	protectedAdmin.GET("/sensors/:device_id", controllers.GetSensorDataByAdmin)     // Ambil data sensor dari device tertentu
-	protectedAdmin.DELETE("/sensors/:sensor_id", controllers.DeleteSensorDataAdmin) // Hapus data sensor tertentu
+	protectedAdmin.Use(middleware.AuthMiddleware()) // Query admin dibatasi ke organisasi admin (tenant), kecuali super-admin
+
+	// Routes untuk Organisasi (organizations:manage, hanya super-admin)
+	adminOrgs := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageOrganizations))
+	adminOrgs.GET("/organizations", controllers.GetOrganizationsAdmin)              // Daftar organisasi (klinik)
+	adminOrgs.POST("/organizations", controllers.CreateOrganizationAdmin)           // Tambah organisasi
+	adminOrgs.PUT("/organizations/:org_id", controllers.UpdateOrganizationAdmin)    // Ubah nama organisasi
+	adminOrgs.DELETE("/organizations/:org_id", controllers.DeleteOrganizationAdmin) // Hapus organisasi tanpa user
+
+	// Routes untuk User Management (users:manage)
+	adminUsers := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageUsers))
+	adminUsers.POST("/users", middleware.Audit("user.create"), controllers.CreateUserAdmin)                       // Tambah user
+	adminUsers.GET("/users", middleware.Audit("user.list"), controllers.GetAllUsersAdmin)                         // Dapatkan semua user
+	adminUsers.PUT("/users/:user_id", middleware.Audit("user.update"), controllers.UpdateUserAdmin)               // Update user
+	adminUsers.DELETE("/users/:user_id", middleware.Audit("user.delete"), controllers.DeleteUserAdmin)            // Hapus user (bisa dipulihkan selama masa tenggang)
+	adminUsers.GET("/users/deleted", controllers.GetDeletedUsersAdmin)                                            // Dapatkan user yang dihapus dan belum dihapus permanen
+	adminUsers.POST("/users/:user_id/restore", middleware.Audit("user.restore"), controllers.RestoreUserAdmin)    // Pulihkan user yang dihapus
+	adminUsers.DELETE("/users/:user_id/2fa", middleware.Audit("user.2fa_reset"), controllers.ResetTwoFactorAdmin) // Reset 2FA user yang kehilangan authenticator
+
+	// Routes untuk Kebijakan Keamanan (security:manage)
+	adminSecurity := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageSecurity))
+	adminSecurity.GET("/security-policy", controllers.GetSecurityPolicyAdmin)       // Lihat role yang wajib 2FA
+	adminSecurity.PUT("/security-policy", controllers.UpdateSecurityPolicyAdmin)    // Ubah role yang wajib 2FA
+	adminSecurity.GET("/lockouts", controllers.GetLockoutsAdmin)                    // Lihat username / IP yang sedang diblokir
+	adminSecurity.DELETE("/lockouts", controllers.ClearLockoutAdmin)                // Buka blokir (?key=login:user:<username>)
+	adminSecurity.GET("/security-events", controllers.GetSecurityEventsAdmin)       // Security log (login gagal, lockout, dll)
+	adminSecurity.GET("/encryption", controllers.GetEncryptionStatusAdmin)          // Jumlah data sensitif per key enkripsi (KEK)
+	adminSecurity.POST("/encryption/rotate", controllers.RotateEncryptionKeysAdmin) // Bungkus ulang data key dengan KEK aktif
+	adminSecurity.GET("/sensor-storage", controllers.GetSensorStorageAdmin)         // Mode penyimpanan sensor_data beserta partisi / chunk
+
+	// Routes untuk Audit Log (audit:read)
+	adminAudit := protectedAdmin.Group("", middleware.RequirePermission(models.PermReadAuditLog))
+	adminAudit.GET("/audit-logs", controllers.GetAuditLogsAdmin) // Audit log akses data profil & vital sign (filter actor, target, action, waktu)
+
+	// Routes untuk Retensi Data Sensor (retention:manage)
+	adminRetention := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageRetention))
+	adminRetention.GET("/organizations/:org_id/retention", controllers.GetRetentionPolicyAdmin)       // Policy retensi organisasi & retensi yang berlaku
+	adminRetention.PUT("/organizations/:org_id/retention", controllers.SetRetentionPolicyAdmin)       // Atur masa simpan data mentah / ringkasan per jam / per hari
+	adminRetention.DELETE("/organizations/:org_id/retention", controllers.DeleteRetentionPolicyAdmin) // Kembali ke retensi default
+	adminRetention.GET("/users/:user_id/retention", controllers.GetRetentionPolicyAdmin)              // Policy retensi user & retensi yang berlaku
+	adminRetention.PUT("/users/:user_id/retention", controllers.SetRetentionPolicyAdmin)              // Atur retensi khusus user (mengalahkan policy organisasi)
+	adminRetention.DELETE("/users/:user_id/retention", controllers.DeleteRetentionPolicyAdmin)        // Kembali ke retensi organisasi / default
+
+	// Routes untuk Device Management (devices:manage:all)
+	adminDevices := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageAllDevices))
+	adminDevices.POST("/devices", controllers.CreateDeviceAdmin)                          // Tambah device
+	adminDevices.GET("/devices", controllers.GetAllDevicesAdmin)                          // Dapatkan semua device
+	adminDevices.PUT("/devices/:device_id", controllers.UpdateDeviceAdmin)                // Update device
+	adminDevices.DELETE("/devices/:device_id", controllers.DeleteDeviceAdmin)             // Hapus device
+	adminDevices.POST("/devices/:device_id/rotate-key", controllers.RotateDeviceKeyAdmin) // Ganti API Key device
+
+	// Routes untuk Sensor Data & Alert (vitals:read:all)
+	adminVitals := protectedAdmin.Group("", middleware.RequirePermission(models.PermReadAllVitals))
+	adminVitals.GET("/sensors/:device_id", middleware.Audit("vitals.read"), controllers.GetSensorDataByAdmin)              // Ambil data sensor dari device tertentu
+	adminVitals.GET("/sensors/:device_id/aggregate", middleware.Audit("vitals.aggregate"), controllers.GetSensorAggregate) // Agregasi / downsampling data sensor
+	adminDevices.DELETE("/sensors/:sensor_id", middleware.Audit("vitals.delete"), controllers.DeleteSensorDataAdmin)       // Hapus data sensor tertentu
+
+	// Routes untuk Alert Management (membuat / menghapus alert rule memerlukan alert_rules:manage:all)
+	adminAlertRules := protectedAdmin.Group("", middleware.RequirePermission(models.PermManageAllAlertRules))
+	adminVitals.GET("/users/:user_id/alert-rules", middleware.Audit("alert_rule.read"), controllers.GetAlertRulesAdmin)          // Dapatkan alert rule milik user tertentu
+	adminAlertRules.POST("/users/:user_id/alert-rules", middleware.Audit("alert_rule.create"), controllers.CreateAlertRuleAdmin) // Tambah alert rule atas nama user
+	adminAlertRules.DELETE("/alert-rules/:rule_id", middleware.Audit("alert_rule.delete"), controllers.DeleteAlertRuleAdmin)     // Hapus alert rule
+	adminVitals.GET("/alerts", controllers.GetAllAlertsAdmin)                                                                    // Dapatkan semua alert
+	adminVitals.POST("/alerts/:alert_id/acknowledge", controllers.AcknowledgeAlertAdmin)                                         // Tandai alert sudah diketahui
 	return r
 }
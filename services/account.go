@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// Pengaturan penghapusan akun
+const (
+	defaultDeletionGraceDays = 30 // ACCOUNT_DELETION_GRACE_DAYS
+	accountRestorePurpose    = "restore_account"
+	accountPurgeInterval     = time.Hour
+	purgeBatchSize           = 5000                   // Jumlah data sensor yang dihapus per statement
+	purgeBatchPause          = 100 * time.Millisecond // Jeda antar batch agar tidak membebani database
+)
+
+// Error penghapusan / pemulihan akun
+var (
+	ErrAccountNotDeleted   = errors.New("account is not scheduled for deletion")
+	ErrInvalidRestoreToken = errors.New("invalid or expired restore link")
+)
+
+// AccountDeletionGrace - Masa tenggang sebelum akun yang dihapus dihapus permanen
+func AccountDeletionGrace() time.Duration {
+	return time.Duration(intFromEnv("ACCOUNT_DELETION_GRACE_DAYS", defaultDeletionGraceDays)) * 24 * time.Hour
+}
+
+// PurgeAt - Waktu akun yang dihapus akan dihapus permanen
+func PurgeAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(AccountDeletionGrace())
+}
+
+// SoftDeleteUser - Menandai akun sebagai dihapus (masih bisa dipulihkan selama masa tenggang),
+// mencabut semua sesi dan mengirim link pemulihan ke email user
+func SoftDeleteUser(user models.User) (time.Time, error) {
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+			return err
+		}
+		return RevokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	purgeAt := PurgeAt(now)
+	go sendRestoreEmail(user, now, purgeAt)
+	return purgeAt, nil
+}
+
+// sendRestoreEmail - Mengirim link pemulihan akun yang berlaku sampai akun dihapus permanen
+func sendRestoreEmail(user models.User, deletedAt, purgeAt time.Time) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":    accountRestorePurpose,
+		"user_id":    user.ID,
+		"deleted_at": deletedAt.Unix(),
+		"exp":        purgeAt.Unix(),
+	}).SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		log.Println("Failed to sign restore token:", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/account/restore?token=%s", AppBaseURL(), url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nYour account has been deleted. All of your data will be permanently removed on %s.\n\n"+
+		"If this was a mistake, restore your account by opening the link below:\n\n%s",
+		user.Username, purgeAt.Format("2006-01-02 15:04 MST"), link)
+	if err := SendMail(user.Email, "Your account has been deleted", body); err != nil {
+		log.Println("Failed to send account restore email:", err)
+	}
+}
+
+// RestoreUser - Memulihkan akun yang masih dalam masa tenggang
+func RestoreUser(userID uint) (*models.User, error) {
+	var user models.User
+	err := database.DB.Unscoped().Where("id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", userID, time.Now().Add(-AccountDeletionGrace())).
+		First(&user).Error
+	if err != nil {
+		return nil, ErrAccountNotDeleted
+	}
+
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("deleted_at", nil).Error; err != nil {
+		return nil, err
+	}
+	user.DeletedAt = gorm.DeletedAt{}
+	return &user, nil
+}
+
+// RestoreUserByToken - Memulihkan akun dari link yang dikirim lewat email
+func RestoreUserByToken(tokenString string) (*models.User, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidRestoreToken
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidRestoreToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != accountRestorePurpose {
+		return nil, ErrInvalidRestoreToken
+	}
+	userID, okUser := claims["user_id"].(float64)
+	deletedAt, okDeleted := claims["deleted_at"].(float64)
+	if !okUser || !okDeleted {
+		return nil, ErrInvalidRestoreToken
+	}
+
+	// Link hanya berlaku untuk penghapusan yang sama (bukan penghapusan berikutnya)
+	var user models.User
+	if err := database.DB.Unscoped().First(&user, uint(userID)).Error; err != nil ||
+		!user.DeletedAt.Valid || user.DeletedAt.Time.Unix() != int64(deletedAt) {
+		return nil, ErrInvalidRestoreToken
+	}
+	return RestoreUser(user.ID)
+}
+
+// StartAccountPurger - Worker yang menghapus permanen akun yang masa tenggangnya sudah habis
+func StartAccountPurger() {
+	go func() {
+		ticker := time.NewTicker(accountPurgeInterval)
+		defer ticker.Stop()
+		for {
+			purgeDeletedAccounts()
+			<-ticker.C
+		}
+	}()
+}
+
+// purgeDeletedAccounts - Menghapus permanen akun yang dihapus lebih lama dari masa tenggang
+func purgeDeletedAccounts() {
+	cutoff := time.Now().Add(-AccountDeletionGrace())
+	var users []models.User
+	err := database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).Find(&users).Error
+	if err != nil {
+		log.Println("Failed to load deleted accounts:", err)
+		return
+	}
+
+	for _, user := range users {
+		if err := purgeUser(user, cutoff); err != nil {
+			log.Printf("Failed to purge user %d: %v", user.ID, err)
+		}
+	}
+}
+
+// purgeUser - Menghapus permanen akun yang dihapus sebelum cutoff beserta device, data sensor dan data lain
+// (lewat ON DELETE CASCADE). Penghapusan dan audit log-nya ditulis dalam satu transaksi; akun yang sudah
+// di-purge instance lain atau dipulihkan di tengah jalan dilewati.
+func purgeUser(user models.User, cutoff time.Time) error {
+	var deviceIDs []uint
+	if err := database.DB.Model(&models.Device{}).Where("user_id = ?", user.ID).Pluck("id", &deviceIDs).Error; err != nil {
+		return err
+	}
+
+	// Data sensor dihapus bertahap lebih dulu agar cascade dari users tidak menjadi satu transaksi raksasa
+	var readings int64
+	for _, deviceID := range deviceIDs {
+		deleted, err := deleteSensorDataInBatches(user.ID, deviceID, cutoff)
+		readings += deleted
+		if err != nil {
+			return err
+		}
+	}
+
+	purged := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL AND deleted_at <= ?", user.ID, cutoff).
+			Delete(&models.User{})
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+		purged = true
+
+		// audit_logs tidak punya foreign key ke users, jadi bisa ditulis bersama penghapusan
+		return tx.Create(&models.AuditLog{
+			ActorRole:    "system",
+			Action:       "user.purge",
+			TargetUserID: &user.ID,
+			Outcome:      models.AuditSuccess,
+			Details: map[string]interface{}{
+				"deleted_at": user.DeletedAt.Time.Format(time.RFC3339),
+				"devices":    len(deviceIDs),
+				"readings":   readings,
+			},
+			CreatedAt: time.Now(),
+		}).Error
+	})
+	if err != nil || !purged {
+		return err
+	}
+	log.Printf("Purged user %d (%d devices, %d readings)", user.ID, len(deviceIDs), readings)
+	return nil
+}
+
+// deleteSensorDataInBatches - Menghapus data sensor satu device per purgeBatchSize baris selama pemiliknya
+// masih terhapus sebelum cutoff (berhenti jika akun dipulihkan), mengembalikan jumlah yang dihapus
+func deleteSensorDataInBatches(userID, deviceID uint, cutoff time.Time) (int64, error) {
+	var total int64
+	for {
+		result := database.DB.Exec(`DELETE FROM sensor_data WHERE device_id = ? AND id IN (
+			SELECT id FROM sensor_data WHERE device_id = ? LIMIT ?)
+			AND EXISTS (SELECT 1 FROM users WHERE id = ? AND deleted_at IS NOT NULL AND deleted_at <= ?)`,
+			deviceID, deviceID, purgeBatchSize, userID, cutoff)
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < purgeBatchSize {
+			return total, nil
+		}
+		time.Sleep(purgeBatchPause)
+	}
+}
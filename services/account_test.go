@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+
+	"gorm.io/gorm"
+)
+
+func TestPurgeUserDeletesReadingsAndRecordsDetails(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "purge")
+	for i := 0; i < 3; i++ {
+		storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: time.Now().Add(-time.Duration(i) * time.Minute)})
+	}
+
+	var user models.User
+	db.First(&user, device.UserID)
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now().Add(-time.Hour), Valid: true}
+	db.Model(&user).UpdateColumn("deleted_at", user.DeletedAt)
+	if err := purgeUser(user, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// Purge kedua (instance lain) tidak menghapus atau mencatat ulang
+	if err := purgeUser(user, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var remaining int64
+	db.Unscoped().Model(&models.User{}).Where("id = ?", user.ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatal("expected user to be deleted")
+	}
+	var entries []models.AuditLog
+	if err := db.Where("action = ? AND target_user_id = ?", "user.purge", user.ID).Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one purge audit entry, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Path != "" || entry.Details["devices"] != float64(1) || entry.Details["readings"] != float64(3) {
+		t.Fatalf("expected purge details in the details column, got path %q details %v", entry.Path, entry.Details)
+	}
+}
+
+func TestPurgeUserSkipsRestoredAccount(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "purge-restored")
+	storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: time.Now()})
+
+	// Akun sudah dipulihkan setelah daftar purge dimuat
+	var user models.User
+	db.First(&user, device.UserID)
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now().Add(-time.Hour), Valid: true}
+	if err := purgeUser(user, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var users, readings, audits int64
+	db.Model(&models.User{}).Where("id = ?", user.ID).Count(&users)
+	db.Model(&models.SensorData{}).Where("device_id = ?", device.ID).Count(&readings)
+	db.Model(&models.AuditLog{}).Where("action = ? AND target_user_id = ?", "user.purge", user.ID).Count(&audits)
+	if users != 1 || readings != 1 || audits != 0 {
+		t.Fatalf("expected restored account untouched, got users=%d readings=%d audits=%d", users, readings, audits)
+	}
+}
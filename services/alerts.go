@@ -0,0 +1,250 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metrik dan operator yang didukung AlertRule
+var (
+	AlertMetrics   = map[string]bool{"bpm": true, "spo2": true, "temp": true}
+	AlertOperators = map[string]bool{"<": true, "<=": true, ">": true, ">=": true}
+)
+
+// Batas jumlah data berturut-turut dalam satu rule
+const MaxConsecutiveReadings = 100
+
+// metricValue - Mengambil nilai metrik dari data sensor
+func metricValue(data models.SensorData, metric string) float64 {
+	switch metric {
+	case "bpm":
+		return data.BPM
+	case "spo2":
+		return data.SpO2
+	default:
+		return data.Temp
+	}
+}
+
+// violates - Mengecek apakah nilai melanggar batas pada rule
+func violates(rule models.AlertRule, value float64) bool {
+	switch rule.Operator {
+	case "<":
+		return value < rule.Threshold
+	case "<=":
+		return value <= rule.Threshold
+	case ">":
+		return value > rule.Threshold
+	case ">=":
+		return value >= rule.Threshold
+	}
+	return false
+}
+
+// EvaluateAlerts - Mengevaluasi semua rule aktif untuk device berdasarkan data terbaru.
+// Alert baru dibuat jika N data terakhir melanggar rule dan belum ada alert aktif,
+// dan alert aktif otomatis di-resolve ketika data terbaru kembali normal.
+func EvaluateAlerts(deviceID uint) {
+	device, rules, needed, ok := loadAlertRules(deviceID)
+	if !ok {
+		return
+	}
+
+	recent, err := latestReadings(deviceID, needed)
+	if err != nil {
+		log.Println("Alert evaluation failed to load sensor data:", err)
+		return
+	}
+	if len(recent) == 0 {
+		return
+	}
+
+	for _, rule := range rules {
+		applyRule(device, rule, recent, findActiveAlert(rule, device))
+	}
+}
+
+// EvaluateAlertsForReadings - Mengevaluasi rule untuk setiap data yang baru disimpan (batch / backfill)
+// sesuai urutan timestamp, sehingga pelanggaran di tengah batch tetap memicu alert walaupun data
+// terakhirnya normal. Data lama di sekitar batch ikut dipakai sebagai jendela data berturut-turut.
+// Setelah itu rule dievaluasi sekali lagi terhadap data terbaru device.
+func EvaluateAlertsForReadings(deviceID uint, inserted []models.SensorData) {
+	if len(inserted) == 0 {
+		return
+	}
+	device, rules, needed, ok := loadAlertRules(deviceID)
+	if !ok {
+		return
+	}
+
+	from, to := inserted[0].Timestamp, inserted[0].Timestamp
+	insertedIDs := make(map[uint]bool, len(inserted))
+	for _, reading := range inserted {
+		insertedIDs[reading.ID] = true
+		if reading.Timestamp.Before(from) {
+			from = reading.Timestamp
+		}
+		if reading.Timestamp.After(to) {
+			to = reading.Timestamp
+		}
+	}
+
+	// Data sebelum batch (untuk jendela data pertama) lalu seluruh data dalam rentang batch, urut lama ke baru
+	var before, series []models.SensorData
+	if err := database.DB.Where("device_id = ? AND timestamp < ?", deviceID, from).
+		Order("timestamp DESC, id DESC").Limit(needed - 1).Find(&before).Error; err != nil {
+		log.Println("Alert evaluation failed to load sensor data:", err)
+		return
+	}
+	if err := database.DB.Where("device_id = ? AND timestamp BETWEEN ? AND ?", deviceID, from, to).
+		Order("timestamp, id").Find(&series).Error; err != nil {
+		log.Println("Alert evaluation failed to load sensor data:", err)
+		return
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		series = append([]models.SensorData{before[i]}, series...)
+	}
+
+	for _, rule := range rules {
+		active := findActiveAlert(rule, device)
+		for i, reading := range series {
+			// Data yang lebih lama dari alert aktif tidak mengubah alert tersebut
+			if !insertedIDs[reading.ID] || (active != nil && reading.Timestamp.Before(active.TriggeredAt)) {
+				continue
+			}
+			// Jendela data berturut-turut yang berakhir di data ini (terbaru dulu)
+			start := i - needed + 1
+			if start < 0 {
+				start = 0
+			}
+			recent := make([]models.SensorData, 0, i-start+1)
+			for j := i; j >= start; j-- {
+				recent = append(recent, series[j])
+			}
+			active = applyRule(device, rule, recent, active)
+		}
+	}
+
+	EvaluateAlerts(deviceID)
+}
+
+// loadAlertRules - Device, rule aktif miliknya dan jumlah data terbanyak yang dibutuhkan satu rule
+func loadAlertRules(deviceID uint) (models.Device, []models.AlertRule, int, bool) {
+	var device models.Device
+	if err := database.DB.First(&device, deviceID).Error; err != nil {
+		log.Println("Alert evaluation failed to load device:", err)
+		return device, nil, 0, false
+	}
+
+	var rules []models.AlertRule
+	err := database.DB.Where("user_id = ? AND enabled = ? AND (device_id IS NULL OR device_id = ?)", device.UserID, true, deviceID).
+		Find(&rules).Error
+	if err != nil || len(rules) == 0 {
+		return device, nil, 0, false
+	}
+
+	// Ambil data sebanyak kebutuhan rule dengan jumlah berturut-turut terbesar
+	needed := 1
+	for _, rule := range rules {
+		if rule.Consecutive > needed {
+			needed = rule.Consecutive
+		}
+	}
+	return device, rules, needed, true
+}
+
+// latestReadings - Data sensor terbaru device (terbaru dulu)
+func latestReadings(deviceID uint, limit int) ([]models.SensorData, error) {
+	var recent []models.SensorData
+	err := database.DB.Where("device_id = ?", deviceID).Order("timestamp DESC, id DESC").Limit(limit).Find(&recent).Error
+	return recent, err
+}
+
+// findActiveAlert - Alert yang masih aktif (open atau acknowledged) untuk rule & device, nil jika tidak ada
+func findActiveAlert(rule models.AlertRule, device models.Device) *models.Alert {
+	var active models.Alert
+	if err := database.DB.Where("rule_id = ? AND device_id = ? AND status IN ?", rule.ID, device.ID,
+		[]string{models.AlertStatusOpen, models.AlertStatusAcknowledged}).First(&active).Error; err != nil {
+		return nil
+	}
+	return &active
+}
+
+// applyRule - Mengevaluasi satu rule terhadap jendela data (terbaru dulu) dan mengembalikan
+// alert yang aktif setelahnya
+func applyRule(device models.Device, rule models.AlertRule, recent []models.SensorData, active *models.Alert) *models.Alert {
+	consecutive := rule.Consecutive
+	if consecutive < 1 {
+		consecutive = 1
+	}
+
+	latest := recent[0]
+	triggered := len(recent) >= consecutive
+	for i := 0; triggered && i < consecutive; i++ {
+		triggered = violates(rule, metricValue(recent[i], rule.Metric))
+	}
+
+	switch {
+	case triggered && active == nil:
+		value := metricValue(latest, rule.Metric)
+		alert := models.Alert{
+			RuleID:       &rule.ID,
+			UserID:       device.UserID,
+			DeviceID:     device.ID,
+			Type:         models.AlertTypeThreshold,
+			SensorDataID: &latest.ID,
+			Metric:       rule.Metric,
+			Value:        value,
+			Threshold:    rule.Threshold,
+			Severity:     rule.Severity,
+			Message: fmt.Sprintf("%s %s %v on device %q (value %v, %d consecutive readings)",
+				rule.Metric, rule.Operator, rule.Threshold, device.Name, value, consecutive),
+			Status:      models.AlertStatusOpen,
+			TriggeredAt: latest.Timestamp,
+		}
+
+		// Alert dan antrian notifikasinya disimpan dalam satu transaksi. Jika evaluasi lain untuk device yang sama
+		// sudah membuat alert aktif (unique index parsial), alert itu yang dipakai dan notifikasi tidak dikirim ulang.
+		created := false
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{
+				Columns:     []clause.Column{{Name: "rule_id"}, {Name: "device_id"}},
+				TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN ('open', 'acknowledged')"}}}, // Harus literal agar cocok dengan index parsial
+				DoNothing:   true,
+			}).Create(&alert)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			created = true
+			return EnqueueAlertNotifications(tx, alert)
+		})
+		if err != nil {
+			log.Println("Failed to create alert:", err)
+			return nil
+		}
+		if !created {
+			return findActiveAlert(rule, device)
+		}
+		return &alert
+
+	case active != nil && !violates(rule, metricValue(latest, rule.Metric)):
+		// Data sudah normal, tutup alert secara otomatis pada waktu data tersebut
+		resolvedAt := latest.Timestamp
+		if err := database.DB.Model(active).Where("status IN ?", []string{models.AlertStatusOpen, models.AlertStatusAcknowledged}).
+			Updates(map[string]interface{}{
+				"status":      models.AlertStatusResolved,
+				"resolved_at": &resolvedAt,
+			}).Error; err != nil {
+			log.Println("Failed to resolve alert:", err)
+			return active
+		}
+		return nil
+	}
+	return active
+}
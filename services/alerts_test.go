@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestEvaluateAlertsForReadingsCatchesEpisodeInsideBatch(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "batch-alert")
+
+	rule := models.AlertRule{UserID: device.UserID, Metric: "bpm", Operator: ">", Threshold: 120, Consecutive: 2, Severity: "critical", Enabled: true}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Pelanggaran di tengah batch, data terakhir batch sudah normal
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	var inserted []models.SensorData
+	for i, bpm := range []float64{80, 130, 135, 140, 82} {
+		reading, _ := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: bpm, SpO2: 98, Temp: 36.6, Timestamp: start.Add(time.Duration(i) * time.Minute)})
+		inserted = append(inserted, reading)
+	}
+
+	EvaluateAlertsForReadings(device.ID, inserted)
+
+	var alerts []models.Alert
+	if err := db.Where("rule_id = ?", rule.ID).Find(&alerts).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected one alert for the episode, got %d", len(alerts))
+	}
+	alert := alerts[0]
+	if !alert.TriggeredAt.Equal(inserted[2].Timestamp) || alert.Status != models.AlertStatusResolved {
+		t.Fatalf("expected alert triggered at %v and resolved, got %v (%s)", inserted[2].Timestamp, alert.TriggeredAt, alert.Status)
+	}
+}
+
+func TestApplyRuleDoesNotDuplicateActiveAlert(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "alert-race")
+	rule := models.AlertRule{UserID: device.UserID, Metric: "bpm", Operator: ">", Threshold: 120, Consecutive: 1, Severity: "critical", Enabled: true}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.NotificationChannel{UserID: device.UserID, Type: "email", Target: "alert-race@example.com", Enabled: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+	reading, _ := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 130, SpO2: 98, Temp: 36.6, Timestamp: time.Now()})
+
+	// Dua evaluasi paralel sama-sama belum melihat alert aktif
+	first := applyRule(device, rule, []models.SensorData{reading}, nil)
+	second := applyRule(device, rule, []models.SensorData{reading}, nil)
+	if first == nil || second == nil || first.ID != second.ID {
+		t.Fatalf("expected both evaluations to share one alert, got %+v and %+v", first, second)
+	}
+
+	var alerts, notifications int64
+	db.Model(&models.Alert{}).Where("rule_id = ?", rule.ID).Count(&alerts)
+	db.Model(&models.NotificationOutbox{}).Where("alert_id = ?", first.ID).Count(&notifications)
+	if alerts != 1 || notifications != 1 {
+		t.Fatalf("expected one alert with one notification, got %d alerts and %d notifications", alerts, notifications)
+	}
+}
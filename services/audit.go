@@ -0,0 +1,136 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Key gin.Context untuk pasien / device yang datanya diakses, diisi handler untuk audit log.
+// AuditTargetUsersKey ([]uint) dipakai jika satu respons berisi data beberapa pasien.
+const (
+	AuditTargetUserKey   = "audit_target_user_id"
+	AuditTargetUsersKey  = "audit_target_user_ids"
+	AuditTargetDeviceKey = "audit_target_device_id"
+)
+
+// Percobaan ulang penulisan audit log sebelum dianggap gagal
+const (
+	auditWriteAttempts = 3
+	auditRetryDelay    = 100 * time.Millisecond
+)
+
+// Jumlah maksimum audit log per query
+const auditQueryLimit = 500
+
+// AuditFilter - Filter query audit log (field kosong / nil diabaikan)
+type AuditFilter struct {
+	ActorID        *uint
+	TargetUserID   *uint
+	TargetDeviceID *uint
+	Action         string
+	Outcome        string
+	From           *time.Time
+	To             *time.Time
+	Limit          int
+}
+
+// AccessRecord - Satu akses ke data pasien untuk tampilan "siapa yang mengakses data saya"
+type AccessRecord struct {
+	At             time.Time `json:"at"`
+	ActorID        *uint     `json:"actor_id"`
+	ActorName      string    `json:"actor_name"`
+	ActorRole      string    `json:"actor_role"`
+	Action         string    `json:"action"`
+	TargetDeviceID *uint     `json:"target_device_id"`
+	Outcome        string    `json:"outcome"`
+}
+
+// RecordAudit - Menambahkan catatan ke audit log dalam satu INSERT, dicoba ulang beberapa kali
+// jika database sedang bermasalah. Error dikembalikan agar akses data pasien bisa digagalkan.
+func RecordAudit(entries ...models.AuditLog) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for i := range entries {
+		if entries[i].CreatedAt.IsZero() {
+			entries[i].CreatedAt = now
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= auditWriteAttempts; attempt++ {
+		if err = database.DB.Create(&entries).Error; err == nil {
+			return nil
+		}
+		if attempt < auditWriteAttempts {
+			time.Sleep(time.Duration(attempt) * auditRetryDelay)
+		}
+	}
+	log.Println("Failed to write audit log:", err)
+	return err
+}
+
+// QueryAuditLogs - Mengambil audit log terbaru sesuai filter lewat db (koneksi yang dibatasi ke tenant)
+func QueryAuditLogs(db *gorm.DB, filter AuditFilter) ([]models.AuditLog, error) {
+	if filter.Limit <= 0 || filter.Limit > auditQueryLimit {
+		filter.Limit = auditQueryLimit
+	}
+
+	query := db.Order("created_at DESC, id DESC").Limit(filter.Limit)
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.TargetUserID != nil {
+		query = query.Where("target_user_id = ?", *filter.TargetUserID)
+	}
+	if filter.TargetDeviceID != nil {
+		query = query.Where("target_device_id = ?", *filter.TargetDeviceID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	var logs []models.AuditLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
+
+// AccessHistory - Akses user lain ke data pasien (akses pasien ke datanya sendiri tidak ditampilkan)
+func AccessHistory(patientID uint, from, to *time.Time, limit int) ([]AccessRecord, error) {
+	if limit <= 0 || limit > auditQueryLimit {
+		limit = auditQueryLimit
+	}
+
+	query := database.DB.Table("audit_logs").
+		Select("audit_logs.created_at AS at, audit_logs.actor_id, COALESCE(users.full_name, users.username, '') AS actor_name, "+
+			"audit_logs.actor_role, audit_logs.action, audit_logs.target_device_id, audit_logs.outcome").
+		Joins("LEFT JOIN users ON users.id = audit_logs.actor_id").
+		Where("audit_logs.target_user_id = ? AND (audit_logs.actor_id IS NULL OR audit_logs.actor_id <> ?)", patientID, patientID).
+		Order("audit_logs.created_at DESC, audit_logs.id DESC").
+		Limit(limit)
+	if from != nil {
+		query = query.Where("audit_logs.created_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("audit_logs.created_at <= ?", *to)
+	}
+
+	var records []AccessRecord
+	err := query.Scan(&records).Error
+	return records, err
+}
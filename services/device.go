@@ -0,0 +1,117 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+)
+
+// Panjang prefix API Key yang disimpan untuk pencarian
+const apiKeyPrefixLength = 8
+
+// ErrInvalidAPIKey - API Key tidak ditemukan atau sudah kedaluwarsa
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// HashAPIKey - Menghasilkan prefix dan hash SHA-256 dari API Key.
+// API Key berupa 128-bit acak sehingga SHA-256 cukup (tidak perlu bcrypt yang lambat per request).
+func HashAPIKey(apiKey string) (prefix, hash string) {
+	sum := sha256.Sum256([]byte(apiKey))
+	prefix = apiKey
+	if len(prefix) > apiKeyPrefixLength {
+		prefix = prefix[:apiKeyPrefixLength]
+	}
+	return prefix, hex.EncodeToString(sum[:])
+}
+
+// SetAPIKey - Menyimpan hash API Key ke device (plaintext tidak disimpan)
+func SetAPIKey(device *models.Device, apiKey string) {
+	device.APIKeyPrefix, device.APIKeyHash = HashAPIKey(apiKey)
+	device.APIKey = nil
+}
+
+// FindDeviceByAPIKey - Mencari device berdasarkan API Key aktif atau API Key lama yang masih dalam masa transisi
+func FindDeviceByAPIKey(apiKey string) (*models.Device, error) {
+	if apiKey == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	prefix, hash := HashAPIKey(apiKey)
+
+	// Device milik akun yang sudah dihapus tidak bisa mengirim data lagi
+	var candidates []models.Device
+	err := database.DB.Where("(api_key_prefix = ? OR (previous_api_key_prefix = ? AND previous_api_key_expires_at > ?)) AND user_id IN (?)",
+		prefix, prefix, time.Now(), database.DB.Model(&models.User{}).Select("id")).Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		device := &candidates[i]
+		if subtle.ConstantTimeCompare([]byte(device.APIKeyHash), []byte(hash)) == 1 {
+			return device, nil
+		}
+		if device.PreviousAPIKeyHash != nil && device.PreviousAPIKeyExpiresAt != nil &&
+			device.PreviousAPIKeyExpiresAt.After(time.Now()) &&
+			subtle.ConstantTimeCompare([]byte(*device.PreviousAPIKeyHash), []byte(hash)) == 1 {
+			return device, nil
+		}
+	}
+	return nil, ErrInvalidAPIKey
+}
+
+// RotateAPIKey - Mengganti API Key device. API Key lama tetap berlaku selama grace,
+// sehingga perangkat di lapangan bisa di-flash ulang tanpa downtime.
+func RotateAPIKey(device *models.Device, newKey string, grace time.Duration) error {
+	if grace > 0 {
+		expiresAt := time.Now().Add(grace)
+		prefix, hash := device.APIKeyPrefix, device.APIKeyHash
+		device.PreviousAPIKeyPrefix = &prefix
+		device.PreviousAPIKeyHash = &hash
+		device.PreviousAPIKeyExpiresAt = &expiresAt
+	} else {
+		device.PreviousAPIKeyPrefix = nil
+		device.PreviousAPIKeyHash = nil
+		device.PreviousAPIKeyExpiresAt = nil
+	}
+
+	SetAPIKey(device, newKey)
+	return database.DB.Model(&models.Device{}).Where("id = ?", device.ID).Updates(map[string]interface{}{
+		"api_key":                     nil,
+		"api_key_prefix":              device.APIKeyPrefix,
+		"api_key_hash":                device.APIKeyHash,
+		"previous_api_key_prefix":     device.PreviousAPIKeyPrefix,
+		"previous_api_key_hash":       device.PreviousAPIKeyHash,
+		"previous_api_key_expires_at": device.PreviousAPIKeyExpiresAt,
+	}).Error
+}
+
+// legacyAPIKeyCondition - Device yang masih menyimpan API Key plaintext dari sebelum hashing diterapkan
+const legacyAPIKeyCondition = "api_key IS NOT NULL AND api_key <> ''"
+
+// HashLegacyAPIKeys - Meng-hash API Key plaintext yang tersimpan sebelum hashing diterapkan
+// (dijalankan lewat `go run ./cmd/migrate data`)
+func HashLegacyAPIKeys() (int, error) {
+	var devices []models.Device
+	if err := database.DB.Where(legacyAPIKeyCondition).Find(&devices).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range devices {
+		device := &devices[i]
+		SetAPIKey(device, *device.APIKey)
+		err := database.DB.Model(&models.Device{}).Where("id = ?", device.ID).Updates(map[string]interface{}{
+			"api_key":        nil,
+			"api_key_prefix": device.APIKeyPrefix,
+			"api_key_hash":   device.APIKeyHash,
+		}).Error
+		if err != nil {
+			return i, fmt.Errorf("device %d: %w", device.ID, err)
+		}
+	}
+	return len(devices), nil
+}
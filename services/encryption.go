@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/fieldcrypt"
+
+	"gorm.io/gorm"
+)
+
+// Kolom users yang dienkripsi (sesuai tag serializer:encrypted di models.User)
+var encryptedUserColumns = []string{"date_of_birth", "medical_history", "address", "totp_secret"}
+
+// Batas percobaan ulang rotasi satu baris yang terus diubah request lain
+const rotateRowRetries = 5
+
+// errRotationConflict - Baris selalu berubah di antara baca dan tulis selama rotasi
+var errRotationConflict = errors.New("row kept changing during rotation")
+
+// encryptedUserRow - Nilai mentah kolom terenkripsi (tanpa serializer)
+type encryptedUserRow struct {
+	ID             uint
+	DateOfBirth    *string
+	MedicalHistory *string
+	Address        *string
+	TOTPSecret     *string
+}
+
+// values - Nilai mentah per kolom
+func (r encryptedUserRow) values() map[string]*string {
+	return map[string]*string{
+		"date_of_birth":   r.DateOfBirth,
+		"medical_history": r.MedicalHistory,
+		"address":         r.Address,
+		"totp_secret":     r.TOTPSecret,
+	}
+}
+
+// InitFieldEncryption - Memuat KEK dari FIELD_ENCRYPTION_KEYS. Server tidak dijalankan tanpa key
+// agar data sensitif tidak tersimpan plaintext. Data lama dienkripsi lewat `go run ./cmd/migrate data`.
+func InitFieldEncryption() {
+	if err := fieldcrypt.LoadFromEnv(); err != nil {
+		log.Fatal("❌ Field encryption is not configured: ", err)
+	}
+}
+
+// RotateFieldEncryption - Mengenkripsi nilai plaintext lama dan nilai v1 (belum terikat ke baris),
+// lalu membungkus ulang data key dengan KEK aktif. Ciphertext v2 tidak berubah saat rotasi,
+// hanya data key terbungkusnya.
+func RotateFieldEncryption() (encrypted, rewrapped int, err error) {
+	var rows []encryptedUserRow
+	result := database.DB.Table("users").Select(append([]string{"id"}, encryptedUserColumns...)).
+		FindInBatches(&rows, 200, func(tx *gorm.DB, batch int) error {
+			for _, row := range rows {
+				e, r, err := rotateUserRow(row)
+				if err != nil {
+					return err
+				}
+				encrypted += e
+				rewrapped += r
+			}
+			return nil
+		})
+	return encrypted, rewrapped, result.Error
+}
+
+// rotateUserRow - Menulis nilai hasil rotasi satu baris. UPDATE hanya berlaku jika kolom yang
+// diubah masih berisi nilai yang dibaca, sehingga perubahan profil di antaranya tidak tertimpa;
+// jika tidak ada baris yang berubah, baris dibaca ulang dan dirotasi lagi.
+func rotateUserRow(row encryptedUserRow) (encrypted, rewrapped int, err error) {
+	for attempt := 0; attempt < rotateRowRetries; attempt++ {
+		updates, e, r, err := rotatedValues(row)
+		if err != nil {
+			return 0, 0, fmt.Errorf("user %d: %w", row.ID, err)
+		}
+		if len(updates) == 0 {
+			return 0, 0, nil
+		}
+
+		query := database.DB.Table("users").Where("id = ?", row.ID)
+		current := row.values()
+		for column := range updates {
+			query = query.Where(column+" = ?", *current[column])
+		}
+		result := query.UpdateColumns(updates)
+		if result.Error != nil {
+			return 0, 0, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return e, r, nil
+		}
+
+		id := row.ID
+		row = encryptedUserRow{}
+		reload := database.DB.Table("users").Select(append([]string{"id"}, encryptedUserColumns...)).
+			Where("id = ?", id).Limit(1).Scan(&row)
+		if reload.Error != nil {
+			return 0, 0, reload.Error
+		}
+		if reload.RowsAffected == 0 {
+			return 0, 0, nil // Sudah dihapus permanen
+		}
+	}
+	return 0, 0, fmt.Errorf("user %d: %w", row.ID, errRotationConflict)
+}
+
+// rotatedValues - Nilai baru untuk kolom yang perlu dienkripsi, dienkripsi ulang atau dibungkus ulang
+func rotatedValues(row encryptedUserRow) (updates map[string]interface{}, encrypted, rewrapped int, err error) {
+	updates = map[string]interface{}{}
+	for column, value := range row.values() {
+		if value == nil {
+			continue
+		}
+
+		if !fieldcrypt.IsRowBound(*value) {
+			ciphertext, err := encryptLegacyValue(column, row.ID, *value)
+			if err != nil {
+				return nil, 0, 0, fmt.Errorf("%s: %w", column, err)
+			}
+			updates[column] = ciphertext
+			encrypted++
+			continue
+		}
+
+		wrapped, changed, err := fieldcrypt.Rewrap(*value)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("%s: %w", column, err)
+		}
+		if changed {
+			updates[column] = wrapped
+			rewrapped++
+		}
+	}
+	return updates, encrypted, rewrapped, nil
+}
+
+// encryptLegacyValue - Mengenkripsi nilai plaintext atau v1 lama dengan format yang sama seperti serializer
+func encryptLegacyValue(column string, id uint, value string) (string, error) {
+	plaintext := value
+	if fieldcrypt.IsEncrypted(value) {
+		decrypted, err := fieldcrypt.Decrypt(value, fieldcrypt.ColumnAAD("users", column))
+		if err != nil {
+			return "", err
+		}
+		plaintext = string(decrypted)
+	} else if column == "date_of_birth" {
+		parsed, err := fieldcrypt.ParseTime(value)
+		if err != nil {
+			return "", err
+		}
+		plaintext = parsed.Format(time.RFC3339Nano)
+	}
+	return fieldcrypt.Encrypt([]byte(plaintext), fieldcrypt.RowAAD("users", column, id))
+}
+
+// countUnencryptedUsers - Jumlah user yang masih memiliki nilai plaintext atau v1 di kolom terenkripsi
+func countUnencryptedUsers() (int64, error) {
+	conditions := make([]string, 0, len(encryptedUserColumns))
+	for _, column := range encryptedUserColumns {
+		conditions = append(conditions, fmt.Sprintf("(%[1]s IS NOT NULL AND %[1]s NOT LIKE 'enc:v2:%%')", column))
+	}
+	var total int64
+	err := database.DB.Table("users").Where(strings.Join(conditions, " OR ")).Count(&total).Error
+	return total, err
+}
+
+// FieldEncryptionStatus - Jumlah nilai per KEK untuk setiap kolom terenkripsi ("plaintext" = belum dienkripsi,
+// "v1:<id KEK>" = belum terikat ke baris, keduanya dienkripsi ulang oleh rotasi)
+func FieldEncryptionStatus() (map[string]map[string]int64, error) {
+	status := map[string]map[string]int64{}
+	for _, column := range encryptedUserColumns {
+		var counts []struct {
+			KeyID string
+			Total int64
+		}
+		err := database.DB.Table("users").
+			Select(fmt.Sprintf("CASE WHEN %[1]s LIKE 'enc:v2:%%' THEN split_part(%[1]s, ':', 3) "+
+				"WHEN %[1]s LIKE 'enc:v1:%%' THEN 'v1:' || split_part(%[1]s, ':', 3) ELSE 'plaintext' END AS key_id, COUNT(*) AS total", column)).
+			Where(column + " IS NOT NULL").
+			Group("key_id").
+			Scan(&counts).Error
+		if err != nil {
+			return nil, err
+		}
+
+		status[column] = map[string]int64{}
+		for _, c := range counts {
+			status[column][c.KeyID] = c.Total
+		}
+	}
+	return status, nil
+}
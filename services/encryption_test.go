@@ -0,0 +1,59 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"backend/fieldcrypt"
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestRotateFieldEncryptionKeepsConcurrentUpdate(t *testing.T) {
+	db := testdb.Open(t)
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := fieldcrypt.NewKeyring("k1:" + base64.StdEncoding.EncodeToString(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldcrypt.SetKeyring(keys)
+	t.Cleanup(func() { fieldcrypt.SetKeyring(nil) })
+
+	user := models.User{Username: "rotate", Password: "x", Email: "rotate@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Plaintext lama yang belum dienkripsi
+	if err := db.Exec("UPDATE users SET medical_history = ? WHERE id = ?", "old history", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var stale encryptedUserRow
+	if err := db.Table("users").Where("id = ?", user.ID).Scan(&stale).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Profil diubah setelah rotasi membaca baris: nilai baru tidak boleh tertimpa
+	newHistory := "new history"
+	user.MedicalHistory = &newHistory
+	if err := db.Save(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := rotateUserRow(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	var reloaded models.User
+	if err := db.First(&reloaded, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.MedicalHistory == nil || *reloaded.MedicalHistory != newHistory {
+		t.Fatalf("expected %q after rotation, got %v", newHistory, reloaded.MedicalHistory)
+	}
+}
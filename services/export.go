@@ -0,0 +1,564 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pengaturan export data pasien
+const (
+	exportFileTTL       = 24 * time.Hour   // File export dihapus setelah waktu ini
+	exportLinkTTL       = 15 * time.Minute // Masa berlaku link download
+	exportPurpose       = "data_export"
+	exportWorkers       = 2 // Jumlah export yang diproses bersamaan per instance
+	exportLease         = 2 * time.Minute
+	exportHeartbeat     = 30 * time.Second // Lease diperpanjang selama export berjalan
+	exportMaxAttempts   = 3
+	exportChunkSize     = 4 << 20 // Ukuran potongan file export di database
+	exportPollInterval  = 30 * time.Second
+	exportJanitorPeriod = time.Hour
+)
+
+// Error export data
+var (
+	ErrExportInProgress = errors.New("an export is already in progress")
+	ErrExportNotReady   = errors.New("export is not ready for download")
+	ErrInvalidExportURL = errors.New("invalid or expired download link")
+	errExportLeaseLost  = errors.New("export lease was taken over by another instance")
+)
+
+// Membatasi jumlah export yang berjalan bersamaan
+var exportSlots = make(chan struct{}, exportWorkers)
+
+// exportInstanceID - Identitas instance ini sebagai pemegang lease export
+var exportInstanceID = func() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}()
+
+// RequestExport - Membuat permintaan export dan memprosesnya di background.
+// Satu user hanya boleh punya satu export yang berjalan (dijaga unique index parsial).
+func RequestExport(userID uint) (*models.DataExport, error) {
+	export := models.DataExport{UserID: userID, Status: models.ExportPending}
+	result := database.DB.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "user_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN ('pending', 'running')"}}}, // Harus literal agar cocok dengan index parsial
+		DoNothing:   true,
+	}).Create(&export)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrExportInProgress
+	}
+
+	go processExports()
+	return &export, nil
+}
+
+// processExports - Mengerjakan export yang menunggu, atau yang lease-nya habis karena instance
+// pemegangnya berhenti, selama masih ada slot kosong
+func processExports() {
+	for {
+		select {
+		case exportSlots <- struct{}{}:
+		default:
+			return // Semua slot terpakai, sisanya diambil setelah export lain selesai
+		}
+
+		export, err := claimExport()
+		if err != nil || export == nil {
+			<-exportSlots
+			if err != nil {
+				log.Println("Export claim failed:", err)
+			}
+			return
+		}
+
+		go func() {
+			runExport(*export)
+			<-exportSlots
+			processExports()
+		}()
+	}
+}
+
+// claimExport - Mengambil satu export dan memasang lease atas nama instance ini
+func claimExport() (*models.DataExport, error) {
+	now := time.Now()
+
+	// Export yang sudah terlalu sering terhenti tidak dicoba lagi
+	err := database.DB.Model(&models.DataExport{}).
+		Where("status = ? AND lease_expires_at < ? AND attempts >= ?", models.ExportRunning, now, exportMaxAttempts).
+		Updates(map[string]interface{}{"status": models.ExportFailed, "error": "Export was interrupted, please try again"}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []models.DataExport
+	err = database.DB.Raw(`
+		UPDATE data_exports
+		SET status = ?, lease_owner = ?, lease_expires_at = ?, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM data_exports
+			WHERE status = ? OR (status = ? AND lease_expires_at < ?)
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, models.ExportRunning, exportInstanceID, now.Add(exportLease),
+		models.ExportPending, models.ExportRunning, now).Scan(&exports).Error
+	if err != nil || len(exports) == 0 {
+		return nil, err
+	}
+	return &exports[0], nil
+}
+
+// leasedExport - Query export yang masih dipegang instance ini pada percobaan yang sama
+func leasedExport(export models.DataExport) *gorm.DB {
+	return database.DB.Model(&models.DataExport{}).
+		Where("id = ? AND lease_owner = ? AND attempts = ?", export.ID, exportInstanceID, export.Attempts)
+}
+
+// runExport - Membuat file ZIP export dan memperbarui statusnya. Lease diperpanjang berkala;
+// jika lease diambil alih instance lain, export ini dihentikan.
+func runExport(export models.DataExport) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(exportHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				result := leasedExport(export).Update("lease_expires_at", time.Now().Add(exportLease))
+				if result.Error == nil && result.RowsAffected == 0 {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	size, readings, err := writeExportFile(ctx, export)
+	if err != nil {
+		log.Printf("Export %d failed: %v", export.ID, err)
+		database.DB.Where("export_id = ? AND attempt = ?", export.ID, export.Attempts).Delete(&models.DataExportChunk{})
+		if !errors.Is(err, errExportLeaseLost) {
+			leasedExport(export).Updates(map[string]interface{}{"status": models.ExportFailed, "error": "Export failed, please try again", "lease_expires_at": nil})
+		}
+		return
+	}
+
+	now := time.Now()
+	result := leasedExport(export).Updates(map[string]interface{}{
+		"status":           models.ExportCompleted,
+		"size_bytes":       size,
+		"readings":         readings,
+		"completed_at":     now,
+		"expires_at":       now.Add(exportFileTTL),
+		"lease_expires_at": nil,
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		log.Printf("Export %d lost its lease before completing", export.ID)
+		database.DB.Where("export_id = ? AND attempt = ?", export.ID, export.Attempts).Delete(&models.DataExportChunk{})
+		return
+	}
+	// Potongan dari percobaan sebelumnya yang terhenti
+	database.DB.Where("export_id = ? AND attempt <> ?", export.ID, export.Attempts).Delete(&models.DataExportChunk{})
+}
+
+// exportChunkWriter - Menyimpan file export ke tabel data_export_chunks per exportChunkSize byte
+type exportChunkWriter struct {
+	ctx    context.Context
+	export models.DataExport
+	buf    []byte
+	seq    int
+	size   int64
+}
+
+func (w *exportChunkWriter) Write(data []byte) (int, error) {
+	written := len(data)
+	for len(data) > 0 {
+		n := min(exportChunkSize-len(w.buf), len(data))
+		w.buf = append(w.buf, data[:n]...)
+		data = data[n:]
+		if len(w.buf) == exportChunkSize {
+			if err := w.Flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Flush - Menyimpan sisa data yang belum disimpan sebagai satu potongan
+func (w *exportChunkWriter) Flush() error {
+	if w.ctx.Err() != nil {
+		return errExportLeaseLost
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	chunk := models.DataExportChunk{ExportID: w.export.ID, Attempt: w.export.Attempts, Seq: w.seq, Data: w.buf}
+	if err := database.DB.WithContext(w.ctx).Create(&chunk).Error; err != nil {
+		return err
+	}
+	w.seq++
+	w.size += int64(len(w.buf))
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// writeExportFile - Menulis ZIP berisi profile.json, devices.json, devices.csv, sensor_data.csv dan
+// ringkasan data sensor (sensor_rollup_hourly.csv, sensor_rollup_daily.csv) untuk periode yang data
+// mentahnya sudah dihapus retensi. Data sensor dibaca baris per baris dan langsung ditulis ke ZIP
+// sehingga tidak dimuat ke memori.
+func writeExportFile(ctx context.Context, export models.DataExport) (size, readings int64, err error) {
+	// Sisa potongan jika percobaan ini pernah dimulai
+	if err = database.DB.Where("export_id = ? AND attempt = ?", export.ID, export.Attempts).Delete(&models.DataExportChunk{}).Error; err != nil {
+		return 0, 0, err
+	}
+
+	file := &exportChunkWriter{ctx: ctx, export: export, buf: make([]byte, 0, exportChunkSize)}
+	archive := zip.NewWriter(file)
+	db := database.DB.WithContext(ctx)
+
+	var user models.User
+	if err = db.First(&user, export.UserID).Error; err != nil {
+		return 0, 0, err
+	}
+	if err = writeZipJSON(archive, "profile.json", user); err != nil {
+		return 0, 0, err
+	}
+
+	var devices []models.Device
+	if err = db.Where("user_id = ?", export.UserID).Order("id").Find(&devices).Error; err != nil {
+		return 0, 0, err
+	}
+	if err = writeZipJSON(archive, "devices.json", devices); err != nil {
+		return 0, 0, err
+	}
+	if err = writeDevicesCSV(archive, devices); err != nil {
+		return 0, 0, err
+	}
+
+	if readings, err = writeSensorCSV(db, archive, devices); err != nil {
+		return 0, 0, err
+	}
+	for _, rollup := range rollupTables {
+		if err = writeRollupCSV(db, archive, rollup.Table, devices); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err = file.Flush(); err != nil {
+		return 0, 0, err
+	}
+	return file.size, readings, nil
+}
+
+// writeZipJSON - Menulis satu file JSON ke dalam ZIP
+func writeZipJSON(archive *zip.Writer, name string, value interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeDevicesCSV - Menulis daftar device sebagai CSV
+func writeDevicesCSV(archive *zip.Writer, devices []models.Device) error {
+	w, err := archive.Create("devices.csv")
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "name", "api_key_prefix", "delay", "current_state", "connectivity", "last_seen_at", "created_at"})
+	for _, d := range devices {
+		out.Write([]string{
+			strconv.FormatUint(uint64(d.ID), 10), d.Name, d.APIKeyPrefix, strconv.Itoa(d.Delay),
+			d.CurrentState, d.Connectivity, formatOptionalTime(d.LastSeenAt), d.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// writeSensorCSV - Menulis riwayat data sensor mentah milik device user sebagai CSV (streaming)
+func writeSensorCSV(db *gorm.DB, archive *zip.Writer, devices []models.Device) (int64, error) {
+	w, err := archive.Create("sensor_data.csv")
+	if err != nil {
+		return 0, err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"device_id", "timestamp", "bpm", "spo2", "temp", "seq", "reading_id"})
+
+	var count int64
+	for _, device := range devices {
+		written, err := writeDeviceSensorRows(db, out, device)
+		count += written
+		if err != nil {
+			return count, err
+		}
+	}
+
+	out.Flush()
+	return count, out.Error()
+}
+
+// writeDeviceSensorRows - Menulis data sensor mentah satu device. Sebelum raw_retained_from hanya sisa data
+// mentah dari partisi yang belum dihapus, periode itu sudah ada di ringkasan (sama seperti SensorSeries).
+func writeDeviceSensorRows(db *gorm.DB, out *csv.Writer, device models.Device) (int64, error) {
+	query := db.Model(&models.SensorData{}).Where("device_id = ?", device.ID)
+	if device.RawRetainedFrom != nil {
+		query = query.Where("timestamp >= ?", *device.RawRetainedFrom)
+	}
+	rows, err := query.Order("timestamp, id").Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var reading models.SensorData
+		if err := db.ScanRows(rows, &reading); err != nil {
+			return count, err
+		}
+
+		seq, readingID := "", ""
+		if reading.Seq != nil {
+			seq = strconv.FormatInt(*reading.Seq, 10)
+		}
+		if reading.ReadingID != nil {
+			readingID = *reading.ReadingID
+		}
+		out.Write([]string{
+			strconv.FormatUint(uint64(reading.DeviceID), 10), reading.Timestamp.Format(time.RFC3339Nano),
+			formatFloat(reading.BPM), formatFloat(reading.SpO2), formatFloat(reading.Temp), seq, readingID,
+		})
+		count++
+	}
+	return count, rows.Err()
+}
+
+// writeRollupCSV - Menulis ringkasan data sensor milik device user dari tabel rollup sebagai CSV (streaming)
+func writeRollupCSV(db *gorm.DB, archive *zip.Writer, table string, devices []models.Device) error {
+	w, err := archive.Create(table + ".csv")
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"device_id", "bucket_start", "count",
+		"bpm_min", "bpm_max", "bpm_mean", "bpm_median",
+		"spo2_min", "spo2_max", "spo2_mean", "spo2_median",
+		"temp_min", "temp_max", "temp_mean", "temp_median"})
+
+	for _, device := range devices {
+		if err := writeDeviceRollupRows(db, out, table, device); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// writeDeviceRollupRows - Menulis ringkasan satu device hanya untuk periode yang tidak dicakup data yang lebih
+// rinci: per jam sebelum raw_retained_from, per hari sebelum hourly_retained_from (sama seperti SensorSeries)
+func writeDeviceRollupRows(db *gorm.DB, out *csv.Writer, table string, device models.Device) error {
+	query := db.Table(table).Where("device_id = ?", device.ID)
+	switch {
+	case table == rollupTables[0].Table && device.RawRetainedFrom != nil:
+		query = query.Where("bucket_start < ?", *device.RawRetainedFrom)
+		if device.HourlyRetainedFrom != nil {
+			query = query.Where("bucket_start >= ?", *device.HourlyRetainedFrom)
+		}
+	case table == rollupTables[1].Table && device.HourlyRetainedFrom != nil:
+		query = query.Where("bucket_start < ?", *device.HourlyRetainedFrom)
+	default:
+		return nil // Data mentah / ringkasan per jam masih lengkap
+	}
+
+	rows, err := query.Order("bucket_start").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.SensorRollup
+		if err := db.ScanRows(rows, &r); err != nil {
+			return err
+		}
+		count := float64(r.Count)
+		out.Write([]string{
+			strconv.FormatUint(uint64(r.DeviceID), 10), r.BucketStart.Format(time.RFC3339), strconv.FormatInt(r.Count, 10),
+			formatFloat(r.BPMMin), formatFloat(r.BPMMax), formatFloat(r.BPMSum / count), formatOptionalFloat(r.BPMMedian),
+			formatFloat(r.SpO2Min), formatFloat(r.SpO2Max), formatFloat(r.SpO2Sum / count), formatOptionalFloat(r.SpO2Median),
+			formatFloat(r.TempMin), formatFloat(r.TempMax), formatFloat(r.TempSum / count), formatOptionalFloat(r.TempMedian),
+		})
+	}
+	return rows.Err()
+}
+
+// formatFloat - Format angka untuk CSV
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatOptionalFloat - Format angka opsional untuk CSV (kosong jika nil)
+func formatOptionalFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return formatFloat(*value)
+}
+
+// formatOptionalTime - Format waktu opsional untuk CSV (kosong jika nil)
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ExportDownloadURL - Link download sementara (berlaku exportLinkTTL) untuk export yang sudah selesai
+func ExportDownloadURL(export models.DataExport) (string, time.Time, error) {
+	expiresAt := time.Now().Add(exportLinkTTL)
+	if export.ExpiresAt != nil && export.ExpiresAt.Before(expiresAt) {
+		expiresAt = *export.ExpiresAt
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":   exportPurpose,
+		"export_id": export.ID,
+		"user_id":   export.UserID,
+		"exp":       expiresAt.Unix(),
+	})
+	signed, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fmt.Sprintf("%s/exports/download?token=%s", AppBaseURL(), url.QueryEscape(signed)), expiresAt, nil
+}
+
+// ExportForDownload - Memvalidasi token link download dan mengembalikan export yang siap diunduh
+func ExportForDownload(tokenString string) (*models.DataExport, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidExportURL
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidExportURL
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != exportPurpose {
+		return nil, ErrInvalidExportURL
+	}
+	exportID, okID := claims["export_id"].(float64)
+	userID, okUser := claims["user_id"].(float64)
+	if !okID || !okUser {
+		return nil, ErrInvalidExportURL
+	}
+
+	// Link dari akun yang sudah dihapus tidak berlaku lagi
+	var export models.DataExport
+	err = database.DB.Joins("JOIN users ON users.id = data_exports.user_id AND users.deleted_at IS NULL").
+		Where("data_exports.id = ? AND data_exports.user_id = ?", uint(exportID), uint(userID)).First(&export).Error
+	if err != nil {
+		return nil, ErrInvalidExportURL
+	}
+	if export.Status != models.ExportCompleted || (export.ExpiresAt != nil && export.ExpiresAt.Before(time.Now())) {
+		return nil, ErrExportNotReady
+	}
+	return &export, nil
+}
+
+// WriteExportTo - Mengirim isi file export potongan per potongan sehingga tidak dimuat seluruhnya ke memori
+func WriteExportTo(w io.Writer, export models.DataExport) error {
+	for seq := 0; ; seq++ {
+		var chunk models.DataExportChunk
+		err := database.DB.Where("export_id = ? AND attempt = ? AND seq = ?", export.ID, export.Attempts, seq).
+			Limit(1).Find(&chunk).Error
+		if err != nil {
+			return err
+		}
+		if chunk.Data == nil {
+			return nil
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+}
+
+// StartExportWorker - Mengerjakan export yang menunggu (termasuk milik instance yang berhenti di tengah
+// jalan) secara berkala, lalu menghapus file export yang sudah kedaluwarsa
+func StartExportWorker() {
+	go func() {
+		ticker := time.NewTicker(exportPollInterval)
+		defer ticker.Stop()
+		lastCleanup := time.Time{}
+		for {
+			processExports()
+			if time.Since(lastCleanup) >= exportJanitorPeriod {
+				removeExpiredExports()
+				lastCleanup = time.Now()
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// removeExpiredExports - Menghapus file export yang masa berlakunya sudah habis
+func removeExpiredExports() {
+	var exports []models.DataExport
+	if err := database.DB.Where("status = ? AND expires_at < ?", models.ExportCompleted, time.Now()).Find(&exports).Error; err != nil {
+		log.Println("Failed to load expired exports:", err)
+		return
+	}
+
+	for _, export := range exports {
+		if err := database.DB.Where("export_id = ?", export.ID).Delete(&models.DataExportChunk{}).Error; err != nil {
+			log.Printf("Failed to remove export file %d: %v", export.ID, err)
+			continue
+		}
+		database.DB.Model(&export).Update("status", models.ExportExpired)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestRequestExportRejectsSecondInProgress(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "export-busy")
+
+	if err := db.Create(&models.DataExport{UserID: device.UserID, Status: models.ExportRunning}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RequestExport(device.UserID); !errors.Is(err, ErrExportInProgress) {
+		t.Fatalf("expected ErrExportInProgress, got %v", err)
+	}
+}
+
+func TestExportIsStoredInDatabaseWithRollups(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "export")
+	storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: time.Now()})
+	if err := db.Create(&models.SensorRollupDaily{SensorRollup: models.SensorRollup{DeviceID: device.ID, BucketStart: startOfDayUTC(time.Now().AddDate(0, 0, -60)), Count: 2, BPMSum: 140}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.DataExport{UserID: device.UserID, Status: models.ExportPending}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := claimExport()
+	if err != nil || claimed == nil || claimed.LeaseOwner != exportInstanceID {
+		t.Fatalf("expected export claimed by this instance, got %+v (err %v)", claimed, err)
+	}
+	runExport(*claimed)
+
+	var export models.DataExport
+	db.First(&export, claimed.ID)
+	if export.Status != models.ExportCompleted || export.Readings != 1 {
+		t.Fatalf("expected completed export with 1 reading, got %+v", export)
+	}
+
+	var file bytes.Buffer
+	if err := WriteExportTo(&file, export); err != nil {
+		t.Fatal(err)
+	}
+	if int64(file.Len()) != export.SizeBytes {
+		t.Fatalf("expected %d bytes, got %d", export.SizeBytes, file.Len())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(file.Bytes()), int64(file.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, f := range archive.File {
+		names[f.Name] = true
+	}
+	for _, name := range []string{"profile.json", "sensor_data.csv", "sensor_rollup_hourly.csv", "sensor_rollup_daily.csv"} {
+		if !names[name] {
+			t.Errorf("export is missing %s", name)
+		}
+	}
+}
+
+func TestExportLeaseTakenOverAfterExpiry(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "export-lease")
+	if err := db.Create(&models.DataExport{UserID: device.UserID, Status: models.ExportPending}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := claimExport()
+	if err != nil || first == nil {
+		t.Fatalf("expected a claimed export, got err %v", err)
+	}
+	if again, _ := claimExport(); again != nil {
+		t.Fatal("export with an active lease must not be claimed twice")
+	}
+
+	// Instance pemegang lease berhenti: setelah lease habis export diambil alih
+	db.Model(&models.DataExport{}).Where("id = ?", first.ID).Update("lease_expires_at", time.Now().Add(-time.Minute))
+	second, err := claimExport()
+	if err != nil || second == nil || second.Attempts != first.Attempts+1 {
+		t.Fatalf("expected export to be reclaimed, got %+v (err %v)", second, err)
+	}
+	if result := leasedExport(*first).Update("lease_expires_at", time.Now()); result.RowsAffected != 0 {
+		t.Fatal("previous attempt must no longer hold the lease")
+	}
+}
+
+func TestExportDoesNotRepeatPeriodsCoveredByRollups(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "export-overlap")
+
+	rawFrom := startOfDayUTC(time.Now().AddDate(0, 0, -7))
+	hourlyFrom := startOfDayUTC(time.Now().AddDate(0, 0, -30))
+	db.Model(&device).UpdateColumns(map[string]interface{}{"raw_retained_from": rawFrom, "hourly_retained_from": hourlyFrom})
+	device.RawRetainedFrom, device.HourlyRetainedFrom = &rawFrom, &hourlyFrom
+
+	// Sisa data mentah di partisi lama yang periodenya sudah diringkas
+	for _, reading := range []models.SensorData{
+		{DeviceID: device.ID, BPM: 70, Timestamp: rawFrom.Add(-time.Hour)},
+		{DeviceID: device.ID, BPM: 72, Timestamp: rawFrom.Add(time.Hour)},
+	} {
+		if err := db.Create(&reading).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rollup := range []models.SensorRollup{
+		{DeviceID: device.ID, BucketStart: rawFrom.Add(-time.Hour), Count: 1, BPMSum: 70},   // Sebelum raw_retained_from
+		{DeviceID: device.ID, BucketStart: rawFrom.Add(time.Hour), Count: 1, BPMSum: 72},    // Sudah ada di data mentah
+		{DeviceID: device.ID, BucketStart: hourlyFrom.Add(-time.Hour), Count: 1, BPMSum: 1}, // Sudah di-prune, dari per hari
+	} {
+		if err := db.Create(&models.SensorRollupHourly{SensorRollup: rollup}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, day := range []time.Time{hourlyFrom.AddDate(0, 0, -1), rawFrom.AddDate(0, 0, -1)} {
+		if err := db.Create(&models.SensorRollupDaily{SensorRollup: models.SensorRollup{DeviceID: device.ID, BucketStart: day, Count: 1, BPMSum: 70}}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var file bytes.Buffer
+	archive := zip.NewWriter(&file)
+	readings, err := writeSensorCSV(db, archive, []models.Device{device})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rollup := range rollupTables {
+		if err := writeRollupCSV(db, archive, rollup.Table, []models.Device{device}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if readings != 1 {
+		t.Fatalf("expected only the raw reading after raw_retained_from, got %d", readings)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(file.Bytes()), int64(file.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := map[string]int{}
+	for _, f := range reader.File {
+		content, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(content).ReadAll()
+		content.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[f.Name] = len(records) - 1 // Tanpa header
+	}
+	if lines["sensor_rollup_hourly.csv"] != 1 || lines["sensor_rollup_daily.csv"] != 1 {
+		t.Fatalf("expected one hourly and one daily row, got %v", lines)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Error akses bersama
+var (
+	ErrGrantNotFound     = errors.New("access grant not found")
+	ErrGrantExists       = errors.New("access grant already exists for this email")
+	ErrGrantSelf         = errors.New("cannot share access with yourself")
+	ErrGrantNotAllowed   = errors.New("role cannot receive shared access")
+	ErrGrantUnverified   = errors.New("email must be verified to accept access")
+	ErrGrantInvalidScope = errors.New("invalid scope")
+)
+
+// Urutan cakupan akses, cakupan yang lebih tinggi mencakup yang lebih rendah
+var grantScopeRank = map[string]int{
+	models.GrantScopeViewVitals:    1,
+	models.GrantScopeViewAlerts:    2,
+	models.GrantScopeManageDevices: 3,
+	models.GrantScopeEditMedical:   4,
+}
+
+// ValidGrantScope - Mengecek apakah scope dikenal
+func ValidGrantScope(scope string) bool {
+	_, ok := grantScopeRank[scope]
+	return ok
+}
+
+// scopesAtLeast - Daftar scope yang mencakup scope minimum
+func scopesAtLeast(scope string) []string {
+	var scopes []string
+	for s, rank := range grantScopeRank {
+		if rank >= grantScopeRank[scope] {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// activeGrants - Query akses yang aktif dan belum kedaluwarsa dengan cakupan minimal scope
+func activeGrants(tx *gorm.DB, scope string) *gorm.DB {
+	return tx.Model(&models.AccessGrant{}).
+		Where("status = ? AND (expires_at IS NULL OR expires_at > ?) AND scope IN ?", models.GrantActive, time.Now(), scopesAtLeast(scope)).
+		Where("patient_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id")) // Pasien yang akunnya dihapus tidak dibagikan lagi
+}
+
+// HasActiveGrant - Mengecek apakah grantee memiliki akses aktif ke data pasien dengan cakupan minimal scope
+func HasActiveGrant(patientID, granteeID uint, scope string) bool {
+	var count int64
+	err := activeGrants(database.DB, scope).Where("patient_id = ? AND grantee_id = ?", patientID, granteeID).Count(&count).Error
+	return err == nil && count > 0
+}
+
+// SharedPatientIDs - Daftar pasien yang membagikan akses ke grantee dengan cakupan minimal scope
+func SharedPatientIDs(granteeID uint, scope string) ([]uint, error) {
+	var ids []uint
+	err := activeGrants(database.DB, scope).Where("grantee_id = ?", granteeID).Distinct().Pluck("patient_id", &ids).Error
+	return ids, err
+}
+
+// alertGrantees - Grantee yang ikut menerima notifikasi alert pasien
+func alertGrantees(tx *gorm.DB, patientID uint) ([]uint, error) {
+	var ids []uint
+	err := activeGrants(tx, models.GrantScopeViewAlerts).Where("patient_id = ? AND grantee_id IS NOT NULL", patientID).
+		Distinct().Pluck("grantee_id", &ids).Error
+	return ids, err
+}
+
+// InviteGrantee - Pasien mengundang user lain lewat email untuk mengakses datanya
+func InviteGrantee(patient models.User, email, scope string, expiresAt *time.Time) (*models.AccessGrant, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !ValidGrantScope(scope) {
+		return nil, ErrGrantInvalidScope
+	}
+	if strings.EqualFold(email, patient.Email) {
+		return nil, ErrGrantSelf
+	}
+
+	grant := models.AccessGrant{
+		PatientID:    patient.ID,
+		GranteeEmail: email,
+		Scope:        scope,
+		Status:       models.GrantPending,
+		ExpiresAt:    expiresAt,
+	}
+	// Undangan yang masih pending / aktif untuk email yang sama dicegah unique index parsial,
+	// sehingga dua request bersamaan tidak bisa membuat dua undangan
+	result := database.DB.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "patient_id"}, {Name: "grantee_email"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN ('pending', 'active')"}}}, // Harus literal agar cocok dengan index parsial
+		DoNothing:   true,
+	}).Create(&grant)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrGrantExists
+	}
+
+	name := patient.Username
+	if patient.FullName != nil && *patient.FullName != "" {
+		name = *patient.FullName
+	}
+	body := fmt.Sprintf("%s has invited you to access their health monitoring data (%s).\n\n"+
+		"Sign in and open %s/api/grants/received to accept the invitation.", name, scope, AppBaseURL())
+	go func() {
+		if err := SendMail(email, "You have been invited to view health data", body); err != nil {
+			log.Println("Failed to send access invitation:", err)
+		}
+	}()
+
+	return &grant, nil
+}
+
+// AcceptGrant - Grantee menerima undangan yang dikirim ke email-nya
+func AcceptGrant(grantID uint, grantee models.User, role string) (*models.AccessGrant, error) {
+	if !HasPermission(role, models.PermReadSharedVitals) {
+		return nil, ErrGrantNotAllowed
+	}
+	// Email harus terverifikasi agar undangan tidak bisa diambil akun lain yang memakai email yang sama
+	if !grantee.EmailVerified {
+		return nil, ErrGrantUnverified
+	}
+
+	var grant models.AccessGrant
+	err := database.DB.Where("id = ? AND grantee_email = ? AND status = ?", grantID, strings.ToLower(grantee.Email), models.GrantPending).
+		First(&grant).Error
+	if err != nil {
+		return nil, ErrGrantNotFound
+	}
+
+	now := time.Now()
+	grant.GranteeID = &grantee.ID
+	grant.Status = models.GrantActive
+	grant.AcceptedAt = &now
+	if err := database.DB.Save(&grant).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+// RevokeGrant - Mencabut akses. Bisa dilakukan pasien pemberi akses maupun grantee sendiri.
+// Stream data sensor grantee yang masih terbuka ke device pasien langsung ditutup.
+func RevokeGrant(grantID, userID uint, email string) error {
+	now := time.Now()
+	var grant models.AccessGrant
+	result := database.DB.Model(&grant).Clauses(clause.Returning{}).
+		Where("id = ? AND status IN ? AND (patient_id = ? OR grantee_id = ? OR grantee_email = ?)",
+			grantID, []string{models.GrantPending, models.GrantActive}, userID, userID, strings.ToLower(email)).
+		Updates(map[string]interface{}{"status": models.GrantRevoked, "revoked_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGrantNotFound
+	}
+	if grant.GranteeID != nil {
+		SensorHub.CloseViewer(grant.PatientID, *grant.GranteeID)
+	}
+	return nil
+}
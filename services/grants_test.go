@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestInviteGranteeRejectsDuplicateOpenInvite(t *testing.T) {
+	db := testdb.Open(t)
+	patient := models.User{Username: "grant-patient", Password: "x", Email: "grant-patient@example.com"}
+	if err := db.Create(&patient).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := InviteGrantee(patient, "Family@example.com", models.GrantScopeViewVitals, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InviteGrantee(patient, "family@example.com", models.GrantScopeViewAlerts, nil); !errors.Is(err, ErrGrantExists) {
+		t.Fatalf("expected ErrGrantExists, got %v", err)
+	}
+
+	// Setelah dicabut, email yang sama boleh diundang lagi
+	if err := RevokeGrant(first.ID, patient.ID, patient.Email); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InviteGrantee(patient, "family@example.com", models.GrantScopeViewVitals, nil); err != nil {
+		t.Fatalf("expected new invite after revoke, got %v", err)
+	}
+}
+
+func TestRevokeGrantClosesGranteeStreams(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "grant-stream")
+	grantee := models.User{Username: "grant-viewer", Password: "x", Email: "grant-viewer@example.com"}
+	if err := db.Create(&grantee).Error; err != nil {
+		t.Fatal(err)
+	}
+	grant := models.AccessGrant{PatientID: device.UserID, GranteeEmail: grantee.Email, GranteeID: &grantee.ID,
+		Scope: models.GrantScopeViewVitals, Status: models.GrantActive}
+	if err := db.Create(&grant).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sub := SensorHub.Subscribe(device.ID, device.UserID, grantee.ID)
+	defer SensorHub.Unsubscribe(sub)
+	if err := RevokeGrant(grant.ID, device.UserID, ""); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.Done():
+	default:
+		t.Fatal("expected grantee stream to be closed after revoke")
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Pengaturan deteksi device offline
+const (
+	heartbeatCheckInterval = 30 * time.Second
+	defaultOfflineFactor   = 3  // Offline jika tidak ada data selama N x Device.Delay
+	minOfflineSeconds      = 60 // Batas minimum agar delay kecil tidak langsung dianggap offline
+	defaultHeartbeatWrite  = 30 // last_seen_at hanya ditulis ulang jika sudah lebih lama dari N detik
+)
+
+// offlineFactor - Membaca DEVICE_OFFLINE_FACTOR dari environment
+func offlineFactor() int {
+	factor, err := strconv.Atoi(os.Getenv("DEVICE_OFFLINE_FACTOR"))
+	if err != nil || factor < 1 {
+		return defaultOfflineFactor
+	}
+	return factor
+}
+
+// heartbeatWriteInterval - Membaca DEVICE_HEARTBEAT_WRITE_SECONDS dari environment. Dibatasi setengah dari
+// batas offline minimum agar device yang aktif tidak sempat dianggap offline.
+func heartbeatWriteInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("DEVICE_HEARTBEAT_WRITE_SECONDS"))
+	if err != nil || seconds < 0 {
+		seconds = defaultHeartbeatWrite
+	}
+	return time.Duration(min(seconds, minOfflineSeconds/2)) * time.Second
+}
+
+// heartbeatDue - Menentukan apakah heartbeat perlu ditulis: device belum online, last_seen_at sudah lebih lama
+// dari interval, IP berubah atau perangkat restart (uptime mengecil)
+func heartbeatDue(device *models.Device, ip string, uptime *int64, now time.Time, interval time.Duration) bool {
+	if device.Connectivity != models.DeviceOnline || device.LastSeenAt == nil {
+		return true
+	}
+	if ip != "" && ip != device.LastIP {
+		return true
+	}
+	if uptime != nil && (device.Uptime == nil || *uptime < *device.Uptime) {
+		return true
+	}
+	return !device.LastSeenAt.After(now.Add(-interval))
+}
+
+// TouchDevice - Mencatat heartbeat device (last_seen_at, last_ip, uptime) pada request ber-API Key.
+// Selama device online, last_seen_at paling sering ditulis sekali per heartbeatWriteInterval.
+// Jika device sebelumnya offline, statusnya kembali online dan alert offline di-resolve.
+func TouchDevice(device *models.Device, ip string, uptime *int64) {
+	now := time.Now()
+	interval := heartbeatWriteInterval()
+	if !heartbeatDue(device, ip, uptime, now, interval) {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"last_seen_at": now,
+		"connectivity": models.DeviceOnline,
+	}
+	if ip != "" {
+		updates["last_ip"] = ip
+	}
+	if uptime != nil {
+		updates["uptime"] = *uptime
+	}
+
+	// UpdateColumns agar updated_at device tidak berubah setiap heartbeat
+	if err := database.DB.Model(&models.Device{}).Where("id = ?", device.ID).UpdateColumns(updates).Error; err != nil {
+		log.Println("Failed to record device heartbeat:", err)
+		return
+	}
+
+	// Uptime lebih kecil dari sebelumnya berarti perangkat baru saja restart
+	if uptime != nil && device.Uptime != nil && *uptime < *device.Uptime {
+		recordStatusEvent(database.DB, device.ID, models.DeviceReboot, uptime, now)
+	}
+
+	if device.Connectivity != models.DeviceOnline {
+		recordStatusEvent(database.DB, device.ID, models.DeviceOnline, uptime, now)
+		resolveOfflineAlerts(device.ID, now)
+	}
+}
+
+// recordStatusEvent - Menyimpan riwayat perubahan status device
+func recordStatusEvent(tx *gorm.DB, deviceID uint, status string, uptime *int64, at time.Time) {
+	event := models.DeviceStatusEvent{DeviceID: deviceID, Status: status, Uptime: uptime, OccurredAt: at}
+	if err := tx.Create(&event).Error; err != nil {
+		log.Println("Failed to record device status event:", err)
+	}
+}
+
+// resolveOfflineAlerts - Menutup alert offline yang masih aktif untuk device
+func resolveOfflineAlerts(deviceID uint, now time.Time) {
+	err := database.DB.Model(&models.Alert{}).
+		Where("device_id = ? AND type = ? AND status IN ?", deviceID, models.AlertTypeOffline,
+			[]string{models.AlertStatusOpen, models.AlertStatusAcknowledged}).
+		Updates(map[string]interface{}{"status": models.AlertStatusResolved, "resolved_at": now}).Error
+	if err != nil {
+		log.Println("Failed to resolve offline alerts:", err)
+	}
+}
+
+// StartHeartbeatChecker - Menjalankan pengecekan berkala untuk device yang berhenti mengirim data
+func StartHeartbeatChecker() {
+	go func() {
+		ticker := time.NewTicker(heartbeatCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkOfflineDevices()
+		}
+	}()
+}
+
+// checkOfflineDevices - Menandai device offline jika tidak ada heartbeat selama N x Delay detik.
+// Device milik akun yang sudah dihapus dilewati agar tidak ada alert dan notifikasi untuk akun tersebut.
+func checkOfflineDevices() {
+	var devices []models.Device
+	err := database.DB.Where("connectivity = ? AND last_seen_at < NOW() - make_interval(secs => GREATEST(? * delay, ?))",
+		models.DeviceOnline, offlineFactor(), minOfflineSeconds).
+		Where("user_id IN (?)", database.DB.Model(&models.User{}).Select("id")).Find(&devices).Error
+	if err != nil {
+		log.Println("Heartbeat check failed:", err)
+		return
+	}
+
+	for _, device := range devices {
+		if err := markDeviceOffline(device); err != nil {
+			log.Printf("Failed to mark device %d offline: %v", device.ID, err)
+		}
+	}
+}
+
+// markDeviceOffline - Mengubah status device menjadi offline dan membuat alert untuk pemiliknya
+func markDeviceOffline(device models.Device) error {
+	now := time.Now()
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Cek ulang kondisi agar tidak bentrok dengan heartbeat yang baru masuk
+		result := tx.Model(&models.Device{}).
+			Where("id = ? AND connectivity = ? AND last_seen_at = ?", device.ID, models.DeviceOnline, device.LastSeenAt).
+			Where("user_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&models.User{}).Select("id")).
+			UpdateColumn("connectivity", models.DeviceOffline)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		recordStatusEvent(tx, device.ID, models.DeviceOffline, device.Uptime, now)
+
+		alert := models.Alert{
+			UserID:      device.UserID,
+			DeviceID:    device.ID,
+			Type:        models.AlertTypeOffline,
+			Metric:      "connectivity",
+			Severity:    "warning",
+			Message:     fmt.Sprintf("Device %q has not reported since %s", device.Name, device.LastSeenAt.Format(time.RFC3339)),
+			Status:      models.AlertStatusOpen,
+			TriggeredAt: now,
+		}
+		if err := tx.Create(&alert).Error; err != nil {
+			return err
+		}
+		return EnqueueAlertNotifications(tx, alert)
+	})
+}
+
+// DeviceStatusHistory - Mengambil riwayat status terbaru (maksimal limit per device)
+func DeviceStatusHistory(deviceIDs []uint, limit int) (map[uint][]models.DeviceStatusEvent, error) {
+	history := make(map[uint][]models.DeviceStatusEvent)
+	if len(deviceIDs) == 0 {
+		return history, nil
+	}
+
+	var events []models.DeviceStatusEvent
+	err := database.DB.Raw(`
+		SELECT id, device_id, status, uptime, occurred_at FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY device_id ORDER BY occurred_at DESC, id DESC) AS rn
+			FROM device_status_events
+			WHERE device_id IN ?
+		) e
+		WHERE rn <= ?
+		ORDER BY device_id, occurred_at DESC, id DESC`, deviceIDs, limit).Scan(&events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		history[event.DeviceID] = append(history[event.DeviceID], event)
+	}
+	return history, nil
+}
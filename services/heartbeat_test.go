@@ -0,0 +1,70 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestHeartbeatDueThrottlesOnlineDevices(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-10 * time.Second)
+	stale := now.Add(-time.Minute)
+	uptime, rebooted := int64(500), int64(5)
+	online := func(lastSeen time.Time) *models.Device {
+		return &models.Device{Connectivity: models.DeviceOnline, LastSeenAt: &lastSeen, LastIP: "10.0.0.1", Uptime: &uptime}
+	}
+
+	tests := []struct {
+		name   string
+		device *models.Device
+		ip     string
+		uptime *int64
+		due    bool
+	}{
+		{"recent heartbeat", online(recent), "10.0.0.1", &uptime, false},
+		{"mqtt without ip", online(recent), "", nil, false},
+		{"stale heartbeat", online(stale), "10.0.0.1", &uptime, true},
+		{"ip changed", online(recent), "10.0.0.2", &uptime, true},
+		{"rebooted", online(recent), "10.0.0.1", &rebooted, true},
+		{"offline device", &models.Device{Connectivity: models.DeviceOffline, LastSeenAt: &recent}, "", nil, true},
+		{"never seen", &models.Device{Connectivity: "unknown"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		if due := heartbeatDue(tt.device, tt.ip, tt.uptime, now, 30*time.Second); due != tt.due {
+			t.Errorf("%s: expected due=%v, got %v", tt.name, tt.due, due)
+		}
+	}
+}
+
+func TestHeartbeatWriteIntervalStaysBelowOfflineThreshold(t *testing.T) {
+	t.Setenv("DEVICE_HEARTBEAT_WRITE_SECONDS", "600")
+	if interval := heartbeatWriteInterval(); interval != minOfflineSeconds/2*time.Second {
+		t.Fatalf("expected interval capped at %ds, got %v", minOfflineSeconds/2, interval)
+	}
+	t.Setenv("DEVICE_HEARTBEAT_WRITE_SECONDS", "")
+	if interval := heartbeatWriteInterval(); interval != defaultHeartbeatWrite*time.Second {
+		t.Fatalf("expected default interval, got %v", interval)
+	}
+}
+
+func TestCheckOfflineDevicesSkipsDeletedAccounts(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "offline-deleted")
+	lastSeen := time.Now().Add(-time.Hour)
+	db.Model(&device).UpdateColumns(map[string]interface{}{"connectivity": models.DeviceOnline, "last_seen_at": lastSeen})
+	if err := db.Delete(&models.User{}, device.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	checkOfflineDevices()
+
+	var alerts int64
+	db.Model(&models.Alert{}).Where("device_id = ?", device.ID).Count(&alerts)
+	db.First(&device, device.ID)
+	if alerts != 0 || device.Connectivity != models.DeviceOnline {
+		t.Fatalf("expected device of deleted account to be skipped, got %d alerts (%s)", alerts, device.Connectivity)
+	}
+}
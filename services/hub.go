@@ -0,0 +1,103 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"backend/models"
+)
+
+// Ukuran buffer default per subscriber sebelum data mulai dibuang
+const defaultSubscriberBuffer = 64
+
+// Subscriber - Penerima data sensor dari satu device
+type Subscriber struct {
+	deviceID  uint
+	ownerID   uint // Pemilik device (pasien)
+	viewerID  uint // User yang membuka stream
+	C         chan models.SensorData
+	done      chan struct{}
+	closeOnce sync.Once
+	dropped   atomic.Uint64
+}
+
+// Done - Ditutup jika akses viewer ke device dicabut, stream harus diakhiri
+func (s *Subscriber) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped - Jumlah data yang dibuang karena subscriber terlalu lambat
+func (s *Subscriber) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Hub - Pub/sub in-process untuk data sensor baru per device
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[*Subscriber]struct{}
+}
+
+// SensorHub - Hub global yang dipakai ingestion (HTTP & MQTT) dan endpoint streaming
+var SensorHub = NewHub()
+
+// NewHub - Membuat hub kosong
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[uint]map[*Subscriber]struct{})}
+}
+
+// Subscribe - Mendaftarkan subscriber baru untuk device milik ownerID yang dibuka viewerID
+func (h *Hub) Subscribe(deviceID, ownerID, viewerID uint) *Subscriber {
+	sub := &Subscriber{
+		deviceID: deviceID,
+		ownerID:  ownerID,
+		viewerID: viewerID,
+		C:        make(chan models.SensorData, defaultSubscriberBuffer),
+		done:     make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[deviceID] == nil {
+		h.subscribers[deviceID] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[deviceID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe - Menghapus subscriber, channel-nya tidak dipakai lagi setelah ini
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subscribers[sub.deviceID], sub)
+	if len(h.subscribers[sub.deviceID]) == 0 {
+		delete(h.subscribers, sub.deviceID)
+	}
+}
+
+// Publish - Mengirim data sensor ke semua subscriber device tersebut.
+// Tidak pernah memblokir: jika buffer subscriber penuh, data untuk subscriber itu dibuang.
+func (h *Hub) Publish(data models.SensorData) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subscribers[data.DeviceID] {
+		select {
+		case sub.C <- data:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// CloseViewer - Mengakhiri semua stream viewer ke device milik owner (misalnya akses bersama dicabut).
+// Subscriber tetap terdaftar sampai Unsubscribe dipanggil oleh stream-nya.
+func (h *Hub) CloseViewer(ownerID, viewerID uint) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			if sub.ownerID == ownerID && sub.viewerID == viewerID {
+				sub.closeOnce.Do(func() { close(sub.done) })
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"backend/models"
+)
+
+func TestHubCloseViewerEndsOnlyGranteeStreams(t *testing.T) {
+	hub := NewHub()
+	grantee := hub.Subscribe(1, 10, 20)
+	owner := hub.Subscribe(1, 10, 10)
+	otherPatient := hub.Subscribe(2, 11, 20)
+
+	hub.CloseViewer(10, 20)
+	hub.CloseViewer(10, 20) // Pencabutan ganda tidak boleh panic
+
+	select {
+	case <-grantee.Done():
+	default:
+		t.Fatal("expected grantee stream to be closed")
+	}
+	for name, sub := range map[string]*Subscriber{"owner": owner, "other patient": otherPatient} {
+		select {
+		case <-sub.Done():
+			t.Fatalf("%s stream must stay open", name)
+		default:
+		}
+	}
+
+	// Data tetap terkirim ke subscriber yang belum Unsubscribe
+	hub.Publish(models.SensorData{DeviceID: 1})
+	if len(owner.C) != 1 {
+		t.Fatal("expected owner to keep receiving data")
+	}
+	hub.Unsubscribe(grantee)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	database "backend/config"
+	"backend/models"
+)
+
+// ErrLegacyDataPending - Masih ada data lama yang harus dimigrasi sebelum server dijalankan
+var ErrLegacyDataPending = errors.New("legacy data has not been migrated")
+
+// LegacyDataStatus - Jumlah data lama yang belum dimigrasi oleh `go run ./cmd/migrate data`
+type LegacyDataStatus struct {
+	UnencryptedUsers   int64 // User dengan nilai plaintext / v1 di kolom terenkripsi
+	PlaintextAPIKeys   int64 // Device dengan API Key yang belum di-hash
+	LegacyRoles        int64 // User (dan kebijakan 2FA) dengan role lama
+	UnassignedPatients int64 // Pasien tanpa organisasi
+}
+
+// GetLegacyDataStatus - Menghitung data lama yang belum dimigrasi
+func GetLegacyDataStatus() (LegacyDataStatus, error) {
+	var status LegacyDataStatus
+	var err error
+	if status.UnencryptedUsers, err = countUnencryptedUsers(); err != nil {
+		return status, err
+	}
+	if err = database.DB.Model(&models.Device{}).Where(legacyAPIKeyCondition).Count(&status.PlaintextAPIKeys).Error; err != nil {
+		return status, err
+	}
+	if status.LegacyRoles, err = countLegacyRoles(); err != nil {
+		return status, err
+	}
+	err = database.DB.Unscoped().Model(&models.User{}).
+		Where("organization_id IS NULL AND role = ?", models.RolePatient).Count(&status.UnassignedPatients).Error
+	return status, err
+}
+
+// RequireLegacyDataMigrated - Error jika masih ada data plaintext atau role lama (dipakai saat server start).
+// Pasien tanpa organisasi tidak menahan start karena super-admin boleh mengeluarkan pasien dari organisasi.
+func RequireLegacyDataMigrated() error {
+	status, err := GetLegacyDataStatus()
+	if err != nil {
+		return fmt.Errorf("failed to check legacy data: %w", err)
+	}
+
+	var pending []string
+	if status.UnencryptedUsers > 0 {
+		pending = append(pending, fmt.Sprintf("%d users with unencrypted fields", status.UnencryptedUsers))
+	}
+	if status.PlaintextAPIKeys > 0 {
+		pending = append(pending, fmt.Sprintf("%d plaintext device API keys", status.PlaintextAPIKeys))
+	}
+	if status.LegacyRoles > 0 {
+		pending = append(pending, fmt.Sprintf("%d legacy roles", status.LegacyRoles))
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s, run `go run ./cmd/migrate data`", ErrLegacyDataPending, strings.Join(pending, ", "))
+}
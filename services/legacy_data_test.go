@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestRequireLegacyDataMigrated(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "legacy")
+
+	if err := db.Exec("UPDATE devices SET api_key = ? WHERE id = ?", "legacy-plaintext-key", device.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("UPDATE users SET role = ? WHERE id = ?", "admin", device.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := RequireLegacyDataMigrated(); !errors.Is(err, ErrLegacyDataPending) {
+		t.Fatalf("expected pending legacy data, got %v", err)
+	}
+
+	// Langkah yang dijalankan `go run ./cmd/migrate data`
+	if hashed, err := HashLegacyAPIKeys(); err != nil || hashed != 1 {
+		t.Fatalf("expected 1 hashed API key, got %d (err %v)", hashed, err)
+	}
+	if migrated, err := MigrateLegacyRoles(); err != nil || migrated != 1 {
+		t.Fatalf("expected 1 migrated role, got %d (err %v)", migrated, err)
+	}
+	if err := RequireLegacyDataMigrated(); err != nil {
+		t.Fatalf("expected no pending legacy data, got %v", err)
+	}
+
+	var user models.User
+	if err := db.First(&user, device.UserID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleSuperAdmin {
+		t.Fatalf("expected role %s, got %s", models.RoleSuperAdmin, user.Role)
+	}
+	if _, err := FindDeviceByAPIKey("legacy-plaintext-key"); err != nil {
+		t.Fatalf("expected hashed API key to still authenticate: %v", err)
+	}
+}
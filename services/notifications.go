@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/notifier"
+
+	"gorm.io/gorm"
+)
+
+// Pengaturan worker outbox
+const (
+	outboxPollInterval = 5 * time.Second
+	outboxBatchSize    = 20
+	outboxLease        = 2 * time.Minute // Pesan yang sedang dikirim tidak diambil worker lain selama lease
+	outboxMaxAttempts  = 8
+	outboxBaseBackoff  = 30 * time.Second
+	outboxMaxBackoff   = time.Hour
+	outboxSendTimeout  = 15 * time.Second
+)
+
+// Notifiers - Registry notifier yang dipakai worker outbox
+var Notifiers notifier.Registry
+
+// ParseClock - Membaca jam dalam format HH:MM menjadi menit sejak tengah malam
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// quietHoursEnd - Jika now berada dalam quiet hours, kembalikan waktu berakhirnya
+func quietHoursEnd(setting models.NotificationSetting, now time.Time) (time.Time, bool) {
+	if !setting.QuietHoursEnabled {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(setting.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	start, errStart := ParseClock(setting.QuietHoursStart)
+	end, errEnd := ParseClock(setting.QuietHoursEnd)
+	if errStart != nil || errEnd != nil || start == end {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	if start < end {
+		// Contoh 13:00-15:00 (dalam hari yang sama)
+		if minute >= start && minute < end {
+			return midnight.Add(time.Duration(end) * time.Minute), true
+		}
+		return time.Time{}, false
+	}
+
+	// Contoh 22:00-07:00 (melewati tengah malam)
+	if minute >= start {
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(end) * time.Minute), true
+	}
+	if minute < end {
+		return midnight.Add(time.Duration(end) * time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+// EnqueueAlertNotifications - Memasukkan notifikasi alert ke outbox untuk semua channel aktif milik pasien
+// dan grantee yang diberi akses menerima alert.
+// Dipanggil di dalam transaksi yang sama dengan pembuatan alert agar notifikasi tidak hilang.
+func EnqueueAlertNotifications(tx *gorm.DB, alert models.Alert) error {
+	grantees, err := alertGrantees(tx, alert.UserID)
+	if err != nil {
+		return err
+	}
+	recipients := append([]uint{alert.UserID}, grantees...)
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":        "alert.triggered",
+		"alert_id":     alert.ID,
+		"user_id":      alert.UserID,
+		"device_id":    alert.DeviceID,
+		"metric":       alert.Metric,
+		"value":        alert.Value,
+		"threshold":    alert.Threshold,
+		"severity":     alert.Severity,
+		"message":      alert.Message,
+		"triggered_at": alert.TriggeredAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] Vital sign alert: %s", alert.Severity, alert.Metric)
+	for _, recipientID := range recipients {
+		if err := enqueueForRecipient(tx, alert, recipientID, subject, string(payload)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueueForRecipient - Memasukkan notifikasi alert ke outbox untuk channel milik satu penerima
+func enqueueForRecipient(tx *gorm.DB, alert models.Alert, recipientID uint, subject, payload string) error {
+	// Alert tetap dicatat, tapi tidak dikirim ke akun yang sudah dihapus atau
+	// (sesuai kebijakan verifikasi email) ke user yang belum terverifikasi
+	recipient := tx.Model(&models.User{}).Where("id = ?", recipientID)
+	if RequireVerifiedEmail() {
+		recipient = recipient.Where("email_verified = ?", true)
+	}
+	var eligible int64
+	if err := recipient.Count(&eligible).Error; err != nil {
+		return err
+	}
+	if eligible == 0 {
+		return nil
+	}
+
+	var channels []models.NotificationChannel
+	if err := tx.Where("user_id = ? AND enabled = ?", recipientID, true).Find(&channels).Error; err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	// Saat quiet hours, notifikasi ditunda sampai quiet hours selesai (kecuali severity critical)
+	now := time.Now()
+	sendAt := now
+	var setting models.NotificationSetting
+	if err := tx.Where("user_id = ?", recipientID).First(&setting).Error; err == nil {
+		if end, quiet := quietHoursEnd(setting, now); quiet && !(alert.Severity == "critical" && setting.CriticalBypassesQH) {
+			sendAt = end
+		}
+	}
+
+	for _, channel := range channels {
+		channelID := channel.ID
+		entry := models.NotificationOutbox{
+			UserID:        recipientID,
+			AlertID:       &alert.ID,
+			ChannelID:     &channelID,
+			Type:          channel.Type,
+			Target:        channel.Target,
+			Secret:        channel.Secret,
+			Subject:       subject,
+			Body:          alert.Message,
+			Payload:       payload,
+			Status:        models.OutboxPending,
+			NextAttemptAt: sendAt,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartOutboxWorker - Menjalankan worker yang mengirim notifikasi dari outbox secara berkala
+func StartOutboxWorker() {
+	if Notifiers == nil {
+		Notifiers = notifier.FromEnv()
+	}
+
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			processOutbox()
+		}
+	}()
+}
+
+// claimOutbox - Mengambil pesan yang sudah jatuh tempo dan memasang lease agar tidak diambil instance lain
+func claimOutbox() ([]models.NotificationOutbox, error) {
+	var entries []models.NotificationOutbox
+	err := database.DB.Raw(`
+		UPDATE notification_outboxes
+		SET next_attempt_at = ?, attempts = attempts + 1, updated_at = ?
+		WHERE id IN (
+			SELECT id FROM notification_outboxes
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, time.Now().Add(outboxLease), time.Now(), models.OutboxPending, time.Now(), outboxBatchSize).
+		Scan(&entries).Error
+	return entries, err
+}
+
+// processOutbox - Mengirim satu batch pesan outbox
+func processOutbox() {
+	entries, err := claimOutbox()
+	if err != nil {
+		log.Println("Outbox claim failed:", err)
+		return
+	}
+
+	for _, entry := range entries {
+		sendErr := deliver(entry)
+		if sendErr == nil {
+			now := time.Now()
+			database.DB.Model(&entry).Updates(map[string]interface{}{
+				"status":     models.OutboxSent,
+				"sent_at":    &now,
+				"last_error": "",
+			})
+			continue
+		}
+
+		// Gagal: coba lagi dengan exponential backoff sampai batas percobaan
+		updates := map[string]interface{}{"last_error": sendErr.Error()}
+		if entry.Attempts >= outboxMaxAttempts {
+			updates["status"] = models.OutboxFailed
+		} else {
+			backoff := outboxBaseBackoff << (entry.Attempts - 1)
+			if backoff > outboxMaxBackoff || backoff <= 0 {
+				backoff = outboxMaxBackoff
+			}
+			updates["next_attempt_at"] = time.Now().Add(backoff)
+		}
+		database.DB.Model(&entry).Updates(updates)
+		log.Printf("Notification %d via %s failed (attempt %d): %v", entry.ID, entry.Type, entry.Attempts, sendErr)
+	}
+}
+
+// deliver - Mengirim satu pesan outbox lewat notifier yang sesuai
+func deliver(entry models.NotificationOutbox) error {
+	n, err := Notifiers.Get(entry.Type)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), outboxSendTimeout)
+	defer cancel()
+
+	return n.Send(ctx, notifier.Message{
+		Target:  entry.Target,
+		Secret:  entry.Secret,
+		Subject: entry.Subject,
+		Body:    entry.Body,
+		Payload: []byte(entry.Payload),
+	})
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Pengaturan reset password
+const (
+	passwordResetTTL      = time.Hour
+	passwordResetCooldown = time.Minute // Jeda minimum antar permintaan reset untuk user yang sama
+)
+
+// ErrInvalidResetToken - Token reset tidak ditemukan, sudah dipakai, atau kedaluwarsa
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// RequestPasswordReset - Memproses permintaan reset password di background. Pencarian user, pembuatan
+// token dan pengiriman email tidak ditunggu sehingga waktu respons sama untuk email terdaftar maupun tidak.
+func RequestPasswordReset(email string) {
+	go func() {
+		if err := sendPasswordReset(email); err != nil {
+			log.Println("Password reset request failed:", err)
+		}
+	}()
+}
+
+// PasswordResetURL - Halaman frontend untuk memilih password baru (PASSWORD_RESET_URL, default
+// APP_BASE_URL/reset-password). Halaman tersebut mengirim token dari query ?token= ke POST /password/reset.
+func PasswordResetURL() string {
+	if resetURL := os.Getenv("PASSWORD_RESET_URL"); resetURL != "" {
+		return resetURL
+	}
+	return AppBaseURL() + "/reset-password"
+}
+
+// sendPasswordReset - Membuat token reset dan mengirimkannya ke email user.
+// Tidak mengembalikan error jika email tidak terdaftar.
+func sendPasswordReset(email string) error {
+	var user models.User
+	if err := database.DB.Where("LOWER(email) = ?", strings.ToLower(strings.TrimSpace(email))).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Batasi frekuensi pengiriman email reset per user
+	var recent int64
+	database.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).Count(&recent)
+	if recent > 0 {
+		return nil
+	}
+
+	token := RandomToken(32)
+	entry := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	link, err := url.Parse(PasswordResetURL())
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_RESET_URL: %w", err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	body := fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new password:\n\n%s\n\n"+
+		"The link expires in %d minutes and can only be used once. If you did not request this, you can ignore this email.",
+		user.Username, link.String(), int(passwordResetTTL/time.Minute))
+	return SendMail(user.Email, "Reset your password", body)
+}
+
+// ResetPassword - Mengganti password dengan token reset (sekali pakai) lalu mencabut semua sesi user
+func ResetPassword(token, newPassword string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var entry models.PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", HashToken(token), now).
+			First(&entry).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		// Tandai token terpakai secara atomik agar tidak bisa dipakai dua kali bersamaan
+		result := tx.Model(&models.PasswordResetToken{}).Where("id = ? AND used_at IS NULL", entry.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		// Token reset lain yang belum dipakai ikut dinonaktifkan
+		if err := tx.Model(&models.PasswordResetToken{}).Where("user_id = ? AND used_at IS NULL", entry.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.User{}).Where("id = ?", entry.UserID).Update("password", string(hashedPassword)).Error; err != nil {
+			return err
+		}
+		return RevokeAllSessions(tx, entry.UserID)
+	})
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+)
+
+// RolePermissions - Daftar permission untuk setiap role
+var RolePermissions = map[string][]string{
+	models.RolePatient: {
+		models.PermReadOwnVitals,
+		models.PermManageOwnDevices,
+		models.PermEditOwnMedicalHistory,
+	},
+	models.RoleCaregiver: {
+		models.PermReadSharedVitals,
+		models.PermManageSharedDevices,
+	},
+	models.RoleClinician: {
+		models.PermReadSharedVitals,
+		models.PermManageSharedDevices,
+		models.PermEditSharedMedicalHistory,
+	},
+	models.RoleOrgAdmin: {
+		models.PermReadAllVitals,
+		models.PermManageAllDevices,
+		models.PermManageAllAlertRules,
+		models.PermManageUsers,
+		models.PermReadAuditLog,
+		models.PermManageRetention,
+	},
+	models.RoleSuperAdmin: {
+		models.PermReadOwnVitals,
+		models.PermManageOwnDevices,
+		models.PermEditOwnMedicalHistory,
+		models.PermReadAllVitals,
+		models.PermManageAllDevices,
+		models.PermEditAllMedicalHistory,
+		models.PermManageAllAlertRules,
+		models.PermManageUsers,
+		models.PermManageSecurity,
+		models.PermManageOrganizations,
+		models.PermReadAuditLog,
+		models.PermManageRetention,
+	},
+}
+
+// Role lama sebelum role model diterapkan
+var legacyRoles = map[string]string{
+	"user":  models.RolePatient,
+	"admin": models.RoleSuperAdmin,
+}
+
+// ValidRole - Mengecek apakah role dikenal
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission - Mengecek apakah role memiliki permission
+func HasPermission(role, permission string) bool {
+	for _, p := range RolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAssignRole - Mengecek apakah actor boleh memberikan role ke user lain.
+// Hanya super-admin yang boleh membuat super-admin lain.
+func CanAssignRole(actorRole, role string) bool {
+	if !ValidRole(role) {
+		return false
+	}
+	if role == models.RoleSuperAdmin {
+		return actorRole == models.RoleSuperAdmin
+	}
+	return HasPermission(actorRole, models.PermManageUsers)
+}
+
+// MigrateLegacyRoles - Mengubah role lama ("user", "admin") ke role baru, termasuk akun yang sedang
+// dihapus (masih bisa dipulihkan) dan kebijakan 2FA (dijalankan lewat `go run ./cmd/migrate data`)
+func MigrateLegacyRoles() (int64, error) {
+	var migrated int64
+	for legacy, role := range legacyRoles {
+		result := database.DB.Unscoped().Model(&models.User{}).Where("role = ?", legacy).Update("role", role)
+		if result.Error != nil {
+			return migrated, result.Error
+		}
+		migrated += result.RowsAffected
+	}
+
+	setting, roles, changed, err := legacy2FARoles()
+	if err != nil || !changed {
+		return migrated, err
+	}
+	return migrated, database.DB.Model(&setting).Update("value", strings.Join(roles, ",")).Error
+}
+
+// countLegacyRoles - Jumlah user dengan role lama, ditambah 1 jika kebijakan 2FA masih memakai role lama
+func countLegacyRoles() (int64, error) {
+	legacy := make([]string, 0, len(legacyRoles))
+	for role := range legacyRoles {
+		legacy = append(legacy, role)
+	}
+	var total int64
+	if err := database.DB.Unscoped().Model(&models.User{}).Where("role IN ?", legacy).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	_, _, changed, err := legacy2FARoles()
+	if changed {
+		total++
+	}
+	return total, err
+}
+
+// legacy2FARoles - Kebijakan 2FA dengan nama role lama sudah diganti ke role baru (changed false jika tidak ada)
+func legacy2FARoles() (setting models.SystemSetting, roles []string, changed bool, err error) {
+	err = database.DB.Where("key = ?", SettingRequire2FARoles).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return setting, nil, false, nil
+	}
+	if err != nil {
+		return setting, nil, false, err
+	}
+
+	roles = strings.Split(setting.Value, ",")
+	for i, r := range roles {
+		if mapped, ok := legacyRoles[strings.TrimSpace(r)]; ok {
+			roles[i] = mapped
+			changed = true
+		}
+	}
+	return setting, roles, changed, nil
+}
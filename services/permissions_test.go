@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+
+	"backend/models"
+)
+
+func TestAlertRuleManagementIsSeparateFromReadingVitals(t *testing.T) {
+	for role := range RolePermissions {
+		if HasPermission(role, models.PermManageAllAlertRules) && !HasPermission(role, models.PermReadAllVitals) {
+			t.Errorf("%s can manage alert rules without reading vitals", role)
+		}
+	}
+	if !HasPermission(models.RoleOrgAdmin, models.PermManageAllAlertRules) {
+		t.Error("org-admin must be able to manage alert rules of patients in the organization")
+	}
+	if HasPermission(models.RoleClinician, models.PermManageAllAlertRules) {
+		t.Error("clinician must not manage alert rules of every patient")
+	}
+}
+
+func TestSharedMedicalHistoryRequiresHighestGrantScope(t *testing.T) {
+	if !HasPermission(models.RoleClinician, models.PermEditSharedMedicalHistory) || HasPermission(models.RoleCaregiver, models.PermEditSharedMedicalHistory) {
+		t.Fatal("only clinicians may edit shared medical history")
+	}
+	for _, scope := range scopesAtLeast(models.GrantScopeEditMedical) {
+		if scope != models.GrantScopeEditMedical {
+			t.Errorf("scope %s must not allow editing medical history", scope)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Pengaturan retensi data sensor
+const (
+	defaultRawRetentionDays    = 90  // SENSOR_RAW_RETENTION_DAYS
+	defaultHourlyRetentionDays = 730 // SENSOR_HOURLY_RETENTION_DAYS (0 = selamanya)
+	defaultDailyRetentionDays  = 0   // SENSOR_DAILY_RETENTION_DAYS (0 = selamanya)
+	retentionInterval          = time.Hour
+	retentionBatchPause        = 100 * time.Millisecond // Jeda antar batch agar tidak membebani database
+	retentionDeviceBatch       = 100
+)
+
+// Sumber pengaturan retensi
+const (
+	RetentionSourceDefault      = "default"
+	RetentionSourceOrganization = "organization"
+	RetentionSourceUser         = "user"
+)
+
+// ErrInvalidRetention - Pengaturan retensi tidak valid
+var ErrInvalidRetention = errors.New("raw_days must be at least 1, hourly_days must be 0 or at least raw_days, daily_days must be 0 or at least hourly_days")
+
+// Resolusi ringkasan data sensor
+var rollupTables = []struct {
+	Table  string
+	Bucket time.Duration
+}{
+	{"sensor_rollup_hourly", time.Hour},
+	{"sensor_rollup_daily", 24 * time.Hour},
+}
+
+// RetentionSettings - Masa simpan data sensor yang berlaku untuk satu device / user
+type RetentionSettings struct {
+	RawDays    int    `json:"raw_days"`
+	HourlyDays int    `json:"hourly_days"` // 0 = selamanya
+	DailyDays  int    `json:"daily_days"`  // 0 = selamanya
+	Source     string `json:"source"`      // default, organization, user
+}
+
+// retentionDaysFromEnv - Membaca jumlah hari dari environment (0 diperbolehkan = selamanya)
+func retentionDaysFromEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// DefaultRetention - Retensi default dari environment
+func DefaultRetention() RetentionSettings {
+	return RetentionSettings{
+		RawDays:    intFromEnv("SENSOR_RAW_RETENTION_DAYS", defaultRawRetentionDays),
+		HourlyDays: retentionDaysFromEnv("SENSOR_HOURLY_RETENTION_DAYS", defaultHourlyRetentionDays),
+		DailyDays:  retentionDaysFromEnv("SENSOR_DAILY_RETENTION_DAYS", defaultDailyRetentionDays),
+		Source:     RetentionSourceDefault,
+	}
+}
+
+// ValidateRetention - Resolusi yang lebih kasar harus disimpan minimal selama resolusi yang lebih halus
+// agar query untuk rentang lama selalu menemukan ringkasan
+func ValidateRetention(settings RetentionSettings) error {
+	if settings.RawDays < 1 || settings.HourlyDays < 0 || settings.DailyDays < 0 {
+		return ErrInvalidRetention
+	}
+	if settings.HourlyDays != 0 && settings.HourlyDays < settings.RawDays {
+		return ErrInvalidRetention
+	}
+	if settings.DailyDays != 0 && (settings.HourlyDays == 0 || settings.DailyDays < settings.HourlyDays) {
+		return ErrInvalidRetention
+	}
+	return nil
+}
+
+// policySettings - Mengubah policy menjadi RetentionSettings
+func policySettings(policy models.RetentionPolicy, source string) RetentionSettings {
+	return RetentionSettings{RawDays: policy.RawDays, HourlyDays: policy.HourlyDays, DailyDays: policy.DailyDays, Source: source}
+}
+
+// EffectiveRetention - Retensi yang berlaku: policy user (jika userID diisi), lalu policy organisasi, lalu default
+func EffectiveRetention(userID, organizationID *uint) RetentionSettings {
+	var policy models.RetentionPolicy
+	if userID != nil {
+		if err := database.DB.Where("user_id = ?", *userID).First(&policy).Error; err == nil {
+			return policySettings(policy, RetentionSourceUser)
+		}
+	}
+	if organizationID != nil {
+		if err := database.DB.Where("organization_id = ?", *organizationID).First(&policy).Error; err == nil {
+			return policySettings(policy, RetentionSourceOrganization)
+		}
+	}
+	return DefaultRetention()
+}
+
+// FindRetentionPolicy - Policy milik organisasi atau user (salah satu diisi)
+func FindRetentionPolicy(organizationID, userID *uint) (*models.RetentionPolicy, error) {
+	var policy models.RetentionPolicy
+	if err := retentionPolicyOwner(database.DB, organizationID, userID).First(&policy).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetRetentionPolicy - Membuat atau mengubah policy organisasi / user
+func SetRetentionPolicy(organizationID, userID *uint, settings RetentionSettings) (*models.RetentionPolicy, error) {
+	if err := ValidateRetention(settings); err != nil {
+		return nil, err
+	}
+
+	policy, err := FindRetentionPolicy(organizationID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy = &models.RetentionPolicy{OrganizationID: organizationID, UserID: userID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	policy.RawDays = settings.RawDays
+	policy.HourlyDays = settings.HourlyDays
+	policy.DailyDays = settings.DailyDays
+	if err := database.DB.Save(policy).Error; err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// DeleteRetentionPolicy - Menghapus policy sehingga kembali ke policy organisasi / default
+func DeleteRetentionPolicy(organizationID, userID *uint) (bool, error) {
+	result := retentionPolicyOwner(database.DB, organizationID, userID).Delete(&models.RetentionPolicy{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// retentionPolicyOwner - Filter policy berdasarkan pemiliknya
+func retentionPolicyOwner(tx *gorm.DB, organizationID, userID *uint) *gorm.DB {
+	if userID != nil {
+		return tx.Where("user_id = ?", *userID)
+	}
+	return tx.Where("organization_id = ?", *organizationID)
+}
+
+// retentionPolicies - Semua policy yang tersimpan, dimuat sekali per putaran scheduler
+type retentionPolicies struct {
+	users         map[uint]RetentionSettings
+	organizations map[uint]RetentionSettings
+	fallback      RetentionSettings
+}
+
+// loadRetentionPolicies - Memuat semua policy organisasi dan user
+func loadRetentionPolicies() (retentionPolicies, error) {
+	policies := retentionPolicies{
+		users:         map[uint]RetentionSettings{},
+		organizations: map[uint]RetentionSettings{},
+		fallback:      DefaultRetention(),
+	}
+
+	var rows []models.RetentionPolicy
+	if err := database.DB.Find(&rows).Error; err != nil {
+		return policies, err
+	}
+	for _, row := range rows {
+		switch {
+		case row.UserID != nil:
+			policies.users[*row.UserID] = policySettings(row, RetentionSourceUser)
+		case row.OrganizationID != nil:
+			policies.organizations[*row.OrganizationID] = policySettings(row, RetentionSourceOrganization)
+		}
+	}
+	return policies, nil
+}
+
+// forDevice - Retensi yang berlaku untuk device (mengikuti pemilik dan organisasinya)
+func (p retentionPolicies) forDevice(device models.Device) RetentionSettings {
+	if settings, ok := p.users[device.UserID]; ok {
+		return settings
+	}
+	if device.OrganizationID != nil {
+		if settings, ok := p.organizations[*device.OrganizationID]; ok {
+			return settings
+		}
+	}
+	return p.fallback
+}
+
+// maxRawDays - Retensi data mentah terpanjang dari default dan semua policy
+func (p retentionPolicies) maxRawDays() int {
+	days := p.fallback.RawDays
+	for _, settings := range p.users {
+		days = max(days, settings.RawDays)
+	}
+	for _, settings := range p.organizations {
+		days = max(days, settings.RawDays)
+	}
+	return days
+}
+
+// StartRetentionScheduler - Worker yang meringkas data sensor lama lalu menghapusnya secara berkala
+func StartRetentionScheduler() {
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			if err := ApplyRetention(); err != nil {
+				log.Println("Failed to apply sensor data retention:", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// ApplyRetention - Menjalankan retensi untuk semua device
+func ApplyRetention() error {
+	policies, err := loadRetentionPolicies()
+	if err != nil {
+		return err
+	}
+
+	// Pada mode partisi / hypertable data mentah dihapus dengan men-drop partisi / chunk yang seluruhnya
+	// lebih lama dari retensi data mentah terpanjang
+	now := time.Now()
+	dropCutoff := startOfDayUTC(now.AddDate(0, 0, -policies.maxRawDays()))
+
+	var devices []models.Device
+	result := database.DB.Select("id, user_id, organization_id, raw_retained_from, hourly_retained_from").
+		FindInBatches(&devices, retentionDeviceBatch, func(tx *gorm.DB, batch int) error {
+			for _, device := range devices {
+				if err := applyDeviceRetention(device, policies.forDevice(device), now, dropCutoff); err != nil {
+					log.Printf("Failed to apply retention for device %d: %v", device.ID, err)
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if SensorStorageMode() != SensorStoragePlain {
+		return dropExpiredSensorData(dropCutoff)
+	}
+	return nil
+}
+
+// applyDeviceRetention - Meringkas data mentah yang lebih lama dari RawDays (per hari UTC) lalu menghapusnya,
+// kemudian menghapus ringkasan yang lebih lama dari HourlyDays / DailyDays.
+// Pada mode partisi / hypertable hari yang lebih lama dari dropCutoff hanya diringkas: data mentahnya
+// disembunyikan lewat raw_retained_from dan terhapus saat partisi / chunk-nya di-drop.
+func applyDeviceRetention(device models.Device, settings RetentionSettings, now, dropCutoff time.Time) error {
+	cutoff := startOfDayUTC(now.AddDate(0, 0, -settings.RawDays))
+	plain := SensorStorageMode() == SensorStoragePlain
+
+	// Setiap hari diproses dalam transaksi sendiri sehingga kunci di tabel hanya dipegang sebentar
+	for {
+		// Data mentah yang belum dihapus di mode partisi sudah diringkas jika sebelum raw_retained_from
+		query := database.DB.Model(&models.SensorData{}).Select("MIN(timestamp)").
+			Where("device_id = ? AND timestamp < ?", device.ID, cutoff)
+		if !plain && device.RawRetainedFrom != nil {
+			query = query.Where("timestamp >= ?", *device.RawRetainedFrom)
+		}
+		var oldest *time.Time
+		if err := query.Scan(&oldest).Error; err != nil {
+			return err
+		}
+		if oldest == nil {
+			break
+		}
+
+		day := startOfDayUTC(*oldest)
+		if err := rollupDay(device.ID, day, plain || !day.Before(dropCutoff)); err != nil {
+			return err
+		}
+		end := day.Add(24 * time.Hour)
+		device.RawRetainedFrom = &end
+		time.Sleep(retentionBatchPause)
+	}
+
+	if device.RawRetainedFrom == nil || device.RawRetainedFrom.Before(cutoff) {
+		err := database.DB.Model(&models.Device{}).Where("id = ?", device.ID).
+			UpdateColumn("raw_retained_from", cutoff).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return pruneRollups(device, settings, now)
+}
+
+// rollupDay - Meringkas data mentah satu hari ke tabel per jam dan per hari, lalu menghapus data mentahnya
+// jika deleteRaw (pada mode partisi / hypertable data mentah dihapus lewat drop partisi / chunk).
+func rollupDay(deviceID uint, day time.Time, deleteRaw bool) error {
+	end := day.Add(24 * time.Hour)
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		// Kunci device agar retensi device yang sama tidak berjalan bersamaan (mis. beberapa instance server)
+		// dan data terlambat untuk device ini menunggu (lihat foldLateReading)
+		var device models.Device
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id, raw_retained_from").First(&device, deviceID).Error; err != nil {
+			return err
+		}
+
+		source := "(SELECT device_id, timestamp, bpm, sp_o2, temp FROM sensor_data WHERE device_id = ? AND timestamp >= ? AND timestamp < ?) AS s"
+		for _, rollup := range rollupTables {
+			seconds := int64(rollup.Bucket / time.Second)
+			if err := tx.Exec(rollupSQL(rollup.Table, source), seconds, seconds, deviceID, day, end).Error; err != nil {
+				return err
+			}
+		}
+
+		if deleteRaw {
+			if err := tx.Where("device_id = ? AND timestamp >= ? AND timestamp < ?", deviceID, day, end).Delete(&models.SensorData{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if device.RawRetainedFrom == nil || device.RawRetainedFrom.Before(end) {
+			return tx.Model(&models.Device{}).Where("id = ?", deviceID).UpdateColumn("raw_retained_from", end).Error
+		}
+		return nil
+	})
+}
+
+// foldLateReading - Data yang datang setelah harinya diringkas (perangkat offline lebih lama dari retensi
+// data mentah) tidak disimpan sebagai data mentah, tapi langsung digabung ke ringkasan. Mengembalikan true
+// jika data sudah digabung.
+func foldLateReading(tx *gorm.DB, sensorData models.SensorData) (bool, error) {
+	// Retensi data mentah minimal 1 hari, data yang lebih baru tidak mungkin sudah diringkas
+	if sensorData.Timestamp.After(time.Now().Add(-24 * time.Hour)) {
+		return false, nil
+	}
+
+	// KEY SHARE menunggu rollupDay yang sedang berjalan tanpa menghalangi update heartbeat device
+	var device models.Device
+	if err := tx.Clauses(clause.Locking{Strength: "KEY SHARE"}).Select("id, raw_retained_from").
+		First(&device, sensorData.DeviceID).Error; err != nil {
+		return false, err
+	}
+	if device.RawRetainedFrom == nil || !sensorData.Timestamp.Before(*device.RawRetainedFrom) {
+		return false, nil
+	}
+
+	source := "(SELECT ?::bigint AS device_id, ?::timestamptz AS timestamp, ?::float8 AS bpm, ?::float8 AS sp_o2, ?::float8 AS temp) AS s"
+	for _, rollup := range rollupTables {
+		seconds := int64(rollup.Bucket / time.Second)
+		err := tx.Exec(rollupSQL(rollup.Table, source), seconds, seconds,
+			sensorData.DeviceID, sensorData.Timestamp, sensorData.BPM, sensorData.SpO2, sensorData.Temp).Error
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// rollupSQL - Query ringkasan dari source (subquery berkolom device_id, timestamp, bpm, sp_o2, temp) ke
+// tabel rollup, bucket = floor(epoch / detik) * detik (UTC). Jika bucket sudah ada (data terlambat),
+// min / max / sum digabung; median tidak bisa dihitung tanpa data mentah sebelumnya sehingga dikosongkan.
+func rollupSQL(table, source string) string {
+	return fmt.Sprintf(`
+		INSERT INTO %[1]s AS r (device_id, bucket_start, count,
+			bpm_min, bpm_max, bpm_sum, bpm_median,
+			sp_o2_min, sp_o2_max, sp_o2_sum, sp_o2_median,
+			temp_min, temp_max, temp_sum, temp_median)
+		SELECT device_id, to_timestamp(floor(extract(epoch FROM timestamp) / ?) * ?), COUNT(*),
+			MIN(bpm), MAX(bpm), SUM(bpm), percentile_cont(0.5) WITHIN GROUP (ORDER BY bpm),
+			MIN(sp_o2), MAX(sp_o2), SUM(sp_o2), percentile_cont(0.5) WITHIN GROUP (ORDER BY sp_o2),
+			MIN(temp), MAX(temp), SUM(temp), percentile_cont(0.5) WITHIN GROUP (ORDER BY temp)
+		FROM %[2]s
+		GROUP BY 1, 2
+		ON CONFLICT (device_id, bucket_start) DO UPDATE SET
+			count = r.count + EXCLUDED.count,
+			bpm_min = LEAST(r.bpm_min, EXCLUDED.bpm_min),
+			bpm_max = GREATEST(r.bpm_max, EXCLUDED.bpm_max),
+			bpm_sum = r.bpm_sum + EXCLUDED.bpm_sum,
+			bpm_median = NULL,
+			sp_o2_min = LEAST(r.sp_o2_min, EXCLUDED.sp_o2_min),
+			sp_o2_max = GREATEST(r.sp_o2_max, EXCLUDED.sp_o2_max),
+			sp_o2_sum = r.sp_o2_sum + EXCLUDED.sp_o2_sum,
+			sp_o2_median = NULL,
+			temp_min = LEAST(r.temp_min, EXCLUDED.temp_min),
+			temp_max = GREATEST(r.temp_max, EXCLUDED.temp_max),
+			temp_sum = r.temp_sum + EXCLUDED.temp_sum,
+			temp_median = NULL`, table, source)
+}
+
+// pruneRollups - Menghapus ringkasan yang sudah melewati masa simpannya. Batas ringkasan per jam dicatat
+// di hourly_retained_from agar query rentang lama beralih ke ringkasan per hari.
+func pruneRollups(device models.Device, settings RetentionSettings, now time.Time) error {
+	if settings.HourlyDays > 0 {
+		cutoff := startOfDayUTC(now.AddDate(0, 0, -settings.HourlyDays))
+		err := database.DB.Where("device_id = ? AND bucket_start < ?", device.ID, cutoff).
+			Delete(&models.SensorRollupHourly{}).Error
+		if err != nil {
+			return err
+		}
+		if device.HourlyRetainedFrom == nil || device.HourlyRetainedFrom.Before(cutoff) {
+			err := database.DB.Model(&models.Device{}).Where("id = ?", device.ID).
+				UpdateColumn("hourly_retained_from", cutoff).Error
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if settings.DailyDays > 0 {
+		err := database.DB.Where("device_id = ? AND bucket_start < ?", device.ID, startOfDayUTC(now.AddDate(0, 0, -settings.DailyDays))).
+			Delete(&models.SensorRollupDaily{}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropExpiredSensorData - Men-drop partisi bulanan / chunk TimescaleDB yang seluruhnya lebih lama dari
+// cutoff. Batas dimundurkan jika masih ada data mentah yang belum diringkas (mis. retensi device gagal).
+func dropExpiredSensorData(cutoff time.Time) error {
+	var pending *time.Time
+	err := database.DB.Raw(`
+		SELECT MIN(s.timestamp) FROM sensor_data s JOIN devices d ON d.id = s.device_id
+		WHERE s.timestamp < ? AND (d.raw_retained_from IS NULL OR s.timestamp >= d.raw_retained_from)`, cutoff).
+		Scan(&pending).Error
+	if err != nil {
+		return err
+	}
+	if pending != nil && pending.Before(cutoff) {
+		cutoff = startOfDayUTC(*pending)
+	}
+
+	switch SensorStorageMode() {
+	case SensorStorageTimescale:
+		return database.DB.Exec("SELECT drop_chunks('sensor_data', older_than => ?::timestamptz)", cutoff).Error
+
+	case SensorStoragePartitioned:
+		partitions, err := SensorPartitions()
+		if err != nil {
+			return err
+		}
+		for _, partition := range partitions {
+			var year, month int
+			if _, err := fmt.Sscanf(partition.Name, "sensor_data_y%04dm%02d", &year, &month); err != nil {
+				continue // Partisi default
+			}
+			if end := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC); end.After(cutoff) {
+				continue
+			}
+			if err := database.DB.Exec("DROP TABLE " + partition.Name).Error; err != nil {
+				return err
+			}
+			log.Println("Dropped expired sensor data partition", partition.Name)
+		}
+		// Partisi default hanya berisi data di luar rentang partisi bulanan, jumlahnya kecil
+		return database.DB.Exec("DELETE FROM sensor_data_default WHERE timestamp < ?", cutoff).Error
+	}
+	return nil
+}
+
+// startOfDayUTC - Awal hari (UTC) dari waktu t
+func startOfDayUTC(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// SensorSeries - Sumber data sensor satu device dengan kolom yang sama seperti tabel sensor_data.
+// Untuk periode yang data mentahnya sudah dihapus retensi, dipakai rata-rata per jam (id = 0), dan
+// rata-rata per hari untuk periode yang ringkasan per jamnya juga sudah dihapus.
+func SensorSeries(device models.Device) *gorm.DB {
+	db := database.DB.Session(&gorm.Session{NewDB: true})
+	if device.RawRetainedFrom == nil {
+		return db.Table("sensor_data").Where("device_id = ?", device.ID)
+	}
+
+	rollupColumns := "0 AS id, device_id, bpm_sum / count AS bpm, sp_o2_sum / count AS sp_o2, temp_sum / count AS temp, " +
+		"bucket_start AS timestamp, NULL AS reading_id, NULL::bigint AS seq, NULL AS boot_id"
+	raw := db.Table("sensor_data").Select("id, device_id, bpm, sp_o2, temp, timestamp, reading_id, seq, boot_id").
+		Where("device_id = ? AND timestamp >= ?", device.ID, *device.RawRetainedFrom)
+	hourly := db.Table("sensor_rollup_hourly").Select(rollupColumns).
+		Where("device_id = ? AND bucket_start < ?", device.ID, *device.RawRetainedFrom)
+	if device.HourlyRetainedFrom == nil {
+		return db.Table("(? UNION ALL ?) AS sensor_data", raw, hourly)
+	}
+
+	hourly = hourly.Where("bucket_start >= ?", *device.HourlyRetainedFrom)
+	daily := db.Table("sensor_rollup_daily").Select(rollupColumns).
+		Where("device_id = ? AND bucket_start < ?", device.ID, *device.HourlyRetainedFrom)
+	return db.Table("(? UNION ALL ? UNION ALL ?) AS sensor_data", raw, hourly, daily)
+}
+
+// RollupTable - Tabel ringkasan yang dipakai untuk interval agregasi (per hari untuk interval >= 1 hari)
+func RollupTable(interval time.Duration) (string, time.Duration) {
+	if interval >= 24*time.Hour {
+		return rollupTables[1].Table, rollupTables[1].Bucket
+	}
+	return rollupTables[0].Table, rollupTables[0].Bucket
+}
+
+// SensorRollups - Ringkasan data sensor device dalam rentang waktu, dari tabel yang sesuai interval.
+// Rentang sebelum hourly_retained_from dibaca dari ringkasan per hari.
+func SensorRollups(device models.Device, interval time.Duration, from, to time.Time) ([]models.SensorRollup, error) {
+	table, _ := RollupTable(interval)
+	rollups := []models.SensorRollup{}
+	if table == rollupTables[0].Table && device.HourlyRetainedFrom != nil && from.Before(*device.HourlyRetainedFrom) {
+		dailyTo := to
+		if device.HourlyRetainedFrom.Before(dailyTo) {
+			dailyTo = *device.HourlyRetainedFrom
+		}
+		if err := database.DB.Table(rollupTables[1].Table).Where("device_id = ? AND bucket_start >= ? AND bucket_start < ?", device.ID, from, dailyTo).
+			Order("bucket_start").Find(&rollups).Error; err != nil {
+			return nil, err
+		}
+		from = dailyTo
+	}
+
+	var rest []models.SensorRollup
+	err := database.DB.Table(table).Where("device_id = ? AND bucket_start >= ? AND bucket_start < ?", device.ID, from, to).
+		Order("bucket_start").Find(&rest).Error
+	return append(rollups, rest...), err
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestLateReadingMergesRollupWithoutMedian(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "late")
+
+	day := startOfDayUTC(time.Now().AddDate(0, 0, -10))
+	for _, bpm := range []float64{60, 70, 90} {
+		storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: bpm, Timestamp: day.Add(time.Hour)})
+	}
+	if err := rollupDay(device.ID, day, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var hourly models.SensorRollupHourly
+	db.Where("device_id = ? AND bucket_start = ?", device.ID, day.Add(time.Hour)).First(&hourly)
+	if hourly.Count != 3 || hourly.BPMMedian == nil || *hourly.BPMMedian != 70 {
+		t.Fatalf("expected 3 readings with median 70, got %+v", hourly.SensorRollup)
+	}
+
+	// Data terlambat untuk hari yang sudah diringkas tidak disimpan mentah, median bucket-nya tidak diketahui lagi
+	late, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 100, Timestamp: day.Add(time.Hour + time.Minute)})
+	if duplicate || late.ID != 0 {
+		t.Fatalf("late reading should be folded into the rollup, got id %d (duplicate=%v)", late.ID, duplicate)
+	}
+	db.Where("device_id = ? AND bucket_start = ?", device.ID, day.Add(time.Hour)).First(&hourly)
+	if hourly.Count != 4 || hourly.BPMSum != 320 || hourly.BPMMax != 100 || hourly.BPMMedian != nil {
+		t.Fatalf("expected merged bucket without median, got %+v", hourly.SensorRollup)
+	}
+
+	var raw int64
+	db.Model(&models.SensorData{}).Where("device_id = ?", device.ID).Count(&raw)
+	if raw != 0 {
+		t.Fatalf("expected no raw readings, got %d", raw)
+	}
+}
+
+func TestSensorSeriesFallsBackToDailyRollups(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "series")
+
+	now := time.Now()
+	oldDay := startOfDayUTC(now.AddDate(0, 0, -40))
+	recentDay := startOfDayUTC(now.AddDate(0, 0, -10))
+	for _, timestamp := range []time.Time{oldDay.Add(time.Hour), recentDay.Add(time.Hour), now} {
+		storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: timestamp})
+	}
+
+	settings := RetentionSettings{RawDays: 7, HourlyDays: 30, DailyDays: 0}
+	if err := applyDeviceRetention(device, settings, now, startOfDayUTC(now.AddDate(0, 0, -settings.RawDays))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&device, device.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if device.HourlyRetainedFrom == nil {
+		t.Fatal("expected hourly_retained_from to be set after pruning hourly rollups")
+	}
+
+	// Hari lama dari ringkasan per hari, hari yang lebih baru dari ringkasan per jam, sisanya data mentah
+	var timestamps []time.Time
+	if err := SensorSeries(device).Order("timestamp").Pluck("timestamp", &timestamps).Error; err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{oldDay, recentDay.Add(time.Hour)}
+	if len(timestamps) != 3 || !timestamps[0].Equal(expected[0]) || !timestamps[1].Equal(expected[1]) {
+		t.Fatalf("expected daily, hourly and raw points, got %v", timestamps)
+	}
+}
+
+func TestFoldedReadingIsNotPublished(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "folded")
+
+	day := startOfDayUTC(time.Now().AddDate(0, 0, -10))
+	storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: day.Add(time.Hour)})
+	if err := rollupDay(device.ID, day, true); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := SensorHub.Subscribe(device.ID, device.UserID, device.UserID)
+	defer SensorHub.Unsubscribe(sub)
+	late := models.SensorData{DeviceID: device.ID, BPM: 200, Timestamp: day.Add(2 * time.Hour)}
+	duplicate, _, err := IngestSensorData(db, &late)
+	if err != nil || duplicate || late.ID != 0 {
+		t.Fatalf("expected folded reading, got id %d duplicate %v (err %v)", late.ID, duplicate, err)
+	}
+	if len(sub.C) != 0 {
+		t.Fatal("folded reading must not be sent to live subscribers")
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/ratelimit"
+)
+
+// Pengaturan pembatasan login dan registrasi
+const (
+	defaultLoginMaxFailures  = 10
+	defaultLockoutMinutes    = 15
+	limiterRetention         = 24 * time.Hour
+	limiterPruneInterval     = time.Hour
+	securityEventsQueryLimit = 1000
+)
+
+// Limiter - Store rate limit (memory secara default, SQL jika RATE_LIMIT_STORE=sql)
+var Limiter ratelimit.Store
+
+// InitLimiter - Memilih store rate limit dari environment. SQL dipakai jika server berjalan lebih dari satu instance.
+func InitLimiter() {
+	if strings.EqualFold(os.Getenv("RATE_LIMIT_STORE"), "sql") {
+		store := ratelimit.NewSQLStore(database.DB)
+		Limiter = store
+		go func() {
+			ticker := time.NewTicker(limiterPruneInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := store.Prune(context.Background(), time.Now().Add(-limiterRetention)); err != nil {
+					log.Println("Failed to prune login throttles:", err)
+				}
+			}
+		}()
+		return
+	}
+	Limiter = ratelimit.NewMemoryStore(limiterRetention)
+}
+
+// limiter - Mengambil store rate limit, memory jika belum diinisialisasi
+func limiter() ratelimit.Store {
+	if Limiter == nil {
+		Limiter = ratelimit.NewMemoryStore(limiterRetention)
+	}
+	return Limiter
+}
+
+// intFromEnv - Membaca angka positif dari environment
+func intFromEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+// loginUserPolicy - Per username: backoff setelah 3 gagal, lockout setelah LOGIN_MAX_FAILURES gagal
+func loginUserPolicy() ratelimit.Policy {
+	return ratelimit.Policy{
+		Limit:           3,
+		Window:          time.Hour,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    intFromEnv("LOGIN_MAX_FAILURES", defaultLoginMaxFailures),
+		LockoutDuration: time.Duration(intFromEnv("LOGIN_LOCKOUT_MINUTES", defaultLockoutMinutes)) * time.Minute,
+	}
+}
+
+// Per IP: lebih longgar karena satu IP bisa dipakai banyak user (NAT), tanpa lockout
+var loginIPPolicy = ratelimit.Policy{
+	Limit:     20,
+	Window:    time.Hour,
+	BaseDelay: time.Second,
+	MaxDelay:  15 * time.Minute,
+}
+
+// Policy untuk endpoint publik yang bisa disalahgunakan script (registrasi, lupa password)
+var publicEndpointPolicies = map[string]ratelimit.Policy{
+	"register":        {Limit: 5, Window: time.Hour, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	"password_forgot": {Limit: 5, Window: time.Hour, BaseDelay: time.Minute, MaxDelay: time.Hour},
+}
+
+// loginUserKey - Key rate limit login per username
+func loginUserKey(username string) string {
+	return "login:user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// loginIPKey - Key rate limit login per IP
+func loginIPKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+// LoginRetryAfter - Sisa waktu blokir login untuk username atau IP (0 jika boleh mencoba)
+func LoginRetryAfter(ctx context.Context, username, ip string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{loginUserKey(username), loginIPKey(ip)} {
+		state, err := limiter().Get(ctx, key)
+		if err != nil {
+			log.Println("Rate limiter unavailable:", err)
+			continue
+		}
+		if retry := state.RetryAfter(now); retry > wait {
+			wait = retry
+		}
+	}
+	return wait
+}
+
+// RecordLoginFailure - Mencatat login gagal ke rate limiter dan security log
+func RecordLoginFailure(ctx context.Context, username, ip, userAgent string, userID *uint, reason string) {
+	now := time.Now()
+	userState, err := limiter().Hit(ctx, loginUserKey(username), loginUserPolicy(), now)
+	if err != nil {
+		log.Println("Rate limiter unavailable:", err)
+	}
+	if _, err := limiter().Hit(ctx, loginIPKey(ip), loginIPPolicy, now); err != nil {
+		log.Println("Rate limiter unavailable:", err)
+	}
+
+	LogSecurityEvent(models.SecurityEvent{
+		Type: models.SecurityLoginFailed, UserID: userID, Username: username, IP: ip, UserAgent: userAgent, Detail: reason,
+	})
+	if userState.Locked && userState.Count == loginUserPolicy().LockoutAfter {
+		LogSecurityEvent(models.SecurityEvent{
+			Type: models.SecurityAccountLocked, UserID: userID, Username: username, IP: ip, UserAgent: userAgent,
+			Detail: "locked until " + userState.BlockedUntil.Format(time.RFC3339),
+		})
+	}
+}
+
+// RecordLoginSuccess - Menghapus hitungan gagal untuk username setelah login berhasil
+func RecordLoginSuccess(ctx context.Context, username string) {
+	if err := limiter().Reset(ctx, loginUserKey(username)); err != nil {
+		log.Println("Rate limiter unavailable:", err)
+	}
+}
+
+// HitPublicEndpoint - Mencatat request ke endpoint publik per IP, mengembalikan sisa waktu blokir jika sedang diblokir
+func HitPublicEndpoint(ctx context.Context, scope, ip string) time.Duration {
+	policy, ok := publicEndpointPolicies[scope]
+	if !ok {
+		return 0
+	}
+	key := scope + ":ip:" + ip
+	now := time.Now()
+
+	// Cek blokir dan pencatatan dalam satu operasi terkunci, agar request paralel tidak lolos bersamaan.
+	// Request yang membuat batas terlampaui masih diproses, request berikutnya ditolak.
+	state, allowed, err := limiter().Attempt(ctx, key, policy, now)
+	if err != nil {
+		log.Println("Rate limiter unavailable:", err)
+		return 0
+	}
+	if !allowed {
+		return state.RetryAfter(now)
+	}
+	if state.Count == policy.Limit+1 {
+		LogSecurityEvent(models.SecurityEvent{Type: models.SecurityRateLimited, IP: ip, Detail: scope})
+	}
+	return 0
+}
+
+// Lockouts - Daftar key login / registrasi yang sedang diblokir
+func Lockouts(ctx context.Context) ([]ratelimit.State, error) {
+	return limiter().Blocked(ctx, time.Now())
+}
+
+// ClearLockout - Membuka blokir key dan mencatatnya di security log
+func ClearLockout(ctx context.Context, key string, adminID uint) error {
+	if err := limiter().Reset(ctx, key); err != nil {
+		return err
+	}
+	LogSecurityEvent(models.SecurityEvent{
+		Type:   models.SecurityLockoutCleared,
+		UserID: &adminID,
+		Detail: "cleared " + key,
+	})
+	return nil
+}
+
+// LogSecurityEvent - Menyimpan event ke security log
+func LogSecurityEvent(event models.SecurityEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Println("Failed to write security log:", err)
+	}
+}
+
+// SecurityEvents - Mengambil security log terbaru dengan filter opsional
+func SecurityEvents(eventType, username string, limit int) ([]models.SecurityEvent, error) {
+	if limit <= 0 || limit > securityEventsQueryLimit {
+		limit = securityEventsQueryLimit
+	}
+	query := database.DB.Order("created_at DESC, id DESC").Limit(limit)
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+
+	var events []models.SecurityEvent
+	err := query.Find(&events).Error
+	return events, err
+}
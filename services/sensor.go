@@ -0,0 +1,180 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Default rentang waktu pengecekan duplikat seq (SENSOR_SEQ_DEDUP_WINDOW_MINUTES)
+const defaultSeqDedupWindow = time.Hour
+
+// Jumlah advisory lock per device yang dipakai pengecekan duplikat (membatasi jumlah lock per transaksi batch)
+const readingLockSlots = 64
+
+// SeqDedupWindow - seq dimulai ulang saat perangkat reboot / flash ulang firmware, jadi seq yang sama
+// hanya dianggap duplikat jika boot_id sama dan timestamp-nya berjarak kurang dari rentang ini
+func SeqDedupWindow() time.Duration {
+	return durationFromEnv("SENSOR_SEQ_DEDUP_WINDOW_MINUTES", defaultSeqDedupWindow)
+}
+
+// MaxClockSkew - Toleransi timestamp perangkat yang lebih maju dari jam server
+const MaxClockSkew = 5 * time.Minute
+
+// ValidateReadingTimestamp - Menolak timestamp perangkat yang lebih maju dari jam server melebihi MaxClockSkew
+func ValidateReadingTimestamp(timestamp, now time.Time) string {
+	if timestamp.After(now.Add(MaxClockSkew)) {
+		return "timestamp is in the future"
+	}
+	return ""
+}
+
+// ValidateReadingIdentity - Memeriksa reading_id, boot_id dan seq yang dikirim perangkat
+func ValidateReadingIdentity(readingID, bootID *string, seq *int64) string {
+	if readingID != nil && (len(*readingID) == 0 || len(*readingID) > 64) {
+		return "reading_id must be 1-64 characters"
+	}
+	if bootID != nil && (len(*bootID) == 0 || len(*bootID) > 64) {
+		return "boot_id must be 1-64 characters"
+	}
+	if seq != nil && *seq < 0 {
+		return "seq must not be negative"
+	}
+	return ""
+}
+
+// StoreSensorData - Menyimpan data sensor secara idempotent.
+// Duplikat dicari berdasarkan reading_id lebih dulu, lalu seq (boot_id sama, dalam SeqDedupWindow).
+// Jika ditemukan, data lama dimuat ke sensorData dan duplicate bernilai true.
+// Data untuk hari yang sudah diringkas retensi langsung digabung ke ringkasan (ID tetap 0).
+func StoreSensorData(tx *gorm.DB, sensorData *models.SensorData) (bool, error) {
+	if sensorData.ReadingID != nil {
+		// Unique index tabel partisi / hypertable menyertakan timestamp, pengiriman ulang dengan timestamp
+		// berbeda hanya tertahan pengecekan ini sehingga perlu dikunci dari request paralel
+		if SensorStorageMode() != SensorStoragePlain {
+			if err := lockReadingIdentity(tx, sensorData.DeviceID, "reading:"+*sensorData.ReadingID); err != nil {
+				return false, err
+			}
+		}
+		if found, err := loadExistingReading(tx, sameReadingID(tx, *sensorData), sensorData); err != nil || found {
+			return found, err
+		}
+	}
+
+	if sensorData.Seq != nil {
+		bootID := ""
+		if sensorData.BootID != nil {
+			bootID = *sensorData.BootID
+		}
+		if err := lockReadingIdentity(tx, sensorData.DeviceID, fmt.Sprintf("seq:%s:%d", bootID, *sensorData.Seq)); err != nil {
+			return false, err
+		}
+		if found, err := loadExistingReading(tx, sameSeq(tx, *sensorData), sensorData); err != nil || found {
+			return found, err
+		}
+	}
+
+	// Hari yang data mentahnya sudah diringkas retensi tidak menerima data mentah baru
+	if folded, err := foldLateReading(tx, *sensorData); err != nil || folded {
+		return false, err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(sensorData)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return false, nil
+	}
+
+	// Tidak ada baris baru, berarti reading_id bentrok dengan data yang baru saja disimpan request lain
+	if sensorData.ReadingID == nil {
+		return false, fmt.Errorf("sensor data was not inserted")
+	}
+	found, err := loadExistingReading(tx, sameReadingID(tx, *sensorData), sensorData)
+	if err == nil && !found {
+		err = fmt.Errorf("sensor data was not inserted")
+	}
+	return found, err
+}
+
+// sameReadingID - Query data sensor dengan reading_id yang sama untuk device yang sama. Pada mode
+// partisi / hypertable hanya dicari dalam readingDedupWindow agar tidak memindai semua partisi.
+func sameReadingID(tx *gorm.DB, sensorData models.SensorData) *gorm.DB {
+	query := tx.Where("device_id = ? AND reading_id = ?", sensorData.DeviceID, *sensorData.ReadingID)
+	if SensorStorageMode() != SensorStoragePlain {
+		query = query.Where("timestamp > ? AND timestamp < ?",
+			sensorData.Timestamp.Add(-readingDedupWindow), sensorData.Timestamp.Add(readingDedupWindow))
+	}
+	return query
+}
+
+// sameSeq - Query data sensor dengan seq dan boot_id yang sama, timestamp dalam SeqDedupWindow
+func sameSeq(tx *gorm.DB, sensorData models.SensorData) *gorm.DB {
+	window := SeqDedupWindow()
+	return tx.Where("device_id = ? AND seq = ? AND boot_id IS NOT DISTINCT FROM ?", sensorData.DeviceID, *sensorData.Seq, sensorData.BootID).
+		Where("timestamp > ? AND timestamp < ?", sensorData.Timestamp.Add(-window), sensorData.Timestamp.Add(window))
+}
+
+// loadExistingReading - Memuat data lama yang cocok dengan query ke sensorData
+func loadExistingReading(tx *gorm.DB, query *gorm.DB, sensorData *models.SensorData) (bool, error) {
+	var existing models.SensorData
+	if err := query.Order("timestamp DESC").Limit(1).Find(&existing).Error; err != nil {
+		return false, err
+	}
+	if existing.ID == 0 {
+		return false, nil
+	}
+	*sensorData = existing
+	return true, nil
+}
+
+// lockReadingIdentity - Advisory lock (sampai transaksi selesai) untuk satu identitas data sensor,
+// agar pengecekan duplikat tanpa unique index aman dari request paralel. Identitas dipetakan ke
+// readingLockSlots lock per device sehingga data lain dari device yang sama jarang ikut menunggu.
+func lockReadingIdentity(tx *gorm.DB, deviceID uint, identity string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?::int, hashtext(?) & ?)", deviceID, identity, readingLockSlots-1).Error
+}
+
+// CountSkippedSequence - Menghitung jumlah nomor urut yang terlewat sebelum seq, dibanding data terakhir
+// dari boot yang sama. seq yang lebih kecil berarti perangkat mulai ulang, tidak ada yang terlewat.
+func CountSkippedSequence(tx *gorm.DB, deviceID uint, bootID *string, seq int64) (int64, error) {
+	var last *int64
+	err := tx.Model(&models.SensorData{}).Select("seq").
+		Where("device_id = ? AND seq IS NOT NULL AND boot_id IS NOT DISTINCT FROM ?", deviceID, bootID).
+		Order("timestamp DESC, id DESC").Limit(1).Scan(&last).Error
+	if err != nil {
+		return 0, err
+	}
+	if last == nil || seq <= *last+1 {
+		return 0, nil
+	}
+	return seq - *last - 1, nil
+}
+
+// IngestSensorData - Menyimpan satu data sensor beserta deteksi seq yang terlewat
+func IngestSensorData(db *gorm.DB, sensorData *models.SensorData) (duplicate bool, missed int64, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if sensorData.Seq != nil {
+			if missed, err = CountSkippedSequence(tx, sensorData.DeviceID, sensorData.BootID, *sensorData.Seq); err != nil {
+				return err
+			}
+		}
+		duplicate, err = StoreSensorData(tx, sensorData)
+		return err
+	})
+
+	// Kirim ke subscriber live dan evaluasi alert setelah transaksi commit. Data yang digabung ke ringkasan
+	// (ID 0) tidak tersimpan sebagai data mentah sehingga tidak dikirim maupun dievaluasi.
+	if err == nil && !duplicate && sensorData.ID != 0 {
+		SensorHub.Publish(*sensorData)
+		EvaluateAlerts(sensorData.DeviceID)
+		RefreshSensorAggregates([]models.SensorData{*sensorData})
+	}
+	return duplicate, missed, err
+}
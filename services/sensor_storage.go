@@ -0,0 +1,398 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Mode penyimpanan tabel sensor_data (SENSOR_STORAGE_MODE)
+const (
+	SensorStoragePlain       = "plain"       // Tabel biasa (default, fallback)
+	SensorStorageTimescale   = "timescale"   // Hypertable TimescaleDB + continuous aggregate
+	SensorStoragePartitioned = "partitioned" // Partisi native PostgreSQL per bulan
+)
+
+// Pengaturan partisi / hypertable
+const (
+	timescaleChunkInterval    = "7 days"
+	partitionMonthsAhead      = 3  // Partisi dibuat beberapa bulan sebelum dipakai
+	partitionMaxMonthsBack    = 60 // Data yang lebih lama masuk ke partisi default
+	partitionMaintenanceEvery = 24 * time.Hour
+
+	// Rentang pencarian reading_id yang sama pada mode partisi / hypertable. Unique index menyertakan
+	// timestamp, jadi pengiriman ulang dengan timestamp berbeda dicek manual dalam rentang ini.
+	readingDedupWindow = 24 * time.Hour
+
+	// Policy continuous aggregate hanya me-refresh sekian bucket terakhir. Data backfill yang lebih
+	// lama di-refresh manual lewat RefreshSensorAggregates.
+	aggregatePolicyBuckets = 3
+)
+
+// Continuous aggregate TimescaleDB per interval (kolom sama dengan query agregasi di controller)
+var sensorAggregateViews = map[time.Duration]string{
+	time.Hour:      "sensor_data_hourly",
+	24 * time.Hour: "sensor_data_daily",
+}
+
+// Mode yang benar-benar aktif di database (diisi InitSensorStorage)
+var (
+	sensorStorage         = SensorStoragePlain
+	sensorAggregatesReady bool
+)
+
+// SensorStorageMode - Mode penyimpanan sensor_data yang aktif
+func SensorStorageMode() string {
+	return sensorStorage
+}
+
+// InitSensorStorage - Mendeteksi mode tabel sensor_data saat server start. Server tidak mengubah tabel:
+// jika SENSOR_STORAGE_MODE berbeda dengan mode di database, start ditolak sampai konversi dijalankan
+// lewat `go run ./cmd/migrate sensor-storage <mode>`. Yang tetap dilakukan server hanya membuat partisi
+// bulan-bulan berikutnya (mode partitioned).
+func InitSensorStorage() error {
+	current, err := detectSensorStorage()
+	if err != nil {
+		return fmt.Errorf("failed to detect sensor storage mode: %w", err)
+	}
+
+	requested := strings.ToLower(os.Getenv("SENSOR_STORAGE_MODE"))
+	if requested != "" && requested != current {
+		return fmt.Errorf("sensor_data is %s but SENSOR_STORAGE_MODE=%s, run `go run ./cmd/migrate sensor-storage %s`",
+			current, requested, requested)
+	}
+	sensorStorage = current
+
+	switch sensorStorage {
+	case SensorStorageTimescale:
+		ready, err := sensorAggregatesExist()
+		if err != nil {
+			return err
+		}
+		if !ready {
+			log.Println("⚠️ Continuous aggregates are not available, aggregates are computed from raw data " +
+				"(run `go run ./cmd/migrate sensor-storage timescale` to create them)")
+		}
+		sensorAggregatesReady = ready
+	case SensorStoragePartitioned:
+		go func() {
+			ticker := time.NewTicker(partitionMaintenanceEvery)
+			defer ticker.Stop()
+			for {
+				if err := ensureSensorPartitions(time.Now()); err != nil {
+					log.Println("Failed to create sensor data partitions:", err)
+				}
+				<-ticker.C
+			}
+		}()
+	}
+	return nil
+}
+
+// ConvertSensorStorage - Mengubah tabel sensor_data biasa ke mode hypertable / partisi (dipanggil dari
+// perintah migrate, bukan saat server start). Konversi menyalin ulang data (partisi) atau memindahkannya
+// ke chunk (hypertable) dan mengunci tabel selama proses berlangsung, jalankan saat maintenance.
+// Menjalankan ulang untuk mode yang sudah aktif hanya melengkapi continuous aggregate.
+func ConvertSensorStorage(mode string) error {
+	current, err := detectSensorStorage()
+	if err != nil {
+		return fmt.Errorf("failed to detect sensor storage mode: %w", err)
+	}
+
+	if current != mode {
+		if current != SensorStoragePlain {
+			return fmt.Errorf("sensor_data is already %s, convert back to %s manually first", current, SensorStoragePlain)
+		}
+		switch mode {
+		case SensorStorageTimescale:
+			err = convertToHypertable()
+		case SensorStoragePartitioned:
+			err = convertToPartitioned()
+		default:
+			err = fmt.Errorf("unknown sensor storage mode %q", mode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if mode == SensorStorageTimescale {
+		if err := ensureSensorAggregates(); err != nil {
+			return fmt.Errorf("sensor_data is a hypertable but continuous aggregates failed (TimescaleDB 2.7+ required): %w", err)
+		}
+	}
+	return nil
+}
+
+// sensorAggregatesExist - Mengecek apakah semua continuous aggregate sudah dibuat
+func sensorAggregatesExist() (bool, error) {
+	for _, view := range sensorAggregateViews {
+		var exists bool
+		if err := database.DB.Raw("SELECT to_regclass(?) IS NOT NULL", view).Scan(&exists).Error; err != nil {
+			return false, err
+		}
+		if !exists {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// detectSensorStorage - Membaca mode sensor_data dari katalog PostgreSQL
+func detectSensorStorage() (string, error) {
+	var relkind string
+	if err := database.DB.Raw("SELECT relkind FROM pg_class WHERE oid = to_regclass('sensor_data')").Scan(&relkind).Error; err != nil {
+		return "", err
+	}
+	if relkind == "p" {
+		return SensorStoragePartitioned, nil
+	}
+
+	var timescale bool
+	if err := database.DB.Raw("SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'timescaledb')").Scan(&timescale).Error; err != nil {
+		return "", err
+	}
+	if timescale {
+		var hypertable bool
+		err := database.DB.Raw("SELECT EXISTS (SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = 'sensor_data')").
+			Scan(&hypertable).Error
+		if err != nil {
+			return "", err
+		}
+		if hypertable {
+			return SensorStorageTimescale, nil
+		}
+	}
+	return SensorStoragePlain, nil
+}
+
+// partitionedIdentitySQL - Primary key dan unique index sensor_data. Pada tabel partisi / hypertable,
+// setiap unique constraint wajib menyertakan kolom partisi (timestamp).
+var partitionedIdentitySQL = []string{
+	"ALTER TABLE sensor_data ADD PRIMARY KEY (id, timestamp)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_sensor_device_reading ON sensor_data (device_id, reading_id, timestamp)",
+	"CREATE INDEX IF NOT EXISTS idx_sensor_device_seq ON sensor_data (device_id, seq, timestamp)",
+	"CREATE INDEX IF NOT EXISTS idx_sensor_device_time ON sensor_data (device_id, timestamp)",
+}
+
+// convertToHypertable - Mengubah sensor_data menjadi hypertable TimescaleDB (chunk per 7 hari)
+func convertToHypertable() error {
+	if err := database.DB.Exec("CREATE EXTENSION IF NOT EXISTS timescaledb").Error; err != nil {
+		return fmt.Errorf("timescaledb extension is not available: %w", err)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"ALTER TABLE sensor_data DROP CONSTRAINT IF EXISTS sensor_data_pkey",
+			"DROP INDEX IF EXISTS idx_sensor_device_reading",
+			"DROP INDEX IF EXISTS idx_sensor_device_seq",
+		}
+		for _, statement := range append(statements, partitionedIdentitySQL...) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Exec("SELECT create_hypertable('sensor_data', 'timestamp', chunk_time_interval => INTERVAL '" + timescaleChunkInterval +
+			"', create_default_indexes => false, migrate_data => true)").Error
+	})
+}
+
+// convertToPartitioned - Membuat ulang sensor_data sebagai tabel partisi per bulan lalu menyalin datanya
+func convertToPartitioned() error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			"LOCK TABLE sensor_data IN ACCESS EXCLUSIVE MODE",
+			"ALTER SEQUENCE sensor_data_id_seq OWNED BY NONE", // Sequence id tidak ikut terhapus bersama tabel lama
+			"CREATE TABLE sensor_data_partitioned (LIKE sensor_data INCLUDING DEFAULTS) PARTITION BY RANGE (timestamp)",
+			"CREATE TABLE sensor_data_default PARTITION OF sensor_data_partitioned DEFAULT",
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		// Partisi untuk data lama sampai beberapa bulan ke depan
+		var oldest *time.Time
+		if err := tx.Raw("SELECT MIN(timestamp) FROM sensor_data").Scan(&oldest).Error; err != nil {
+			return err
+		}
+		if err := createMonthlyPartitions(tx, "sensor_data_partitioned", oldest, time.Now()); err != nil {
+			return err
+		}
+
+		statements = []string{
+			"INSERT INTO sensor_data_partitioned SELECT * FROM sensor_data",
+			"DROP TABLE sensor_data",
+			"ALTER TABLE sensor_data_partitioned RENAME TO sensor_data",
+			"ALTER SEQUENCE sensor_data_id_seq OWNED BY sensor_data.id",
+		}
+		statements = append(statements, partitionedIdentitySQL...)
+		statements = append(statements, "ALTER TABLE sensor_data ADD CONSTRAINT fk_sensor_data_device FOREIGN KEY (device_id) "+
+			"REFERENCES devices(id) ON DELETE CASCADE ON UPDATE CASCADE")
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ensureSensorPartitions - Membuat partisi bulan ini sampai partitionMonthsAhead bulan ke depan
+func ensureSensorPartitions(now time.Time) error {
+	return createMonthlyPartitions(database.DB, "sensor_data", nil, now)
+}
+
+// createMonthlyPartitions - Membuat partisi bulanan (UTC) dari bulan data tertua (atau bulan ini) sampai
+// partitionMonthsAhead bulan ke depan. Data di luar rentang tersebut masuk ke partisi default.
+func createMonthlyPartitions(tx *gorm.DB, table string, oldest *time.Time, now time.Time) error {
+	current := startOfMonthUTC(now)
+	start := current
+	if oldest != nil && oldest.Before(start) {
+		start = startOfMonthUTC(*oldest)
+	}
+	if limit := current.AddDate(0, -partitionMaxMonthsBack, 0); start.Before(limit) {
+		start = limit
+	}
+
+	for month := start; !month.After(current.AddDate(0, partitionMonthsAhead, 0)); month = month.AddDate(0, 1, 0) {
+		statement := fmt.Sprintf("CREATE TABLE IF NOT EXISTS sensor_data_y%04dm%02d PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+			month.Year(), month.Month(), table, month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startOfMonthUTC - Awal bulan (UTC) dari waktu t
+func startOfMonthUTC(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// SensorPartition - Informasi satu partisi / chunk sensor_data
+type SensorPartition struct {
+	Name  string `json:"name"`
+	Range string `json:"range"`
+}
+
+// SensorPartitions - Daftar partisi (mode partitioned) atau chunk (mode timescale)
+func SensorPartitions() ([]SensorPartition, error) {
+	partitions := []SensorPartition{}
+	var err error
+	switch sensorStorage {
+	case SensorStoragePartitioned:
+		err = database.DB.Raw(`
+			SELECT c.relname AS name, pg_get_expr(c.relpartbound, c.oid) AS range
+			FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+			WHERE i.inhparent = 'sensor_data'::regclass
+			ORDER BY c.relname`).Scan(&partitions).Error
+	case SensorStorageTimescale:
+		err = database.DB.Raw(`
+			SELECT chunk_name AS name, range_start || ' - ' || range_end AS range
+			FROM timescaledb_information.chunks
+			WHERE hypertable_name = 'sensor_data'
+			ORDER BY range_start`).Scan(&partitions).Error
+	}
+	return partitions, err
+}
+
+// ensureSensorAggregates - Membuat continuous aggregate per jam dan per hari beserta policy refresh.
+// Membutuhkan TimescaleDB 2.7+ (percentile_cont di continuous aggregate).
+func ensureSensorAggregates() error {
+	for interval, view := range sensorAggregateViews {
+		err := database.DB.Exec(fmt.Sprintf(`
+			CREATE MATERIALIZED VIEW IF NOT EXISTS %[1]s
+			WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+			SELECT device_id, time_bucket(INTERVAL '%[2]d seconds', timestamp) AS bucket_start,
+				COUNT(*) AS count,
+				MIN(bpm) AS bpm_min, MAX(bpm) AS bpm_max, AVG(bpm) AS bpm_mean,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY bpm) AS bpm_median,
+				MIN(sp_o2) AS sp_o2_min, MAX(sp_o2) AS sp_o2_max, AVG(sp_o2) AS sp_o2_mean,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY sp_o2) AS sp_o2_median,
+				MIN(temp) AS temp_min, MAX(temp) AS temp_max, AVG(temp) AS temp_mean,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY temp) AS temp_median
+			FROM sensor_data
+			GROUP BY device_id, time_bucket(INTERVAL '%[2]d seconds', timestamp)
+			WITH NO DATA`, view, int64(interval/time.Second))).Error
+		if err != nil {
+			return err
+		}
+
+		// Data terbaru dibaca real-time dari hypertable, refresh hanya untuk rentang yang sudah lewat
+		err = database.DB.Exec(fmt.Sprintf(`
+			SELECT add_continuous_aggregate_policy('%s',
+				start_offset => INTERVAL '%d seconds', end_offset => INTERVAL '%d seconds',
+				schedule_interval => INTERVAL '%d seconds', if_not_exists => true)`,
+			view, int64(aggregatePolicyBuckets*interval/time.Second), int64(interval/time.Second), int64(interval/2/time.Second))).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aggregateRefreshWindow - Rentang bucket (dibulatkan keluar) yang berisi data lebih lama dari jangkauan policy
+// refresh continuous aggregate. ok bernilai false jika semua data masih di-refresh oleh policy.
+func aggregateRefreshWindow(readings []models.SensorData, interval time.Duration, now time.Time) (start, end time.Time, ok bool) {
+	policyFrom := now.Add(-aggregatePolicyBuckets * interval)
+	for _, reading := range readings {
+		if reading.ID == 0 || !reading.Timestamp.Before(policyFrom) {
+			continue
+		}
+		if !ok || reading.Timestamp.Before(start) {
+			start = reading.Timestamp
+		}
+		if !ok || reading.Timestamp.After(end) {
+			end = reading.Timestamp
+		}
+		ok = true
+	}
+	if !ok {
+		return start, end, false
+	}
+	return start.UTC().Truncate(interval), end.UTC().Truncate(interval).Add(interval), true
+}
+
+// RefreshSensorAggregates - Memperbarui continuous aggregate untuk data backfill (batch / data offline) yang
+// lebih lama dari jangkauan policy refresh, agar /aggregate tidak membaca bucket lama yang sudah usang.
+// Refresh berjalan di background karena refresh_continuous_aggregate tidak bisa di dalam transaksi.
+func RefreshSensorAggregates(readings []models.SensorData) {
+	if !sensorAggregatesReady {
+		return
+	}
+	now := time.Now()
+	for interval, view := range sensorAggregateViews {
+		start, end, ok := aggregateRefreshWindow(readings, interval, now)
+		if !ok {
+			continue
+		}
+		go func(view string, start, end time.Time) {
+			if err := database.DB.Exec("CALL refresh_continuous_aggregate(?::regclass, ?, ?)", view, start, end).Error; err != nil {
+				log.Printf("Failed to refresh %s for backfilled data: %v", view, err)
+			}
+		}(view, start, end)
+	}
+}
+
+// SensorAggregatesReady - Mengecek apakah continuous aggregate TimescaleDB tersedia
+func SensorAggregatesReady() bool {
+	return sensorAggregatesReady
+}
+
+// SensorAggregateView - Continuous aggregate untuk interval agregasi (kosong jika tidak tersedia)
+func SensorAggregateView(interval time.Duration) string {
+	if !sensorAggregatesReady {
+		return ""
+	}
+	return sensorAggregateViews[interval]
+}
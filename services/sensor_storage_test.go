@@ -0,0 +1,86 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestConvertSensorStoragePartitioned(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "partitioned")
+
+	// Data lama di bulan berbeda harus tetap ada setelah tabel dibuat ulang sebagai partisi
+	now := time.Now().UTC()
+	for _, timestamp := range []time.Time{now.AddDate(0, -2, 0), now.AddDate(0, -1, 0), now} {
+		if err := db.Create(&models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: timestamp}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := ConvertSensorStorage(SensorStoragePartitioned); err != nil {
+		t.Fatal(err)
+	}
+	mode, err := detectSensorStorage()
+	if err != nil || mode != SensorStoragePartitioned {
+		t.Fatalf("expected partitioned storage, got %q (err %v)", mode, err)
+	}
+
+	var count int64
+	db.Model(&models.SensorData{}).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected 3 readings after conversion, got %d", count)
+	}
+
+	sensorStorage = SensorStoragePartitioned
+	t.Cleanup(func() { sensorStorage = SensorStoragePlain })
+
+	partitions, err := SensorPartitions()
+	if err != nil || len(partitions) < partitionMonthsAhead+1 {
+		t.Fatalf("expected monthly partitions, got %d (err %v)", len(partitions), err)
+	}
+
+	// Pengiriman ulang dengan timestamp server berbeda tetap terdeteksi duplikat tanpa unique (device_id, reading_id)
+	readingID := "partitioned-1"
+	first, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 71, Timestamp: now, ReadingID: &readingID})
+	if duplicate {
+		t.Fatal("first reading reported as duplicate")
+	}
+	resent, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 71, Timestamp: now.Add(30 * time.Second), ReadingID: &readingID})
+	if !duplicate || resent.ID != first.ID {
+		t.Fatalf("resend should return reading %d, got %d (duplicate=%v)", first.ID, resent.ID, duplicate)
+	}
+
+	// Pengiriman ulang dengan timestamp yang sama ditahan unique index yang menyertakan timestamp
+	again, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 71, Timestamp: first.Timestamp, ReadingID: &readingID})
+	if !duplicate || again.ID != first.ID {
+		t.Fatalf("same-timestamp resend should return reading %d, got %d (duplicate=%v)", first.ID, again.ID, duplicate)
+	}
+}
+
+func TestAggregateRefreshWindow(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 30, 0, 0, time.UTC)
+	readings := []models.SensorData{
+		{ID: 1, Timestamp: time.Date(2024, 5, 8, 9, 15, 0, 0, time.UTC)},
+		{ID: 2, Timestamp: time.Date(2024, 5, 9, 22, 40, 0, 0, time.UTC)},
+		{ID: 0, Timestamp: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}, // digabung ke ringkasan
+		{ID: 3, Timestamp: now.Add(-time.Hour)},                         // masih dalam jangkauan policy
+	}
+
+	start, end, ok := aggregateRefreshWindow(readings, time.Hour, now)
+	if !ok {
+		t.Fatal("expected backfilled readings to need a refresh")
+	}
+	if want := time.Date(2024, 5, 8, 9, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Fatalf("expected start %v, got %v", want, start)
+	}
+	if want := time.Date(2024, 5, 9, 23, 0, 0, 0, time.UTC); !end.Equal(want) {
+		t.Fatalf("expected end %v, got %v", want, end)
+	}
+
+	if _, _, ok := aggregateRefreshWindow(readings[3:], time.Hour, now); ok {
+		t.Fatal("expected recent readings to be left to the policy")
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+	"backend/testdb"
+
+	"gorm.io/gorm"
+)
+
+// createTestDevice - User dan device untuk test yang membutuhkan data sensor
+func createTestDevice(t *testing.T, db *gorm.DB, name string) models.Device {
+	t.Helper()
+	user := models.User{Username: name, Password: "x", Email: name + "@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	device := models.Device{UserID: user.ID, Name: name}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatal(err)
+	}
+	return device
+}
+
+// storeReading - Menyimpan satu data sensor dalam transaksi sendiri
+func storeReading(t *testing.T, db *gorm.DB, reading models.SensorData) (models.SensorData, bool) {
+	t.Helper()
+	var duplicate bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		duplicate, err = StoreSensorData(tx, &reading)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reading, duplicate
+}
+
+func TestStoreSensorDataSeqDedupWindow(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "seq")
+
+	seq := int64(7)
+	boot := "boot-a"
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	first, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: start, Seq: &seq, BootID: &boot})
+	if duplicate {
+		t.Fatal("first reading reported as duplicate")
+	}
+
+	// Pengiriman ulang dengan timestamp berbeda sedikit tetap duplikat
+	resent, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 70, Timestamp: start.Add(time.Minute), Seq: &seq, BootID: &boot})
+	if !duplicate || resent.ID != first.ID {
+		t.Fatalf("resend within the window should return reading %d, got %d (duplicate=%v)", first.ID, resent.ID, duplicate)
+	}
+
+	// seq sama setelah reboot (boot_id berbeda) adalah data baru
+	otherBoot := "boot-b"
+	if _, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 71, Timestamp: start.Add(2 * time.Minute), Seq: &seq, BootID: &otherBoot}); duplicate {
+		t.Fatal("same seq with a different boot_id must not be a duplicate")
+	}
+
+	// seq sama di luar rentang waktu (perangkat tanpa boot_id yang di-flash ulang) adalah data baru
+	if _, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, BPM: 72, Timestamp: start.Add(SeqDedupWindow() + time.Minute), Seq: &seq, BootID: &boot}); duplicate {
+		t.Fatal("same seq outside the dedup window must not be a duplicate")
+	}
+}
+
+func TestStoreSensorDataMatchesReadingIDBeforeSeq(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "reading")
+
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	seqA, seqB := int64(1), int64(2)
+	idA, idB := "reading-a", "reading-b"
+	readingA, _ := storeReading(t, db, models.SensorData{DeviceID: device.ID, Timestamp: start, ReadingID: &idA, Seq: &seqA})
+	readingB, _ := storeReading(t, db, models.SensorData{DeviceID: device.ID, Timestamp: start.Add(time.Second), ReadingID: &idB, Seq: &seqB})
+
+	// reading_id milik A dengan seq milik B: yang dikembalikan harus A
+	got, duplicate := storeReading(t, db, models.SensorData{DeviceID: device.ID, Timestamp: start.Add(2 * time.Second), ReadingID: &idA, Seq: &seqB})
+	if !duplicate || got.ID != readingA.ID {
+		t.Fatalf("expected duplicate of reading %d (not %d), got %d", readingA.ID, readingB.ID, got.ID)
+	}
+}
+
+func TestCountSkippedSequenceAfterReboot(t *testing.T) {
+	db := testdb.Open(t)
+	device := createTestDevice(t, db, "skipped")
+
+	start := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	seq := int64(100)
+	storeReading(t, db, models.SensorData{DeviceID: device.ID, Timestamp: start, Seq: &seq})
+
+	if missed, err := CountSkippedSequence(db, device.ID, nil, 105); err != nil || missed != 4 {
+		t.Fatalf("expected 4 missed readings, got %d (err %v)", missed, err)
+	}
+	if missed, err := CountSkippedSequence(db, device.ID, nil, 0); err != nil || missed != 0 {
+		t.Fatalf("restarted seq should not count as missed, got %d (err %v)", missed, err)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+)
+
+// Masa berlaku default token
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Error sesi
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrSessionRevoked      = errors.New("session revoked")
+)
+
+// TokenPair - Access token (JWT) dan refresh token (opaque) untuk client
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // Detik sampai access token kedaluwarsa
+	SessionID    uint   `json:"session_id"`
+}
+
+// durationFromEnv - Membaca durasi dalam menit dari environment
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	minutes, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || minutes <= 0 {
+		return fallback
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// AccessTokenTTL - Masa berlaku access token (ACCESS_TOKEN_TTL_MINUTES)
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL_MINUTES", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL - Masa berlaku refresh token (REFRESH_TOKEN_TTL_MINUTES)
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL_MINUTES", defaultRefreshTokenTTL)
+}
+
+// HashToken - SHA-256 dari token acak yang disimpan di database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomToken - Membuat token acak (hex) sepanjang n byte
+func RandomToken(n int) string {
+	bytes := make([]byte, n)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+// signAccessToken - Membuat JWT berumur pendek yang terikat ke sesi (claim "sid")
+func signAccessToken(user models.User, sessionID uint, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"email":    user.Email,
+		"role":     user.Role,
+		"sid":      sessionID,
+		"exp":      time.Now().Add(ttl).Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// CreateSession - Membuat sesi login baru dan mengembalikan pasangan token
+func CreateSession(user models.User, userAgent, ip string) (*TokenPair, error) {
+	refreshToken := RandomToken(32)
+	now := time.Now()
+	session := models.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: HashToken(refreshToken),
+		UserAgent:        userAgent,
+		IP:               ip,
+		LastUsedAt:       now,
+		ExpiresAt:        now.Add(RefreshTokenTTL()),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return issueTokenPair(user, session.ID, refreshToken)
+}
+
+// issueTokenPair - Membuat access token untuk sesi dan menggabungkannya dengan refresh token
+func issueTokenPair(user models.User, sessionID uint, refreshToken string) (*TokenPair, error) {
+	ttl := AccessTokenTTL()
+	accessToken, err := signAccessToken(user, sessionID, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl / time.Second),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// RefreshSession - Menukar refresh token dengan pasangan token baru (refresh token di-rotasi).
+// Jika refresh token lama dipakai ulang, sesi dianggap bocor dan langsung dicabut.
+func RefreshSession(refreshToken, userAgent, ip string) (*TokenPair, error) {
+	hash := HashToken(refreshToken)
+	newToken := RandomToken(32)
+	now := time.Now()
+
+	var session models.Session
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if session.RevokedAt != nil {
+			return ErrSessionRevoked
+		}
+		if now.After(session.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// Rotasi hanya berhasil jika token belum dirotasi request lain secara bersamaan
+		result := tx.Model(&models.Session{}).Where("id = ? AND refresh_token_hash = ?", session.ID, hash).
+			Updates(map[string]interface{}{
+				"refresh_token_hash":          HashToken(newToken),
+				"previous_refresh_token_hash": hash,
+				"user_agent":                  userAgent,
+				"ip":                          ip,
+				"last_used_at":                now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRefreshToken
+		}
+		return nil
+	})
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		// Refresh token lama dipakai lagi: kemungkinan dicuri, cabut sesinya
+		result := database.DB.Model(&models.Session{}).
+			Where("previous_refresh_token_hash = ? AND revoked_at IS NULL", hash).
+			Update("revoked_at", now)
+		if result.Error == nil && result.RowsAffected > 0 {
+			return nil, ErrSessionRevoked
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, session.UserID).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return issueTokenPair(user, session.ID, newToken)
+}
+
+// ActiveSessionUser - Mengecek sesi masih aktif dan mengembalikan data user terbaru
+func ActiveSessionUser(sessionID, userID uint) (*models.User, error) {
+	var user models.User
+	err := database.DB.Joins("JOIN sessions ON sessions.user_id = users.id").
+		Where("sessions.id = ? AND users.id = ? AND sessions.revoked_at IS NULL AND sessions.expires_at > ?",
+			sessionID, userID, time.Now()).
+		First(&user).Error
+	if err != nil {
+		return nil, ErrSessionRevoked
+	}
+	return &user, nil
+}
+
+// RevokeSession - Mencabut satu sesi milik user
+func RevokeSession(userID, sessionID uint) (bool, error) {
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllSessions - Mencabut semua sesi aktif milik user
+func RevokeAllSessions(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// Key pengaturan sistem
+const (
+	SettingRequire2FARoles = "require_2fa_roles" // Daftar role (dipisah koma) yang wajib memakai 2FA
+)
+
+// Lama cache pengaturan di memori, agar middleware tidak query database di setiap request
+const settingsCacheTTL = 30 * time.Second
+
+// Nilai default jika pengaturan belum pernah diubah admin
+var settingDefaults = map[string]string{
+	// Sama dengan default sebelum role model ("admin" = super-admin). Role lain (org-admin, clinician)
+	// diwajibkan lewat PUT /admin/security-policy
+	SettingRequire2FARoles: "super-admin",
+}
+
+var settingsCache = struct {
+	sync.Mutex
+	values   map[string]string
+	loadedAt time.Time
+}{}
+
+// GetSetting - Membaca pengaturan sistem (dengan cache singkat), default jika belum ada
+func GetSetting(key string) string {
+	settingsCache.Lock()
+	defer settingsCache.Unlock()
+
+	if settingsCache.values == nil || time.Since(settingsCache.loadedAt) > settingsCacheTTL {
+		var settings []models.SystemSetting
+		if err := database.DB.Find(&settings).Error; err == nil {
+			values := make(map[string]string, len(settings))
+			for _, setting := range settings {
+				values[setting.Key] = setting.Value
+			}
+			settingsCache.values = values
+			settingsCache.loadedAt = time.Now()
+		}
+	}
+
+	if value, ok := settingsCache.values[key]; ok {
+		return value
+	}
+	return settingDefaults[key]
+}
+
+// SetSetting - Menyimpan pengaturan sistem dan mengosongkan cache
+func SetSetting(key, value string, updatedBy uint) error {
+	setting := models.SystemSetting{Key: key, Value: value, UpdatedBy: &updatedBy, UpdatedAt: time.Now()}
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_by", "updated_at"}),
+	}).Create(&setting).Error
+	if err != nil {
+		return err
+	}
+
+	settingsCache.Lock()
+	settingsCache.values = nil
+	settingsCache.Unlock()
+	return nil
+}
+
+// Require2FARoles - Role yang wajib memakai 2FA
+func Require2FARoles() []string {
+	var roles []string
+	for _, role := range strings.Split(GetSetting(SettingRequire2FARoles), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// RoleRequires2FA - Mengecek apakah role wajib memakai 2FA
+func RoleRequires2FA(role string) bool {
+	for _, r := range Require2FARoles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
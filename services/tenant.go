@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	database "backend/config"
+	"backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Nama organisasi untuk user yang mendaftar sendiri jika DEFAULT_ORGANIZATION_ID tidak diisi
+const defaultOrganizationName = "Default"
+
+// Error organisasi
+var (
+	ErrOrganizationNotFound = errors.New("organization not found")
+	ErrOrganizationNotEmpty = errors.New("organization still has users")
+)
+
+// TenantOf - Organisasi yang membatasi data yang boleh dilihat role.
+// nil berarti lintas organisasi (super-admin). Admin yang belum ditempatkan
+// di organisasi mana pun dibatasi ke organisasi 0 sehingga tidak melihat data apa pun.
+func TenantOf(role string, organizationID *uint) *uint {
+	if HasPermission(role, models.PermManageOrganizations) {
+		return nil
+	}
+	if organizationID == nil {
+		none := uint(0)
+		return &none
+	}
+	return organizationID
+}
+
+// InTenant - Mengecek apakah data milik organisasi berada dalam tenant
+func InTenant(tenant, organizationID *uint) bool {
+	if tenant == nil {
+		return true
+	}
+	return organizationID != nil && *organizationID == *tenant
+}
+
+// tenantKey - Key context untuk tenant yang membatasi query (lihat WithTenant)
+type tenantKey struct{}
+
+// WithTenant - Context yang membatasi semua query GORM ke organisasi tenant.
+// nil berarti lintas organisasi sehingga context tidak diubah.
+func WithTenant(ctx context.Context, tenant *uint) context.Context {
+	if tenant == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, *tenant)
+}
+
+// RegisterTenantScope - Memasang callback GORM yang menambahkan filter tenant ke setiap query, update dan
+// delete yang dijalankan dengan context dari WithTenant, sehingga handler admin tidak perlu memasang scope
+// sendiri. Tabel dengan kolom organization_id (users, devices) difilter langsung, tabel milik pasien
+// (user_id / target_user_id) difilter lewat organisasi pemiliknya. Query Raw / Exec tidak difilter.
+func RegisterTenantScope(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:scope", applyTenantScope); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:scope", applyTenantScope); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:scope", applyTenantScope); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("tenant:scope", applyTenantScope)
+}
+
+// applyTenantScope - Callback GORM yang menambahkan kondisi tenant ke statement
+func applyTenantScope(db *gorm.DB) {
+	tenant, ok := db.Statement.Context.Value(tenantKey{}).(uint)
+	if !ok || db.Error != nil || db.Statement.Schema == nil {
+		return
+	}
+
+	if field := db.Statement.Schema.LookUpField("organization_id"); field != nil {
+		db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: tenant},
+		}})
+		return
+	}
+	for _, column := range []string{"user_id", "target_user_id"} {
+		if field := db.Statement.Schema.LookUpField(column); field != nil {
+			db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{clause.Expr{
+				SQL:  "? IN (SELECT id FROM users WHERE organization_id = ?)",
+				Vars: []interface{}{clause.Column{Table: clause.CurrentTable, Name: field.DBName}, tenant},
+			}}})
+			return
+		}
+	}
+}
+
+// RegistrationOrganization - Organisasi untuk user yang mendaftar sendiri: DEFAULT_ORGANIZATION_ID,
+// atau organisasi "Default" (dibuat jika belum ada) agar user terlihat oleh admin organisasi
+func RegistrationOrganization(tx *gorm.DB) (*uint, error) {
+	if value := os.Getenv("DEFAULT_ORGANIZATION_ID"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT_ORGANIZATION_ID: %w", err)
+		}
+		organization, err := FindOrganization(uint(id))
+		if err != nil {
+			return nil, err
+		}
+		return &organization.ID, nil
+	}
+
+	// ON CONFLICT agar registrasi pertama yang bersamaan tidak gagal karena nama organisasi unik
+	organization := models.Organization{Name: defaultOrganizationName}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&organization).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("name = ?", organization.Name).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization.ID, nil
+}
+
+// AssignUnassignedUsers - Menempatkan pasien lama yang mendaftar sendiri sebelum ada organisasi registrasi
+// beserta device-nya ke organisasi registrasi. Role lain tetap ditempatkan manual oleh super-admin
+// karena memberi hak akses ke pasien di organisasi tersebut. Dijalankan lewat `go run ./cmd/migrate data`.
+func AssignUnassignedUsers() (int, error) {
+	var users []models.User
+	err := database.DB.Unscoped().Select("id").Where("organization_id IS NULL AND role = ?", models.RolePatient).Find(&users).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, nil
+	}
+
+	organizationID, err := RegistrationOrganization(database.DB)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve registration organization: %w", err)
+	}
+	for i, user := range users {
+		if err := SetUserOrganization(database.DB, user.ID, organizationID); err != nil {
+			return i, fmt.Errorf("user %d: %w", user.ID, err)
+		}
+	}
+	return len(users), nil
+}
+
+// FindOrganization - Mengambil organisasi berdasarkan ID
+func FindOrganization(id uint) (*models.Organization, error) {
+	var org models.Organization
+	if err := database.DB.First(&org, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// SetUserOrganization - Memindahkan user beserta device miliknya ke organisasi lain
+func SetUserOrganization(tx *gorm.DB, userID uint, organizationID *uint) error {
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("organization_id", organizationID).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Device{}).Where("user_id = ?", userID).Update("organization_id", organizationID).Error
+	})
+}
+
+// DeleteOrganization - Menghapus organisasi yang sudah tidak memiliki user
+func DeleteOrganization(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var members int64
+		if err := tx.Model(&models.User{}).Where("organization_id = ?", id).Count(&members).Error; err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrOrganizationNotEmpty
+		}
+
+		result := tx.Delete(&models.Organization{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOrganizationNotFound
+		}
+		return nil
+	})
+}
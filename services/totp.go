@@ -0,0 +1,327 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/fieldcrypt"
+	"backend/models"
+
+	"github.com/dgrijalva/jwt-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Parameter TOTP (RFC 6238) yang didukung semua aplikasi authenticator
+const (
+	totpPeriod        = 30
+	totpDigits        = 6
+	totpSkew          = 1 // Toleransi +/- satu periode untuk jam yang tidak sinkron
+	totpSecretBytes   = 20
+	recoveryCodeCount = 10
+	mfaTokenTTL       = 5 * time.Minute
+	mfaTokenPurpose   = "mfa_login"
+)
+
+// Error 2FA
+var (
+	ErrInvalidTOTPCode   = errors.New("invalid two-factor code")
+	ErrTwoFactorNotSetup = errors.New("two-factor authentication is not set up")
+	ErrTwoFactorEnabled  = errors.New("two-factor authentication is already enabled")
+	ErrInvalidMFAToken   = errors.New("invalid or expired two-factor login token")
+)
+
+var (
+	base32NoPadding        = base32.StdEncoding.WithPadding(base32.NoPadding)
+	recoveryCodeFormatting = strings.NewReplacer("-", "", " ", "")
+)
+
+// TOTPIssuer - Nama aplikasi yang tampil di authenticator (TOTP_ISSUER)
+func TOTPIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "HOSE"
+}
+
+// GenerateTOTPSecret - Membuat secret TOTP acak (base32 tanpa padding)
+func GenerateTOTPSecret() string {
+	bytes := make([]byte, totpSecretBytes)
+	_, _ = rand.Read(bytes)
+	return base32NoPadding.EncodeToString(bytes)
+}
+
+// TOTPProvisioningURI - URI otpauth:// untuk dijadikan QR code oleh client
+func TOTPProvisioningURI(secret, account string) string {
+	issuer := TOTPIssuer()
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// totpCode - Menghitung kode TOTP untuk counter tertentu (HOTP, RFC 4226)
+func totpCode(secret string, counter int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// matchTOTP - Mengembalikan counter yang cocok dengan kode (dalam toleransi waktu)
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		expected, err := totpCode(secret, current+offset)
+		if err == nil && hmac.Equal([]byte(expected), []byte(code)) {
+			return current + offset, true
+		}
+	}
+	return 0, false
+}
+
+// consumeTOTP - Memvalidasi kode TOTP dan menyimpan counter-nya agar kode yang sama tidak bisa dipakai ulang
+func consumeTOTP(tx *gorm.DB, user models.User, code string) error {
+	if user.TOTPSecret == nil {
+		return ErrTwoFactorNotSetup
+	}
+	counter, ok := matchTOTP(*user.TOTPSecret, code, time.Now())
+	if !ok || counter <= user.TOTPLastCounter {
+		return ErrInvalidTOTPCode
+	}
+	result := tx.Model(&models.User{}).Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+		UpdateColumn("totp_last_counter", counter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// hashRecoveryCode - Hash kode cadangan (tanpa tanda hubung, huruf besar)
+func hashRecoveryCode(code string) string {
+	return HashToken(strings.ToUpper(recoveryCodeFormatting.Replace(code)))
+}
+
+// generateRecoveryCodes - Mengganti semua kode cadangan user dan mengembalikan plaintext-nya (hanya sekali)
+func generateRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	entries := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		bytes := make([]byte, 5)
+		_, _ = rand.Read(bytes)
+		raw := base32NoPadding.EncodeToString(bytes) // 8 karakter
+		codes[i] = raw[:4] + "-" + raw[4:]
+		entries[i] = models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(raw)}
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// consumeRecoveryCode - Memakai satu kode cadangan (sekali pakai)
+func consumeRecoveryCode(tx *gorm.DB, userID uint, code string) error {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTOTPCode
+	}
+	return nil
+}
+
+// VerifySecondFactor - Memvalidasi kode TOTP 6 digit atau kode cadangan
+func VerifySecondFactor(user models.User, code string) error {
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotSetup
+	}
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return consumeTOTP(database.DB, user, code)
+	}
+	return consumeRecoveryCode(database.DB, user.ID, code)
+}
+
+// SetupTOTP - Membuat secret baru untuk user (2FA belum aktif sampai dikonfirmasi)
+func SetupTOTP(user models.User) (secret, uri string, err error) {
+	if user.TOTPEnabled {
+		return "", "", ErrTwoFactorEnabled
+	}
+	secret = GenerateTOTPSecret()
+	// Update lewat map tidak melewati serializer, jadi secret dienkripsi di sini
+	ciphertext, err := fieldcrypt.Encrypt([]byte(secret), fieldcrypt.RowAAD("users", "totp_secret", user.ID))
+	if err != nil {
+		return "", "", err
+	}
+	if err := database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"totp_secret": ciphertext, "totp_last_counter": 0}).Error; err != nil {
+		return "", "", err
+	}
+	return secret, TOTPProvisioningURI(secret, user.Email), nil
+}
+
+// EnableTOTP - Mengaktifkan 2FA setelah kode pertama dari authenticator valid, mengembalikan kode cadangan
+func EnableTOTP(user models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := consumeTOTP(tx, user, code); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = generateRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// DisableTOTP - Menonaktifkan 2FA dan menghapus secret serta kode cadangan
+func DisableTOTP(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_enabled":      false,
+			"totp_secret":       nil,
+			"totp_last_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes - Membuat ulang kode cadangan (kode lama tidak berlaku lagi)
+func RegenerateRecoveryCodes(userID uint) ([]string, error) {
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = generateRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// RemainingRecoveryCodes - Jumlah kode cadangan yang belum dipakai
+func RemainingRecoveryCodes(userID uint) int64 {
+	var count int64
+	database.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
+
+// MFAToken - Isi mfa_token yang valid: user yang login dan jti untuk menandai token sudah dipakai
+type MFAToken struct {
+	ID        string
+	User      models.User
+	ExpiresAt time.Time
+}
+
+// SignMFAToken - Token sementara setelah password benar, ditukar dengan sesi setelah kode 2FA valid
+func SignMFAToken(user models.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": mfaTokenPurpose,
+		"jti":     RandomToken(16),
+		"user_id": user.ID,
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// ParseMFAToken - Memvalidasi token sementara 2FA dan mengembalikan user-nya
+func ParseMFAToken(tokenString string) (*MFAToken, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidMFAToken
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidMFAToken
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != mfaTokenPurpose {
+		return nil, ErrInvalidMFAToken
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, ErrInvalidMFAToken
+	}
+	jti, ok := claims["jti"].(string)
+	if !ok || jti == "" {
+		return nil, ErrInvalidMFAToken
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, ErrInvalidMFAToken
+	}
+
+	parsed := MFAToken{ID: jti, ExpiresAt: time.Unix(int64(exp), 0)}
+	if err := database.DB.First(&parsed.User, uint(userID)).Error; err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	var used int64
+	database.DB.Model(&models.UsedMFAToken{}).Where("jti = ?", jti).Count(&used)
+	if used > 0 {
+		return nil, ErrInvalidMFAToken
+	}
+	return &parsed, nil
+}
+
+// ConsumeMFAToken - Menandai mfa_token sudah ditukar dengan sesi. Gagal jika token yang sama sudah dipakai
+// (termasuk oleh request lain yang berjalan bersamaan), sehingga satu token hanya menghasilkan satu sesi.
+func ConsumeMFAToken(token MFAToken) error {
+	// jti yang token-nya sudah kedaluwarsa tidak perlu disimpan lagi
+	database.DB.Where("expires_at < ?", time.Now()).Delete(&models.UsedMFAToken{})
+
+	result := database.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.UsedMFAToken{JTI: token.ID, UserID: token.User.ID, ExpiresAt: token.ExpiresAt})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFAToken
+	}
+	return nil
+}
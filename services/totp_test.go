@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"backend/models"
+	"backend/testdb"
+)
+
+func TestMFATokenCanOnlyBeUsedOnce(t *testing.T) {
+	db := testdb.Open(t)
+	t.Setenv("JWT_SECRET", "test-secret")
+	user := models.User{Username: "mfa-once", Password: "x", Email: "mfa-once@example.com"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := SignMFAToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := ParseMFAToken(signed)
+	if err != nil || token.User.ID != user.ID || token.ID == "" {
+		t.Fatalf("expected valid token for user %d, got %+v (err %v)", user.ID, token, err)
+	}
+	if err := ConsumeMFAToken(*token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Token yang sama tidak bisa ditukar dengan sesi kedua
+	if err := ConsumeMFAToken(*token); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expected ErrInvalidMFAToken on reuse, got %v", err)
+	}
+	if _, err := ParseMFAToken(signed); !errors.Is(err, ErrInvalidMFAToken) {
+		t.Fatalf("expected used token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	database "backend/config"
+	"backend/models"
+	"backend/notifier"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Pengaturan verifikasi email
+const (
+	verificationTokenTTL   = 24 * time.Hour
+	verificationResendWait = 2 * time.Minute // Jeda minimum antar pengiriman ulang email verifikasi
+	verificationPurpose    = "verify_email"
+	mailSendTimeout        = 15 * time.Second
+)
+
+// Error verifikasi email
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrVerificationRateLimited  = errors.New("verification email was sent recently")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+)
+
+// Mailer - Pengirim email akun (verifikasi, dll). Bisa diganti notifier.MemoryNotifier saat pengujian.
+var Mailer notifier.Notifier
+
+// InitMailer - Membuat Mailer dari environment. Server tidak dijalankan tanpa mailer agar email
+// verifikasi / reset password tidak diam-diam hanya ditulis ke log.
+func InitMailer() {
+	if Mailer != nil {
+		return
+	}
+	mailer, err := notifier.MailerFromEnv()
+	if err != nil {
+		log.Fatal("❌ Email is not configured: ", err)
+	}
+	Mailer = mailer
+}
+
+// SendMail - Mengirim email akun lewat Mailer
+func SendMail(to, subject, body string) error {
+	if Mailer == nil {
+		return notifier.ErrMailerNotConfigured
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+	return Mailer.Send(ctx, notifier.Message{Target: to, Subject: subject, Body: body})
+}
+
+// RequireVerifiedEmail - Kebijakan opsional (REQUIRE_EMAIL_VERIFIED=true): user harus verifikasi email
+// sebelum bisa mendaftarkan device dan menerima notifikasi alert
+func RequireVerifiedEmail() bool {
+	return strings.EqualFold(os.Getenv("REQUIRE_EMAIL_VERIFIED"), "true")
+}
+
+// AppBaseURL - URL publik aplikasi untuk link di email (APP_BASE_URL)
+func AppBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// signVerificationToken - Membuat token verifikasi yang ditandatangani dan terikat ke alamat email.
+// Jika user mengganti email, token lama otomatis tidak berlaku.
+func signVerificationToken(user models.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": verificationPurpose,
+		"user_id": user.ID,
+		"email":   user.Email,
+		"exp":     time.Now().Add(verificationTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// SendVerificationEmail - Mengirim link verifikasi ke email user.
+// Jika force false, pengiriman ditolak bila email terakhir dikirim kurang dari verificationResendWait lalu.
+func SendVerificationEmail(user models.User, force bool) error {
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	// Klaim slot pengiriman secara atomik agar request bersamaan tidak mengirim dua kali
+	now := time.Now()
+	query := database.DB.Model(&models.User{}).Where("id = ?", user.ID)
+	if !force {
+		query = query.Where("verification_sent_at IS NULL OR verification_sent_at < ?", now.Add(-verificationResendWait))
+	}
+	result := query.UpdateColumn("verification_sent_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVerificationRateLimited
+	}
+
+	token, err := signVerificationToken(user)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", AppBaseURL(), url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.",
+		user.Username, link, int(verificationTokenTTL/time.Hour))
+
+	return SendMail(user.Email, "Verify your email address", body)
+}
+
+// VerifyEmailToken - Memvalidasi token verifikasi dan menandai email user sebagai terverifikasi
+func VerifyEmailToken(tokenString string) (*models.User, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidVerificationToken
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != verificationPurpose {
+		return nil, ErrInvalidVerificationToken
+	}
+	userID, ok := claims["user_id"].(float64)
+	email, okEmail := claims["email"].(string)
+	if !ok || !okEmail {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND email = ?", uint(userID), email).First(&user).Error; err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	if !user.EmailVerified {
+		if err := database.DB.Model(&user).Update("email_verified", true).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &user, nil
+}
@@ -0,0 +1,47 @@
+// Package testdb menyediakan database PostgreSQL untuk test yang membutuhkan database.
+package testdb
+
+import (
+	"os"
+	"testing"
+
+	database "backend/config"
+	"backend/migrations"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open - Database test dari TEST_DATABASE_DSN (test di-skip jika kosong). Schema public dihapus lalu
+// dibuat ulang lewat migrasi, jadi DSN harus menunjuk database khusus test. database.DB diarahkan
+// ke database ini selama test berjalan.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public").Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrations.Up(db, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}